@@ -0,0 +1,298 @@
+// Package fakebotapi provides a fake Telegram Bot API server, so that the bot can be tested offline.
+package fakebotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"weather-or-not-bot/internal/utils"
+
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// maxPollingWait caps how long getUpdates waits for new updates, whatever timeout is asked for.
+const maxPollingWait = time.Second
+
+// Call is a single request made to the server.
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+// failure is a prepared error response to the next call of a method.
+type failure struct {
+	code        int
+	description string
+	retryAfter  int
+}
+
+// Server is a fake Bot API recording every call made to it.
+type Server struct {
+	srv   *httptest.Server
+	token string
+	self  bot.User
+
+	mu            sync.Mutex
+	calls         []Call
+	updates       []bot.Update
+	arrived       chan struct{}
+	nextUpdateID  int
+	nextMessageID int
+	webhook       string
+	failures      map[string][]failure
+}
+
+// NewServer starts a fake Bot API serving the bot with the given token.
+func NewServer(token string) *Server {
+	s := &Server{
+		token:         token,
+		self:          bot.User{ID: 1, FirstName: "WeartheBot", UserName: "wearthebot", IsBot: true},
+		arrived:       make(chan struct{}),
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		failures:      map[string][]failure{},
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL returns the address of the server.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// NewBotAPI creates a bot library client talking to the server.
+func (s *Server) NewBotAPI() (*bot.BotAPI, error) {
+	client, err := utils.NewBotHTTPClient(s.srv.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create client for fake Bot API")
+	}
+
+	return bot.NewBotAPIWithClient(s.token, client)
+}
+
+// Self returns the user the bot is authorized as.
+func (s *Server) Self() bot.User {
+	return s.self
+}
+
+// PushUpdate queues an update for getUpdates, numbering it if it has no ID yet.
+func (s *Server) PushUpdate(upd bot.Update) bot.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if upd.UpdateID == 0 {
+		upd.UpdateID = s.nextUpdateID
+	}
+	s.nextUpdateID = upd.UpdateID + 1
+	s.updates = append(s.updates, upd)
+
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+
+	return upd
+}
+
+// FailNext makes the next call of the method fail with the given code and description.
+// A positive retryAfter is passed as 'retry_after', as Telegram does on flood control.
+func (s *Server) FailNext(method string, code int, description string, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method] = append(s.failures[method], failure{code: code, description: description, retryAfter: retryAfter})
+}
+
+// Calls returns all calls made to the server so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns calls of the method made to the server so far.
+func (s *Server) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Webhook returns the currently set webhook URL.
+func (s *Server) Webhook() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.webhook
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// Paths look like '/bot<token>/<method>'.
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] != "bot"+s.token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", 0)
+		return
+	}
+	method := parts[1]
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: cannot parse parameters", 0)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Params: r.PostForm})
+	if f, ok := s.popFailure(method); ok {
+		s.mu.Unlock()
+		writeError(w, f.code, f.description, f.retryAfter)
+		return
+	}
+	s.mu.Unlock()
+
+	switch method {
+	case "getMe":
+		writeResult(w, s.self)
+	case "setWebhook":
+		s.setWebhook(r.PostForm.Get("url"))
+		writeResult(w, true)
+	case "deleteWebhook":
+		s.setWebhook("")
+		writeResult(w, true)
+	case "getWebhookInfo":
+		writeResult(w, bot.WebhookInfo{URL: s.Webhook()})
+	case "getUpdates":
+		if s.Webhook() != "" {
+			writeError(w, http.StatusConflict, "Conflict: can't use getUpdates method while webhook is active", 0)
+			return
+		}
+		writeResult(w, s.getUpdates(r.PostForm))
+	case "sendMessage":
+		writeResult(w, s.newMessage(r.PostForm))
+	case "editMessageText":
+		writeResult(w, s.editedMessage(r.PostForm))
+	case "answerCallbackQuery", "answerInlineQuery", "setMyCommands":
+		writeResult(w, true)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found", 0)
+	}
+}
+
+func (s *Server) popFailure(method string) (failure, bool) {
+	failures := s.failures[method]
+	if len(failures) == 0 {
+		return failure{}, false
+	}
+	s.failures[method] = failures[1:]
+
+	return failures[0], true
+}
+
+func (s *Server) setWebhook(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhook = url
+}
+
+// getUpdates returns updates starting from the offset, waiting for a while if there are none yet.
+func (s *Server) getUpdates(params url.Values) []bot.Update {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	timeout, _ := strconv.Atoi(params.Get("timeout"))
+
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxPollingWait {
+		wait = maxPollingWait
+	}
+	deadline := time.After(wait)
+
+	for {
+		s.mu.Lock()
+		var updates []bot.Update
+		for _, upd := range s.updates {
+			if upd.UpdateID >= offset && (limit == 0 || len(updates) < limit) {
+				updates = append(updates, upd)
+			}
+		}
+		arrived := s.arrived
+		s.mu.Unlock()
+
+		if len(updates) > 0 || wait == 0 {
+			return updates
+		}
+
+		select {
+		case <-arrived:
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func (s *Server) newMessage(params url.Values) bot.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	msg := bot.Message{
+		MessageID: s.nextMessageID,
+		From:      &s.self,
+		Date:      int(time.Now().Unix()),
+		Chat:      &bot.Chat{ID: chatID},
+		Text:      params.Get("text"),
+	}
+	s.nextMessageID++
+
+	return msg
+}
+
+func (s *Server) editedMessage(params url.Values) bot.Message {
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	messageID, _ := strconv.Atoi(params.Get("message_id"))
+
+	return bot.Message{
+		MessageID: messageID,
+		From:      &s.self,
+		Date:      int(time.Now().Unix()),
+		EditDate:  int(time.Now().Unix()),
+		Chat:      &bot.Chat{ID: chatID},
+		Text:      params.Get("text"),
+	}
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Internal Server Error", 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bot.APIResponse{Ok: true, Result: raw})
+}
+
+func writeError(w http.ResponseWriter, code int, description string, retryAfter int) {
+	resp := bot.APIResponse{Ok: false, ErrorCode: code, Description: description}
+	if retryAfter > 0 {
+		resp.Parameters = &bot.ResponseParameters{RetryAfter: retryAfter}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
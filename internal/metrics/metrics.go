@@ -0,0 +1,119 @@
+// Package metrics holds Prometheus collectors shared by the bot components.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "weather_bot"
+
+// Outcomes of handled updates, queries and sends.
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeSent    = "sent"
+	OutcomeRetried = "retried"
+	OutcomeFailed  = "failed"
+)
+
+// Types of errors counted by ErrorsTotal.
+const (
+	ErrorHandler  = "handler"
+	ErrorPanic    = "panic"
+	ErrorForecast = "forecast"
+	ErrorDatabase = "database"
+	ErrorSend     = "send"
+)
+
+var (
+	UpdatesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_received_total",
+		Help:      "Updates received from Telegram by kind.",
+	}, []string{"kind"})
+
+	UpdatesHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_handled_total",
+		Help:      "Updates handled by handler and outcome.",
+	}, []string{"handler", "outcome"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent handling an update by handler.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	ErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "errors_total",
+		Help:      "Errors by type.",
+	}, []string{"type"})
+
+	ForecastDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forecast_request_duration_seconds",
+		Help:      "Weather provider request latency by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	ForecastResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "forecast_responses_total",
+		Help:      "Weather provider responses by endpoint and status code, 'error' when there was no response.",
+	}, []string{"endpoint", "code"})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by query name and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query", "outcome"})
+
+	SendsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sends_total",
+		Help:      "Outgoing message attempts by outcome.",
+	}, []string{"outcome"})
+
+	SendsWaiting = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sends_waiting",
+		Help:      "Outgoing messages waiting for their turn or being retried.",
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHandler records the outcome and duration of an update handler started at start.
+func ObserveHandler(handler string, start time.Time, err error) {
+	HandlerDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+	UpdatesHandled.WithLabelValues(handler, outcome(err)).Inc()
+	if err != nil {
+		ErrorsTotal.WithLabelValues(ErrorHandler).Inc()
+	}
+}
+
+// ObserveQuery records the outcome and duration of a database query started at start.
+func ObserveQuery(query string, start time.Time, err error) {
+	QueryDuration.WithLabelValues(query, outcome(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ErrorsTotal.WithLabelValues(ErrorDatabase).Inc()
+	}
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
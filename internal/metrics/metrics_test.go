@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveHandler(t *testing.T) {
+	someErr := errors.New("some error")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantOutcome string
+		wantErrors  float64
+	}{
+		{"1. Handled", nil, OutcomeOK, 0},
+		{"2. Failed", someErr, OutcomeError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := testutil.ToFloat64(UpdatesHandled.WithLabelValues("handleTest", tt.wantOutcome))
+			errs := testutil.ToFloat64(ErrorsTotal.WithLabelValues(ErrorHandler))
+
+			ObserveHandler("handleTest", time.Now(), tt.err)
+
+			if got := testutil.ToFloat64(UpdatesHandled.WithLabelValues("handleTest", tt.wantOutcome)) - handled; got != 1 {
+				t.Errorf("ObserveHandler() counted %v updates, want 1", got)
+			}
+			if got := testutil.ToFloat64(ErrorsTotal.WithLabelValues(ErrorHandler)) - errs; got != tt.wantErrors {
+				t.Errorf("ObserveHandler() counted %v errors, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestObserveQuery(t *testing.T) {
+	errs := testutil.ToFloat64(ErrorsTotal.WithLabelValues(ErrorDatabase))
+
+	ObserveQuery("test_query", time.Now(), nil)
+	ObserveQuery("test_query", time.Now(), errors.New("some error"))
+
+	if got := testutil.CollectAndCount(QueryDuration, "weather_bot_db_query_duration_seconds"); got < 2 {
+		t.Errorf("ObserveQuery() left %d series, want at least 2", got)
+	}
+	if got := testutil.ToFloat64(ErrorsTotal.WithLabelValues(ErrorDatabase)) - errs; got != 1 {
+		t.Errorf("ObserveQuery() counted %v errors, want 1", got)
+	}
+}
@@ -1,6 +1,10 @@
 package repository
 
-import bot "gopkg.in/telegram-bot-api.v4"
+import (
+	"weather-or-not-bot/internal/types"
+
+	bot "gopkg.in/telegram-bot-api.v4"
+)
 
 var buttonsEN = map[string]string{
 	"AtMyLocation": "Weather at my location",
@@ -20,8 +24,14 @@ var buttonsEN = map[string]string{
 	"72Hours":      "72 hours",
 	"96Hours":      "96 hours",
 	"120Hours":     "120 hours",
+	"Favorite":     "⭐ ",
+	"Recent":       "🕘 ",
+	"ForgetMe":     "🗑 Forget me",
 }
 
+// favoritesPerRow is the number of favorite buttons in a row of the main menu.
+const favoritesPerRow = 3
+
 type BotUIRepo struct {
 }
 
@@ -29,17 +39,51 @@ func NewBotUIRepo() *BotUIRepo {
 	return &BotUIRepo{}
 }
 
-func (r *BotUIRepo) GetMainMenuKeyboard() bot.ReplyKeyboardMarkup {
-	return bot.NewReplyKeyboard(
+// GetMainMenuKeyboard returns the main menu with a button for every favorite below.
+func (r *BotUIRepo) GetMainMenuKeyboard(favorites ...string) bot.ReplyKeyboardMarkup {
+	rows := [][]bot.KeyboardButton{
 		bot.NewKeyboardButtonRow(bot.NewKeyboardButtonLocation(buttonsEN["AtMyLocation"])),
 		bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["AtADiffPlace"])),
-	)
+	}
+
+	var row []bot.KeyboardButton
+	for _, name := range favorites {
+		row = append(row, bot.NewKeyboardButton(buttonsEN["Favorite"]+name))
+		if len(row) == favoritesPerRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	return bot.NewReplyKeyboard(rows...)
+}
+
+// GetHistoryKeyboard returns a button for every recent location, one per row, and the way back to the main menu.
+func (r *BotUIRepo) GetHistoryKeyboard(locations ...string) bot.ReplyKeyboardMarkup {
+	rows := make([][]bot.KeyboardButton, 0, len(locations)+1)
+	for _, name := range locations {
+		rows = append(rows, bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["Recent"]+name)))
+	}
+	rows = append(rows, bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["Back0"])))
+
+	return bot.NewReplyKeyboard(rows...)
 }
 
 func (r *BotUIRepo) GetBackToMainMenuKeyboard() bot.ReplyKeyboardMarkup {
 	return bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["Back0"])))
 }
 
+// GetForgetKeyboard returns the button confirming that the user wants to be forgotten and the way back.
+func (r *BotUIRepo) GetForgetKeyboard() bot.ReplyKeyboardMarkup {
+	return bot.NewReplyKeyboard(
+		bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["ForgetMe"])),
+		bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["Back0"])),
+	)
+}
+
 func (r *BotUIRepo) GetDaysOrHoursKeyboard() bot.ReplyKeyboardMarkup {
 	return bot.NewReplyKeyboard(
 		bot.NewKeyboardButtonRow(bot.NewKeyboardButton(buttonsEN["ByHours"]), bot.NewKeyboardButton(buttonsEN["ByDays"])),
@@ -76,3 +120,48 @@ func (r *BotUIRepo) GetHoursKeyboard() bot.ReplyKeyboardMarkup {
 		),
 	)
 }
+
+func (r *BotUIRepo) GetDaysOrHoursInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup {
+	return bot.NewInlineKeyboardMarkup(
+		bot.NewInlineKeyboardRow(
+			inlineButton(buttonsEN["ByHours"], types.CallbackMenu, types.MenuHours, loc),
+			inlineButton(buttonsEN["ByDays"], types.CallbackMenu, types.MenuDays, loc),
+		),
+		bot.NewInlineKeyboardRow(inlineButton(buttonsEN["Now"], types.CallbackForecast, buttonsEN["Now"], loc)),
+	)
+}
+
+func (r *BotUIRepo) GetDaysInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup {
+	return bot.NewInlineKeyboardMarkup(
+		bot.NewInlineKeyboardRow(
+			inlineButton(buttonsEN["3Days"], types.CallbackForecast, buttonsEN["3Days"], loc),
+			inlineButton(buttonsEN["5Days"], types.CallbackForecast, buttonsEN["5Days"], loc),
+			inlineButton(buttonsEN["7Days"], types.CallbackForecast, buttonsEN["7Days"], loc),
+		),
+		bot.NewInlineKeyboardRow(
+			inlineButton(buttonsEN["10Days"], types.CallbackForecast, buttonsEN["10Days"], loc),
+			inlineButton(buttonsEN["16Days"], types.CallbackForecast, buttonsEN["16Days"], loc),
+			inlineButton(buttonsEN["Back1"], types.CallbackMenu, types.MenuPeriodType, loc),
+		),
+	)
+}
+
+func (r *BotUIRepo) GetHoursInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup {
+	return bot.NewInlineKeyboardMarkup(
+		bot.NewInlineKeyboardRow(
+			inlineButton(buttonsEN["24Hours"], types.CallbackForecast, buttonsEN["24Hours"], loc),
+			inlineButton(buttonsEN["48Hours"], types.CallbackForecast, buttonsEN["48Hours"], loc),
+			inlineButton(buttonsEN["72Hours"], types.CallbackForecast, buttonsEN["72Hours"], loc),
+		),
+		bot.NewInlineKeyboardRow(
+			inlineButton(buttonsEN["96Hours"], types.CallbackForecast, buttonsEN["96Hours"], loc),
+			inlineButton(buttonsEN["120Hours"], types.CallbackForecast, buttonsEN["120Hours"], loc),
+			inlineButton(buttonsEN["Back1"], types.CallbackMenu, types.MenuPeriodType, loc),
+		),
+	)
+}
+
+func inlineButton(text, action, option string, loc *types.UserCoordinates) bot.InlineKeyboardButton {
+	data := types.CallbackData{Action: action, Option: option, Latitude: loc.Latitude, Longitude: loc.Longitude}
+	return bot.NewInlineKeyboardButtonData(text, data.String())
+}
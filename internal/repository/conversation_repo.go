@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ConversationRepo keeps the conversation state of every chat.
+type ConversationRepo struct {
+	db *sqlx.DB
+}
+
+func NewConversationRepo(db *sqlx.DB) *ConversationRepo {
+	return &ConversationRepo{db: db}
+}
+
+type conversationRow struct {
+	State   string `db:"state"`
+	History []byte `db:"history"`
+	Version int    `db:"version"`
+}
+
+const getConversationQuery = `
+	-- name: get_conversation
+	SELECT state, history, version
+	FROM conversations
+	WHERE chat_id = $1;
+	`
+
+// GetConversation returns the conversation of the chat, with no state if none is stored.
+func (r *ConversationRepo) GetConversation(ctx context.Context, chatID int64) (*types.Conversation, error) {
+	ctx, span := tracing.Start(ctx, "ConversationRepo.GetConversation")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"chat_id": chatID,
+	})
+	log.Debug("Getting the conversation state")
+
+	var row conversationRow
+	err := r.db.GetContext(ctx, &row, getConversationQuery, chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &types.Conversation{State: types.StateNone}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get conversation")
+	}
+
+	conv := &types.Conversation{State: types.ConversationState(row.State), Version: row.Version}
+	err = json.Unmarshal(row.History, &conv.History)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal conversation history")
+	}
+
+	return conv, nil
+}
+
+const saveConversationQuery = `
+	-- name: save_conversation
+	INSERT INTO conversations (chat_id, state, history, version, updated_at)
+	VALUES ($1, $2, $3, 1, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET state = EXCLUDED.state, history = EXCLUDED.history, version = conversations.version + 1, updated_at = EXCLUDED.updated_at
+	WHERE conversations.version = $4;
+	`
+
+// SaveConversation saves the conversation unless it has been saved by someone else since it was read,
+// returning types.ErrConversationChanged then.
+func (r *ConversationRepo) SaveConversation(ctx context.Context, chatID int64, conv *types.Conversation) error {
+	ctx, span := tracing.Start(ctx, "ConversationRepo.SaveConversation")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"chat_id": chatID,
+		"state":   conv.State,
+	})
+	log.Debug("Saving the conversation state")
+
+	history := conv.History
+	if history == nil {
+		history = []types.ConversationState{}
+	}
+
+	payload, err := json.Marshal(history)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal conversation history")
+	}
+
+	res, err := r.db.ExecContext(ctx, saveConversationQuery, chatID, string(conv.State), payload, conv.Version)
+	if err != nil {
+		return errors.Wrap(err, "cannot save conversation")
+	}
+
+	saved, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "cannot save conversation")
+	}
+	if saved == 0 {
+		return types.ErrConversationChanged
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"weather-or-not-bot/internal/types"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestConversationRepo_GetConversation(t *testing.T) {
+	ctx := context.Background()
+	chatID := int64(123)
+
+	expectedQuery := regexp.QuoteMeta(getConversationQuery)
+	columns := []string{"state", "history", "version"}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    *types.Conversation
+		wantErr bool
+	}{
+		{
+			"1. Error on get conversation",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. No conversation stored yet",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			&types.Conversation{State: types.StateNone},
+			false,
+		},
+		{
+			"3. Broken history",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnRows(sqlmock.NewRows(columns).AddRow("main_menu", []byte("{"), 0))
+			},
+			nil,
+			true,
+		},
+		{
+			"4. Success on get conversation",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("choosing_days", []byte(`["main_menu","choosing_period_type"]`), 2))
+			},
+			&types.Conversation{
+				State:   types.StateChoosingDays,
+				History: []types.ConversationState{types.StateMainMenu, types.StateChoosingPeriodType},
+				Version: 2,
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("ConversationRepo.GetConversation() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewConversationRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.GetConversation(ctx, chatID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConversationRepo.GetConversation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConversationRepo.GetConversation() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationRepo_SaveConversation(t *testing.T) {
+	ctx := context.Background()
+	chatID := int64(123)
+
+	expectedQuery := regexp.QuoteMeta(saveConversationQuery)
+	dbErr := errors.New("some error")
+
+	tests := []struct {
+		name    string
+		conv    *types.Conversation
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			"1. Error on save conversation",
+			&types.Conversation{State: types.StateMainMenu},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(chatID, "main_menu", []byte("[]"), 0).WillReturnError(dbErr)
+			},
+			dbErr,
+		},
+		{
+			"2. Success on save conversation",
+			&types.Conversation{State: types.StateAwaitingCity, History: []types.ConversationState{types.StateMainMenu}, Version: 4},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(chatID, "awaiting_city", []byte(`["main_menu"]`), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			nil,
+		},
+		{
+			"3. Conversation saved by someone else meanwhile",
+			&types.Conversation{State: types.StateAwaitingCity, History: []types.ConversationState{types.StateMainMenu}, Version: 4},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(chatID, "awaiting_city", []byte(`["main_menu"]`), 4).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			types.ErrConversationChanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("ConversationRepo.SaveConversation() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewConversationRepo(sqlx.NewDb(db, "postgres"))
+			if err := repo.SaveConversation(ctx, chatID, tt.conv); errors.Cause(err) != tt.wantErr {
+				t.Errorf("ConversationRepo.SaveConversation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// FavoriteRepo keeps the locations users saved under names of their own.
+type FavoriteRepo struct {
+	db *sqlx.DB
+}
+
+func NewFavoriteRepo(db *sqlx.DB) *FavoriteRepo {
+	return &FavoriteRepo{db: db}
+}
+
+const listFavoritesQuery = `
+	-- name: list_favorites
+	SELECT name, latitude, longitude
+	FROM favorites
+	WHERE user_id = $1
+	ORDER BY created_at, name;
+	`
+
+// ListFavorites returns the favorites of the user in the order they were saved.
+func (r *FavoriteRepo) ListFavorites(ctx context.Context, userID int) ([]types.Favorite, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteRepo.ListFavorites")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+	})
+	log.Debug("Listing the favorites")
+
+	var favorites []types.Favorite
+	err := r.db.SelectContext(ctx, &favorites, listFavoritesQuery, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list favorites")
+	}
+
+	return favorites, nil
+}
+
+const getFavoriteQuery = `
+	-- name: get_favorite
+	SELECT name, latitude, longitude
+	FROM favorites
+	WHERE user_id = $1 AND name = $2;
+	`
+
+func (r *FavoriteRepo) GetFavorite(ctx context.Context, userID int, name string) (*types.Favorite, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteRepo.GetFavorite")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+		"name":    name,
+	})
+	log.Debug("Getting the favorite")
+
+	favorite := &types.Favorite{}
+	err := r.db.GetContext(ctx, favorite, getFavoriteQuery, userID, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get favorite")
+	}
+
+	return favorite, nil
+}
+
+const saveFavoriteQuery = `
+	-- name: save_favorite
+	INSERT INTO favorites (user_id, name, latitude, longitude)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id, name) DO UPDATE
+	SET latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude;
+	`
+
+// SaveFavorite saves the location under the name, replacing the one saved under it before.
+func (r *FavoriteRepo) SaveFavorite(ctx context.Context, userID int, name string, loc *types.UserCoordinates) error {
+	ctx, span := tracing.Start(ctx, "FavoriteRepo.SaveFavorite")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+		"name":    name,
+	})
+	log.Debug("Saving the favorite")
+
+	_, err := r.db.ExecContext(ctx, saveFavoriteQuery, userID, name, loc.Latitude, loc.Longitude)
+	if err != nil {
+		return errors.Wrap(err, "cannot save favorite")
+	}
+
+	return nil
+}
+
+const renameFavoriteQuery = `
+	-- name: rename_favorite
+	UPDATE favorites
+	SET name = $3
+	WHERE user_id = $1 AND name = $2
+		AND NOT EXISTS (SELECT 1 FROM favorites WHERE user_id = $1 AND name = $3);
+	`
+
+// RenameFavorite tells whether the favorite is renamed, it is not if there is no such favorite or the new name is taken.
+func (r *FavoriteRepo) RenameFavorite(ctx context.Context, userID int, oldName, newName string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteRepo.RenameFavorite")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id":  userID,
+		"old_name": oldName,
+		"new_name": newName,
+	})
+	log.Debug("Renaming the favorite")
+
+	res, err := r.db.ExecContext(ctx, renameFavoriteQuery, userID, oldName, newName)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot rename favorite")
+	}
+
+	renamed, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "cannot rename favorite")
+	}
+
+	return renamed > 0, nil
+}
+
+const deleteFavoriteQuery = `
+	-- name: delete_favorite
+	DELETE FROM favorites
+	WHERE user_id = $1 AND name = $2;
+	`
+
+// DeleteFavorite tells whether the favorite is deleted, it is not if there is no such favorite.
+func (r *FavoriteRepo) DeleteFavorite(ctx context.Context, userID int, name string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteRepo.DeleteFavorite")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+		"name":    name,
+	})
+	log.Debug("Deleting the favorite")
+
+	res, err := r.db.ExecContext(ctx, deleteFavoriteQuery, userID, name)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot delete favorite")
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "cannot delete favorite")
+	}
+
+	return deleted > 0, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"weather-or-not-bot/internal/types"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestFavoriteRepo_ListFavorites(t *testing.T) {
+	ctx := context.Background()
+	userID := 122334
+
+	expectedQuery := regexp.QuoteMeta(listFavoritesQuery)
+	columns := []string{"name", "latitude", "longitude"}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    []types.Favorite
+		wantErr bool
+	}{
+		{
+			"1. Error on list favorites",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID).WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. No favorites",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			nil,
+			false,
+		},
+		{
+			"3. Success on list favorites",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("home", "52.52", "13.40").AddRow("office", "48.85", "2.35"))
+			},
+			[]types.Favorite{{Name: "home", Latitude: "52.52", Longitude: "13.40"}, {Name: "office", Latitude: "48.85", Longitude: "2.35"}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("FavoriteRepo.ListFavorites() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewFavoriteRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.ListFavorites(ctx, userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FavoriteRepo.ListFavorites() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FavoriteRepo.ListFavorites() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFavoriteRepo_RenameFavorite(t *testing.T) {
+	ctx := context.Background()
+	userID := 122334
+
+	expectedQuery := regexp.QuoteMeta(renameFavoriteQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			"1. Error on rename favorite",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(userID, "home", "flat").WillReturnError(errors.New("some error"))
+			},
+			false,
+			true,
+		},
+		{
+			"2. No such favorite or the name is taken",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(userID, "home", "flat").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			false,
+			false,
+		},
+		{
+			"3. Success on rename favorite",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(userID, "home", "flat").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("FavoriteRepo.RenameFavorite() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewFavoriteRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.RenameFavorite(ctx, userID, "home", "flat")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FavoriteRepo.RenameFavorite() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("FavoriteRepo.RenameFavorite() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,21 +2,39 @@ package repository
 
 import (
 	"context"
-	"fmt"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+	"weather-or-not-bot/internal/tracing"
 	"weather-or-not-bot/internal/types"
 )
 
+const (
+	// errorWindowSize is the number of recent forecast requests the error rate is taken over.
+	errorWindowSize = 100
+	// minErrorSamples is the number of requests needed before the error rate is trusted.
+	minErrorSamples = 10
+)
+
 type ForecastClient struct {
+	mu      sync.Mutex
+	results []bool
+	next    int
+	failed  int
 }
 
 func NewForecastClient() *ForecastClient {
-	return &ForecastClient{}
+	return &ForecastClient{results: make([]bool, 0, errorWindowSize)}
 }
 
 const (
@@ -24,55 +42,128 @@ const (
 	HostHeader = "weatherbit-v1-mashape.p.rapidapi.com"
 )
 
-func (c *ForecastClient) GetForecast(ctx context.Context, loc *types.UserCoordinates, period string) (*types.FullWeatherReport, error) {
+func (c *ForecastClient) GetForecast(ctx context.Context, loc *types.UserCoordinates, period types.Period) (*types.FullWeatherReport, error) {
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
-		"period": period,
+		"period": period.String(),
 	})
 	log.Debug("Getting forecast data from a third-party provider")
 
-	rawWR, err := c.getForecast(loc, period)
+	if err := period.Validate(); err != nil {
+		return nil, errors.Wrap(err, "cannot get forecast")
+	}
+
+	rawWR, err := c.getForecast(ctx, loc, period)
+	c.record(err != nil)
 	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues(metrics.ErrorForecast).Inc()
 		return nil, errors.Wrap(err, "cannot get forecast")
 	}
 
 	return types.ParseWeather(rawWR)
 }
-func (c *ForecastClient) getForecast(loc *types.UserCoordinates, period string) ([]byte, error) {
-	url := fmt.Sprintf(
-		"%v%vlang=%v&lat=%v&lon=%v", BaseURL, forecasts[period], viper.GetString("language"), loc.Latitude, loc.Longitude,
-	)
+func (c *ForecastClient) getForecast(ctx context.Context, loc *types.UserCoordinates, period types.Period) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "ForecastClient.getForecast", attribute.String("forecast.period", period.String()))
+	defer span.End()
+
+	lang := types.LanguageFrom(ctx)
+	if lang == "" {
+		lang = viper.GetString("language")
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	endpoint, query := endpointOf(period)
+	query.Set("lang", lang)
+	query.Set("lat", loc.Latitude)
+	query.Set("lon", loc.Longitude)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", BaseURL+endpoint+"?"+query.Encode(), nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create a new request")
 	}
 	req.Header.Add("x-rapidapi-host", HostHeader)
 	req.Header.Add("x-rapidapi-key", viper.GetString("weather_api_key"))
 
+	start := time.Now()
+
 	res, err := http.DefaultClient.Do(req)
+	metrics.ForecastDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.ForecastResponses.WithLabelValues(endpoint, metrics.OutcomeError).Inc()
+		tracing.RecordError(ctx, err)
 		return nil, errors.Wrap(err, "cannot perform request")
 	}
 	defer res.Body.Close()
+	metrics.ForecastResponses.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Inc()
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.StatusCode))
+
+	if res.StatusCode != http.StatusOK {
+		err = errors.Errorf("unexpected response status %d", res.StatusCode)
+		tracing.RecordError(ctx, err)
+		return nil, err
+	}
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
+		tracing.RecordError(ctx, err)
 		return nil, errors.Wrap(err, "cannot read from response body")
 	}
 
 	return body, nil
 }
 
-var forecasts = map[string]string{
-	"Now":       "current?",
-	"3 days":    "forecast/daily?",
-	"5 days":    "forecast/daily?",
-	"7 days":    "forecast/daily?",
-	"10 days":   "forecast/daily?",
-	"16 days":   "forecast/daily?",
-	"24 hours":  "forecast/hourly?hours=24&",
-	"48 hours":  "forecast/hourly?hours=48&",
-	"72 hours":  "forecast/hourly?hours=72&",
-	"96 hours":  "forecast/hourly?hours=96&",
-	"120 hours": "forecast/hourly?hours=120&",
+// ErrorRate returns the share of failed requests among the recent ones and their number.
+func (c *ForecastClient) ErrorRate() (float64, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.results) == 0 {
+		return 0, 0
+	}
+
+	return float64(c.failed) / float64(len(c.results)), len(c.results)
+}
+
+// CheckErrorRate tells whether the recent error rate of the provider is below forecast_max_error_rate.
+func (c *ForecastClient) CheckErrorRate(_ context.Context) error {
+	rate, samples := c.ErrorRate()
+	if samples < minErrorSamples {
+		return nil
+	}
+
+	maxRate := viper.GetFloat64("forecast_max_error_rate")
+	if rate > maxRate {
+		return errors.Errorf("%.0f%% of the last %d forecast requests failed, allowed %.0f%%", rate*100, samples, maxRate*100)
+	}
+
+	return nil
+}
+
+func (c *ForecastClient) record(failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.results) < errorWindowSize {
+		c.results = append(c.results, failed)
+	} else {
+		if c.results[c.next] {
+			c.failed--
+		}
+		c.results[c.next] = failed
+		c.next = (c.next + 1) % errorWindowSize
+	}
+
+	if failed {
+		c.failed++
+	}
+}
+
+// endpointOf returns the provider's endpoint for the period and the query parameters to ask for it with.
+func endpointOf(period types.Period) (string, url.Values) {
+	switch period.Unit {
+	case types.PeriodHours:
+		return "forecast/hourly", url.Values{"hours": {strconv.Itoa(period.Length)}}
+	case types.PeriodDays:
+		return "forecast/daily", url.Values{"days": {strconv.Itoa(period.Length)}}
+	default:
+		return "current", url.Values{}
+	}
 }
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GroupSettingsRepo keeps the defaults admins choose for their group chats.
+type GroupSettingsRepo struct {
+	db *sqlx.DB
+}
+
+func NewGroupSettingsRepo(db *sqlx.DB) *GroupSettingsRepo {
+	return &GroupSettingsRepo{db: db}
+}
+
+const getGroupSettingsQuery = `
+	-- name: get_group_settings
+	SELECT location_name, latitude, longitude, language
+	FROM group_settings
+	WHERE chat_id = $1;
+	`
+
+// GetGroupSettings returns the settings of the group, empty if none are stored.
+func (r *GroupSettingsRepo) GetGroupSettings(ctx context.Context, chatID int64) (*types.GroupSettings, error) {
+	ctx, span := tracing.Start(ctx, "GroupSettingsRepo.GetGroupSettings")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"chat_id": chatID,
+	})
+	log.Debug("Getting the group settings")
+
+	settings := &types.GroupSettings{}
+	err := r.db.GetContext(ctx, settings, getGroupSettingsQuery, chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &types.GroupSettings{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get group settings")
+	}
+
+	return settings, nil
+}
+
+const saveGroupLocationQuery = `
+	-- name: save_group_location
+	INSERT INTO group_settings (chat_id, location_name, latitude, longitude, updated_by, updated_at)
+	VALUES ($1, $2, $3, $4, $5, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET location_name = EXCLUDED.location_name, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+		updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at;
+	`
+
+func (r *GroupSettingsRepo) SaveGroupLocation(ctx context.Context, chatID int64, locationName string, loc *types.UserCoordinates, userID int) error {
+	ctx, span := tracing.Start(ctx, "GroupSettingsRepo.SaveGroupLocation")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"chat_id":       chatID,
+		"location_name": locationName,
+	})
+	log.Debug("Saving the group location")
+
+	_, err := r.db.ExecContext(ctx, saveGroupLocationQuery, chatID, locationName, loc.Latitude, loc.Longitude, userID)
+	if err != nil {
+		return errors.Wrap(err, "cannot save group location")
+	}
+
+	return nil
+}
+
+const saveGroupLanguageQuery = `
+	-- name: save_group_language
+	INSERT INTO group_settings (chat_id, language, updated_by, updated_at)
+	VALUES ($1, $2, $3, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET language = EXCLUDED.language, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at;
+	`
+
+func (r *GroupSettingsRepo) SaveGroupLanguage(ctx context.Context, chatID int64, language string, userID int) error {
+	ctx, span := tracing.Start(ctx, "GroupSettingsRepo.SaveGroupLanguage")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"chat_id":  chatID,
+		"language": language,
+	})
+	log.Debug("Saving the group language")
+
+	_, err := r.db.ExecContext(ctx, saveGroupLanguageQuery, chatID, language, userID)
+	if err != nil {
+		return errors.Wrap(err, "cannot save group language")
+	}
+
+	return nil
+}
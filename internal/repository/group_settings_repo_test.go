@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"weather-or-not-bot/internal/types"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestGroupSettingsRepo_GetGroupSettings(t *testing.T) {
+	ctx := context.Background()
+	chatID := int64(-100123)
+
+	expectedQuery := regexp.QuoteMeta(getGroupSettingsQuery)
+	columns := []string{"location_name", "latitude", "longitude", "language"}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    *types.GroupSettings
+		wantErr bool
+	}{
+		{
+			"1. Error on get group settings",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. No settings stored yet",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			&types.GroupSettings{},
+			false,
+		},
+		{
+			"3. Success on get group settings",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(chatID).WillReturnRows(sqlmock.NewRows(columns).AddRow("Lisbon", "38.7223", "-9.1393", "pt"))
+			},
+			&types.GroupSettings{LocationName: "Lisbon", Latitude: "38.7223", Longitude: "-9.1393", Language: "pt"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("GroupSettingsRepo.GetGroupSettings() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewGroupSettingsRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.GetGroupSettings(ctx, chatID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GroupSettingsRepo.GetGroupSettings() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupSettingsRepo.GetGroupSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupSettingsRepo_SaveGroupLocation(t *testing.T) {
+	ctx := context.Background()
+	chatID := int64(-100123)
+	userID := 122334
+	loc := &types.UserCoordinates{Latitude: "38.7223", Longitude: "-9.1393"}
+
+	expectedQuery := regexp.QuoteMeta(saveGroupLocationQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			"1. Error on save group location",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(chatID, "Lisbon", loc.Latitude, loc.Longitude, userID).WillReturnError(errors.New("some error"))
+			},
+			true,
+		},
+		{
+			"2. Success on save group location",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(chatID, "Lisbon", loc.Latitude, loc.Longitude, userID).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("GroupSettingsRepo.SaveGroupLocation() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewGroupSettingsRepo(sqlx.NewDb(db, "postgres"))
+			if err := repo.SaveGroupLocation(ctx, chatID, "Lisbon", loc, userID); (err != nil) != tt.wantErr {
+				t.Errorf("GroupSettingsRepo.SaveGroupLocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
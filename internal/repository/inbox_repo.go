@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+	"weather-or-not-bot/internal/tracing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// Statuses of updates in the inbox.
+const (
+	InboxPending    = "pending"
+	InboxProcessing = "processing"
+	InboxDone       = "done"
+	InboxFailed     = "failed"
+	InboxDead       = "dead"
+)
+
+// InboxRepo keeps incoming updates in Postgres until they are handled.
+// An update waiting in a queue or being handled is leased to the replica that has it,
+// and only updates whose lease has run out are claimed again.
+type InboxRepo struct {
+	db          *sqlx.DB
+	maxAttempts int
+	lease       time.Duration
+}
+
+func NewInboxRepo(db *sqlx.DB, maxAttempts int, lease time.Duration) *InboxRepo {
+	return &InboxRepo{db: db, maxAttempts: maxAttempts, lease: lease}
+}
+
+const saveToInboxQuery = `
+	-- name: save_to_inbox
+	INSERT INTO inbox (update_id, payload, lease_until)
+	VALUES ($1, $2, now() + $3 * interval '1 second')
+	ON CONFLICT (update_id) DO NOTHING;
+`
+
+func (r *InboxRepo) Save(ctx context.Context, upd bot.Update) error {
+	ctx, span := tracing.Start(ctx, "InboxRepo.Save")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": upd.UpdateID,
+	})
+	log.Debug("Saving the update to the inbox")
+
+	payload, err := json.Marshal(upd)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal update")
+	}
+
+	_, err = r.db.ExecContext(ctx, saveToInboxQuery, upd.UpdateID, payload, r.lease.Seconds())
+	if err != nil {
+		return errors.Wrap(err, "cannot save update to inbox")
+	}
+
+	return nil
+}
+
+const takeFromInboxQuery = `
+	-- name: take_from_inbox
+	WITH taken AS (
+		UPDATE inbox
+		SET status = 'processing', lease_until = now() + $2 * interval '1 second', updated_at = now()
+		WHERE update_id = $1 AND status = 'pending'
+		RETURNING update_id
+	)
+	SELECT EXISTS (SELECT 1 FROM taken) OR NOT EXISTS (SELECT 1 FROM inbox WHERE update_id = $1);
+`
+
+// Take marks the update as being handled and tells whether it is to be handled. It is not when another worker
+// has taken it already or it is finished. Updates that could not be stored are handled anyway.
+func (r *InboxRepo) Take(ctx context.Context, updateID int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "InboxRepo.Take")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": updateID,
+	})
+	log.Debug("Taking the update from the inbox")
+
+	var taken bool
+	err := r.db.GetContext(ctx, &taken, takeFromInboxQuery, updateID, r.lease.Seconds())
+	if err != nil {
+		return false, errors.Wrap(err, "cannot take update")
+	}
+
+	return taken, nil
+}
+
+const markInboxDoneQuery = `
+	-- name: mark_inbox_done
+	UPDATE inbox
+	SET status = 'done', updated_at = now()
+	WHERE update_id = $1;
+`
+
+func (r *InboxRepo) MarkDone(ctx context.Context, updateID int) error {
+	ctx, span := tracing.Start(ctx, "InboxRepo.MarkDone")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": updateID,
+	})
+	log.Debug("Marking the update as done")
+
+	_, err := r.db.ExecContext(ctx, markInboxDoneQuery, updateID)
+	if err != nil {
+		return errors.Wrap(err, "cannot mark update as done")
+	}
+
+	return nil
+}
+
+const markInboxFailedQuery = `
+	-- name: mark_inbox_failed
+	UPDATE inbox
+	SET attempts = attempts + 1,
+		last_error = $2,
+		status = CASE WHEN attempts + 1 >= $3 THEN 'dead' ELSE 'failed' END,
+		updated_at = now()
+	WHERE update_id = $1
+	RETURNING status;
+`
+
+// MarkFailed records a failed attempt and tells whether the update has been dead-lettered.
+// An update deleted meanwhile, e.g. with the user who sent it, is left deleted.
+func (r *InboxRepo) MarkFailed(ctx context.Context, updateID int, cause error) (bool, error) {
+	ctx, span := tracing.Start(ctx, "InboxRepo.MarkFailed")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": updateID,
+	})
+	log.Debug("Marking the update as failed")
+
+	var status string
+	err := r.db.GetContext(ctx, &status, markInboxFailedQuery, updateID, cause.Error(), r.maxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot mark update as failed")
+	}
+
+	return status == InboxDead, nil
+}
+
+const claimUnfinishedQuery = `
+	-- name: claim_unfinished
+	UPDATE inbox
+	SET status = 'pending', lease_until = now() + $3 * interval '1 second', updated_at = now()
+	WHERE update_id IN (
+		SELECT update_id
+		FROM inbox
+		WHERE (status = 'failed' AND updated_at < now() - $1 * interval '1 second')
+			OR (status IN ('pending', 'processing') AND lease_until < now())
+		ORDER BY update_id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING payload;
+`
+
+// ClaimUnfinished returns updates that failed longer than retryAfter ago and the unfinished ones whose lease
+// has run out, leasing them anew, so that the same update is not claimed by several replicas at once.
+func (r *InboxRepo) ClaimUnfinished(ctx context.Context, retryAfter time.Duration, limit int) ([]bot.Update, error) {
+	ctx, span := tracing.Start(ctx, "InboxRepo.ClaimUnfinished")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"retry_after": retryAfter,
+		"limit":       limit,
+	})
+	log.Debug("Claiming unfinished updates from the inbox")
+
+	var payloads [][]byte
+	err := r.db.SelectContext(ctx, &payloads, claimUnfinishedQuery, retryAfter.Seconds(), limit, r.lease.Seconds())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot claim unfinished updates")
+	}
+
+	updates := make([]bot.Update, 0, len(payloads))
+	for _, payload := range payloads {
+		var upd bot.Update
+		err = json.Unmarshal(payload, &upd)
+		if err != nil {
+			log.WithError(err).Warn("cannot unmarshal update from the inbox")
+			continue
+		}
+		updates = append(updates, upd)
+	}
+
+	return updates, nil
+}
+
+const deleteDoneQuery = `
+	-- name: delete_done
+	DELETE FROM inbox
+	WHERE status = 'done' AND updated_at < now() - $1 * interval '1 second';
+`
+
+func (r *InboxRepo) DeleteDone(ctx context.Context, olderThan time.Duration) (int64, error) {
+	ctx, span := tracing.Start(ctx, "InboxRepo.DeleteDone")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"older_than": olderThan,
+	})
+	log.Debug("Deleting handled updates from the inbox")
+
+	res, err := r.db.ExecContext(ctx, deleteDoneQuery, olderThan.Seconds())
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot delete handled updates")
+	}
+
+	return res.RowsAffected()
+}
+
+const replayDeadQuery = `
+	-- name: replay_dead
+	UPDATE inbox
+	SET status = 'failed', attempts = 0, last_error = '', updated_at = now()
+	WHERE status = 'dead';
+`
+
+// ReplayDead returns dead-lettered updates to the retry queue and tells how many there were.
+func (r *InboxRepo) ReplayDead(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "InboxRepo.ReplayDead")
+	defer span.End()
+
+	ctxlogrus.Extract(ctx).Debug("Replaying dead-lettered updates")
+
+	res, err := r.db.ExecContext(ctx, replayDeadQuery)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot replay dead updates")
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	testMaxAttempts = 3
+	testLease       = 5 * time.Minute
+)
+
+// withInboxRepo runs the test against a repo on a stub database and checks that every expected query ran.
+func withInboxRepo(t *testing.T, prepare func(mock sqlmock.Sqlmock), test func(repo *InboxRepo)) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	defer func() {
+		if expErr := mock.ExpectationsWereMet(); expErr != nil {
+			t.Errorf("InboxRepo there were unfulfilled expectations: %s", expErr)
+		}
+	}()
+
+	prepare(mock)
+	test(NewInboxRepo(sqlx.NewDb(db, "postgres"), testMaxAttempts, testLease))
+}
+
+func TestInboxRepo_Save(t *testing.T) {
+	ctx := context.Background()
+	upd := bot.Update{UpdateID: 42, Message: &bot.Message{MessageID: 1, Text: "hi"}}
+	payload, _ := json.Marshal(upd)
+
+	expectedQuery := regexp.QuoteMeta(saveToInboxQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			"1. Error on save",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(42, payload, testLease.Seconds()).WillReturnError(errors.New("some error"))
+			},
+			true,
+		},
+		{
+			"2. Success on save, leased to the replica",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(42, payload, testLease.Seconds()).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				if err := repo.Save(ctx, upd); (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.Save() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		})
+	}
+}
+
+func TestInboxRepo_Take(t *testing.T) {
+	ctx := context.Background()
+
+	expectedQuery := regexp.QuoteMeta(takeFromInboxQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			"1. Error on take",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, testLease.Seconds()).WillReturnError(errors.New("some error"))
+			},
+			false,
+			true,
+		},
+		{
+			"2. Taken or finished elsewhere",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, testLease.Seconds()).WillReturnRows(sqlmock.NewRows([]string{"taken"}).AddRow(false))
+			},
+			false,
+			false,
+		},
+		{
+			"3. Success on take",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, testLease.Seconds()).WillReturnRows(sqlmock.NewRows([]string{"taken"}).AddRow(true))
+			},
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				got, err := repo.Take(ctx, 42)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.Take() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("InboxRepo.Take() got = %v, want %v", got, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestInboxRepo_MarkDone(t *testing.T) {
+	ctx := context.Background()
+
+	expectedQuery := regexp.QuoteMeta(markInboxDoneQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			"1. Error on mark done",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(42).WillReturnError(errors.New("some error"))
+			},
+			true,
+		},
+		{
+			"2. Success on mark done",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				if err := repo.MarkDone(ctx, 42); (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.MarkDone() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		})
+	}
+}
+
+func TestInboxRepo_MarkFailed(t *testing.T) {
+	ctx := context.Background()
+	cause := errors.New("cannot send")
+
+	expectedQuery := regexp.QuoteMeta(markInboxFailedQuery)
+
+	tests := []struct {
+		name     string
+		prepare  func(mock sqlmock.Sqlmock)
+		wantDead bool
+		wantErr  bool
+	}{
+		{
+			"1. Error on mark failed",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, cause.Error(), testMaxAttempts).WillReturnError(errors.New("some error"))
+			},
+			false,
+			true,
+		},
+		{
+			"2. Attempts left",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, cause.Error(), testMaxAttempts).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(InboxFailed))
+			},
+			false,
+			false,
+		},
+		{
+			"3. Out of attempts, dead-lettered",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, cause.Error(), testMaxAttempts).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(InboxDead))
+			},
+			true,
+			false,
+		},
+		{
+			"4. Update deleted meanwhile",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(42, cause.Error(), testMaxAttempts).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}))
+			},
+			false,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				dead, err := repo.MarkFailed(ctx, 42, cause)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.MarkFailed() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if dead != tt.wantDead {
+					t.Errorf("InboxRepo.MarkFailed() dead = %v, want %v", dead, tt.wantDead)
+				}
+			})
+		})
+	}
+}
+
+func TestInboxRepo_ClaimUnfinished(t *testing.T) {
+	ctx := context.Background()
+	retryAfter := time.Minute
+	upd := bot.Update{UpdateID: 42, Message: &bot.Message{MessageID: 1, Text: "hi"}}
+	payload, _ := json.Marshal(upd)
+
+	expectedQuery := regexp.QuoteMeta(claimUnfinishedQuery)
+	args := []driver.Value{retryAfter.Seconds(), 10, testLease.Seconds()}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    []bot.Update
+		wantErr bool
+	}{
+		{
+			"1. Error on claim",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. Nothing to claim",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"payload"}))
+			},
+			[]bot.Update{},
+			false,
+		},
+		{
+			"3. Broken payloads skipped",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"payload"}).AddRow([]byte("{")).AddRow(payload))
+			},
+			[]bot.Update{upd},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				got, err := repo.ClaimUnfinished(ctx, retryAfter, 10)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.ClaimUnfinished() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("InboxRepo.ClaimUnfinished() got = %v, want %v", got, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestInboxRepo_DeleteDone(t *testing.T) {
+	ctx := context.Background()
+	olderThan := 72 * time.Hour
+
+	expectedQuery := regexp.QuoteMeta(deleteDoneQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    int64
+		wantErr bool
+	}{
+		{
+			"1. Error on delete",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(olderThan.Seconds()).WillReturnError(errors.New("some error"))
+			},
+			0,
+			true,
+		},
+		{
+			"2. Success on delete",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(olderThan.Seconds()).WillReturnResult(sqlmock.NewResult(0, 5))
+			},
+			5,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInboxRepo(t, tt.prepare, func(repo *InboxRepo) {
+				got, err := repo.DeleteDone(ctx, olderThan)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("InboxRepo.DeleteDone() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("InboxRepo.DeleteDone() got = %v, want %v", got, tt.want)
+				}
+			})
+		})
+	}
+}
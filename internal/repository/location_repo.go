@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"weather-or-not-bot/internal/tracing"
 	"weather-or-not-bot/internal/types"
 
 	bot "gopkg.in/telegram-bot-api.v4"
@@ -20,13 +21,40 @@ func NewLocationRepo(db *sqlx.DB) *LocationRepo {
 	return &LocationRepo{db: db}
 }
 
+const worldCitiesLoadedQuery = `
+	-- name: world_cities_loaded
+	SELECT EXISTS(SELECT 1 FROM world_cities);
+	`
+
+// CheckWorldCities tells whether the world_cities table is loaded.
+func (r *LocationRepo) CheckWorldCities(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "LocationRepo.CheckWorldCities")
+	defer span.End()
+
+	var loaded bool
+	err := r.db.GetContext(ctx, &loaded, worldCitiesLoadedQuery)
+	if err != nil {
+		return errors.Wrap(err, "cannot check world cities")
+	}
+
+	if !loaded {
+		return errors.New("world_cities table is empty")
+	}
+
+	return nil
+}
+
 const getCoordinatesByCityNameQuery = `
+	-- name: get_coordinates_by_city_name
 	SELECT lat, long
 	FROM world_cities
 	WHERE city_ascii = $1;
 	`
 
 func (r *LocationRepo) GetCoordinatesByCityName(ctx context.Context, locationName string) (*bot.Location, error) {
+	ctx, span := tracing.Start(ctx, "LocationRepo.GetCoordinatesByCityName")
+	defer span.End()
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"location_name": locationName,
 	})
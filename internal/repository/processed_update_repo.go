@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"weather-or-not-bot/internal/tracing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ProcessedUpdateRepo remembers claimed and handled updates in Postgres, so that replicas share the window.
+type ProcessedUpdateRepo struct {
+	db     *sqlx.DB
+	window int
+	// pruneEvery is how many update IDs pass between forgetting the ones out of the window.
+	pruneEvery int
+}
+
+func NewProcessedUpdateRepo(db *sqlx.DB, window int) *ProcessedUpdateRepo {
+	pruneEvery := window / pruneShare
+	if pruneEvery < 1 {
+		pruneEvery = 1
+	}
+
+	return &ProcessedUpdateRepo{db: db, window: window, pruneEvery: pruneEvery}
+}
+
+// pruneShare makes updates out of the window forgotten every tenth of the window.
+const pruneShare = 10
+
+const claimUpdateQuery = `
+	-- name: claim_update
+	INSERT INTO processed_updates (update_id)
+	VALUES ($1)
+	ON CONFLICT (update_id) DO NOTHING;
+`
+
+// Seen tells whether the update has been claimed already, and claims it if not. Claiming is a single insert,
+// so that of replicas getting the same update at once only one handles it.
+func (r *ProcessedUpdateRepo) Seen(ctx context.Context, updateID int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "ProcessedUpdateRepo.Seen")
+	defer span.End()
+
+	ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": updateID,
+	}).Debug("Claiming the update")
+
+	res, err := r.db.ExecContext(ctx, claimUpdateQuery, updateID)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot claim update")
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "cannot claim update")
+	}
+
+	return claimed == 0, nil
+}
+
+const forgetOldUpdatesQuery = `
+	-- name: forget_old_updates
+	DELETE FROM processed_updates
+	WHERE update_id <= $1;
+`
+
+// Mark remembers the update as handled. It is kept since it was claimed, so only update IDs further than
+// the window behind are forgotten, as IDs go in sequence. That is done once in a while rather than on every update.
+func (r *ProcessedUpdateRepo) Mark(ctx context.Context, updateID int) error {
+	ctx, span := tracing.Start(ctx, "ProcessedUpdateRepo.Mark")
+	defer span.End()
+
+	if updateID%r.pruneEvery != 0 {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx, forgetOldUpdatesQuery, updateID-r.window)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).WithFields(logrus.Fields{
+			"update_id": updateID,
+		}).Warn("cannot forget old updates")
+	}
+
+	return nil
+}
+
+const releaseUpdateQuery = `
+	-- name: release_update
+	DELETE FROM processed_updates
+	WHERE update_id = $1;
+`
+
+// Release forgets the claim of an update that is not handled, so that it can be claimed again.
+func (r *ProcessedUpdateRepo) Release(ctx context.Context, updateID int) error {
+	ctx, span := tracing.Start(ctx, "ProcessedUpdateRepo.Release")
+	defer span.End()
+
+	ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": updateID,
+	}).Debug("Releasing the update")
+
+	_, err := r.db.ExecContext(ctx, releaseUpdateQuery, updateID)
+	if err != nil {
+		return errors.Wrap(err, "cannot release update")
+	}
+
+	return nil
+}
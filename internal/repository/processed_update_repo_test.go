@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestProcessedUpdateRepo_Seen(t *testing.T) {
+	ctx := context.Background()
+
+	expectedQuery := regexp.QuoteMeta(claimUpdateQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			"1. Error on claim",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(1005).WillReturnError(errors.New("some error"))
+			},
+			false,
+			true,
+		},
+		{
+			"2. Claimed",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(1005).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+			false,
+		},
+		{
+			"3. Claimed already",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(1005).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("ProcessedUpdateRepo.Seen() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewProcessedUpdateRepo(sqlx.NewDb(db, "postgres"), 100)
+			got, err := repo.Seen(ctx, 1005)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProcessedUpdateRepo.Seen() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ProcessedUpdateRepo.Seen() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessedUpdateRepo_Mark(t *testing.T) {
+	ctx := context.Background()
+
+	forgetQuery := regexp.QuoteMeta(forgetOldUpdatesQuery)
+
+	tests := []struct {
+		name     string
+		updateID int
+		prepare  func(mock sqlmock.Sqlmock)
+		wantErr  bool
+	}{
+		{
+			"1. Marked, old updates left for later",
+			1005,
+			func(mock sqlmock.Sqlmock) {},
+			false,
+		},
+		{
+			"2. Marked and old updates forgotten every tenth of the window",
+			1010,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(forgetQuery).WithArgs(910).WillReturnResult(sqlmock.NewResult(0, 10))
+			},
+			false,
+		},
+		{
+			"3. Error on forgetting old updates is not fatal",
+			1010,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(forgetQuery).WithArgs(910).WillReturnError(errors.New("some error"))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("ProcessedUpdateRepo.Mark() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewProcessedUpdateRepo(sqlx.NewDb(db, "postgres"), 100)
+			if err := repo.Mark(ctx, tt.updateID); (err != nil) != tt.wantErr {
+				t.Errorf("ProcessedUpdateRepo.Mark() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessedUpdateRepo_Release(t *testing.T) {
+	ctx := context.Background()
+
+	expectedQuery := regexp.QuoteMeta(releaseUpdateQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			"1. Error on release",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(1005).WillReturnError(errors.New("some error"))
+			},
+			true,
+		},
+		{
+			"2. Released",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(1005).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("ProcessedUpdateRepo.Release() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewProcessedUpdateRepo(sqlx.NewDb(db, "postgres"), 100)
+			if err := repo.Release(ctx, 1005); (err != nil) != tt.wantErr {
+				t.Errorf("ProcessedUpdateRepo.Release() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"weather-or-not-bot/internal/tracing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type UpdateOffsetRepo struct {
+	db *sqlx.DB
+}
+
+func NewUpdateOffsetRepo(db *sqlx.DB) *UpdateOffsetRepo {
+	return &UpdateOffsetRepo{db: db}
+}
+
+const getUpdateOffsetQuery = `
+	-- name: get_update_offset
+	SELECT update_offset
+	FROM update_offsets
+	WHERE bot_id = $1;
+`
+
+// GetUpdateOffset returns the offset to resume polling from, zero if none has been saved yet.
+func (r *UpdateOffsetRepo) GetUpdateOffset(ctx context.Context, botID int) (int, error) {
+	ctx, span := tracing.Start(ctx, "UpdateOffsetRepo.GetUpdateOffset")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"bot_id": botID,
+	})
+	log.Debug("Getting the stored update offset")
+
+	var offset int
+	err := r.db.GetContext(ctx, &offset, getUpdateOffsetQuery, botID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Debug("No update offset stored yet")
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot get update offset")
+	}
+
+	return offset, nil
+}
+
+const saveUpdateOffsetQuery = `
+	-- name: save_update_offset
+	INSERT INTO update_offsets (bot_id, update_offset)
+	VALUES ($1, $2)
+	ON CONFLICT (bot_id) DO UPDATE SET update_offset = EXCLUDED.update_offset;
+`
+
+// SaveUpdateOffset stores the offset of the next update to be requested.
+func (r *UpdateOffsetRepo) SaveUpdateOffset(ctx context.Context, botID int, offset int) error {
+	ctx, span := tracing.Start(ctx, "UpdateOffsetRepo.SaveUpdateOffset")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"bot_id": botID,
+		"offset": offset,
+	})
+	log.Debug("Saving the update offset")
+
+	_, err := r.db.ExecContext(ctx, saveUpdateOffsetQuery, botID, offset)
+	if err != nil {
+		return errors.Wrap(err, "cannot save update offset")
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestUpdateOffsetRepo_GetUpdateOffset(t *testing.T) {
+	ctx := context.Background()
+	botID := 4242
+
+	expectedQuery := regexp.QuoteMeta(getUpdateOffsetQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			"1. Error on get offset",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(botID).WillReturnError(errors.New("some error"))
+			},
+			0,
+			true,
+		},
+		{
+			"2. No offset stored yet",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(botID).WillReturnRows(sqlmock.NewRows([]string{"update_offset"}))
+			},
+			0,
+			false,
+		},
+		{
+			"3. Success on get offset",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(botID).WillReturnRows(sqlmock.NewRows([]string{"update_offset"}).AddRow(1001))
+			},
+			1001,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("UpdateOffsetRepo.GetUpdateOffset() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewUpdateOffsetRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.GetUpdateOffset(ctx, botID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateOffsetRepo.GetUpdateOffset() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UpdateOffsetRepo.GetUpdateOffset() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateOffsetRepo_SaveUpdateOffset(t *testing.T) {
+	ctx := context.Background()
+	botID := 4242
+
+	expectedQuery := regexp.QuoteMeta(saveUpdateOffsetQuery)
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			"1. Error on save offset",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(botID, 1002).WillReturnError(errors.New("some error"))
+			},
+			true,
+		},
+		{
+			"2. Success on save offset",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(expectedQuery).WithArgs(botID, 1002).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("UpdateOffsetRepo.SaveUpdateOffset() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewUpdateOffsetRepo(sqlx.NewDb(db, "postgres"))
+			if err := repo.SaveUpdateOffset(ctx, botID, 1002); (err != nil) != tt.wantErr {
+				t.Errorf("UpdateOffsetRepo.SaveUpdateOffset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	bot "gopkg.in/telegram-bot-api.v4"
+	"weather-or-not-bot/internal/tracing"
 	"weather-or-not-bot/internal/types"
 )
 
@@ -20,11 +21,15 @@ func NewUserLocationRepo(db *sqlx.DB) *UserLocationRepo {
 }
 
 const addUserLocationByCoordinatesQuery = `
+	-- name: add_user_location_by_coordinates
 	INSERT INTO locations (user_id, latitude, longitude)
 		VALUES ($1, $2, $3);
 `
 
 func (r *UserLocationRepo) AddUserLocationByCoordinates(ctx context.Context, userID int, loc *bot.Location) error {
+	ctx, span := tracing.Start(ctx, "UserLocationRepo.AddUserLocationByCoordinates")
+	defer span.End()
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"user_id": userID,
 		"lat":     loc.Latitude,
@@ -46,6 +51,7 @@ func (r *UserLocationRepo) AddUserLocationByCoordinates(ctx context.Context, use
 }
 
 const getUserRecentLocationQuery = `
+	-- name: get_user_recent_location
 	SELECT id, latitude, longitude
 	FROM locations
 	WHERE user_id = $1
@@ -54,6 +60,9 @@ const getUserRecentLocationQuery = `
 `
 
 func (r *UserLocationRepo) GetUserRecentLocation(ctx context.Context, userID int) (*types.UserCoordinates, error) {
+	ctx, span := tracing.Start(ctx, "UserLocationRepo.GetUserRecentLocation")
+	defer span.End()
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"user_id": userID,
 	})
@@ -69,12 +78,16 @@ func (r *UserLocationRepo) GetUserRecentLocation(ctx context.Context, userID int
 }
 
 const saveLocationNameQuery = `
+	-- name: save_location_name
 	UPDATE locations
 	SET location_name = $1
 	WHERE id = $2;
 `
 
 func (r *UserLocationRepo) SaveUserLocationName(ctx context.Context, userID int, locationName string) error {
+	ctx, span := tracing.Start(ctx, "UserLocationRepo.SaveUserLocationName")
+	defer span.End()
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"user_id":       userID,
 		"location_name": locationName,
@@ -95,3 +108,65 @@ func (r *UserLocationRepo) SaveUserLocationName(ctx context.Context, userID int,
 	log.Debug("successfully saved the location name")
 	return nil
 }
+
+const getUserLocationHistoryQuery = `
+	-- name: get_user_location_history
+	SELECT location_name, latitude, longitude
+	FROM (
+		SELECT DISTINCT ON (location_name) id, location_name, latitude, longitude
+		FROM locations
+		WHERE user_id = $1 AND location_name <> ''
+		ORDER BY location_name, id DESC
+	) AS named
+	ORDER BY id DESC
+	LIMIT $2;
+`
+
+// GetUserLocationHistory returns up to limit distinct named locations of the user, the most recent first.
+func (r *UserLocationRepo) GetUserLocationHistory(ctx context.Context, userID int, limit int) ([]types.RecentLocation, error) {
+	ctx, span := tracing.Start(ctx, "UserLocationRepo.GetUserLocationHistory")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+		"limit":   limit,
+	})
+	log.Debug("getting user's location history from db")
+
+	var history []types.RecentLocation
+	err := r.db.SelectContext(ctx, &history, getUserLocationHistoryQuery, userID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get user's location history")
+	}
+
+	return history, nil
+}
+
+const getUserLocationByNameQuery = `
+	-- name: get_user_location_by_name
+	SELECT location_name, latitude, longitude
+	FROM locations
+	WHERE user_id = $1 AND location_name = $2
+	ORDER BY id DESC
+	LIMIT 1;
+`
+
+// GetUserLocationByName returns the latest location of the user with the given name.
+func (r *UserLocationRepo) GetUserLocationByName(ctx context.Context, userID int, locationName string) (*types.RecentLocation, error) {
+	ctx, span := tracing.Start(ctx, "UserLocationRepo.GetUserLocationByName")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id":       userID,
+		"location_name": locationName,
+	})
+	log.Debug("getting user's location by name from db")
+
+	location := &types.RecentLocation{}
+	err := r.db.GetContext(ctx, location, getUserLocationByNameQuery, userID, locationName)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get user's location by name")
+	}
+
+	return location, nil
+}
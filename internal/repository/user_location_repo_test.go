@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"weather-or-not-bot/internal/types"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestUserLocationRepo_GetUserLocationHistory(t *testing.T) {
+	ctx := context.Background()
+	userID := 122334
+	limit := 5
+
+	expectedQuery := regexp.QuoteMeta(getUserLocationHistoryQuery)
+	columns := []string{"location_name", "latitude", "longitude"}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    []types.RecentLocation
+		wantErr bool
+	}{
+		{
+			"1. Error on get location history",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID, limit).WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. No named locations",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID, limit).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			nil,
+			false,
+		},
+		{
+			"3. Success on get location history",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(expectedQuery).WithArgs(userID, limit).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("Paris", "48.85", "2.35").AddRow("Berlin", "52.52", "13.40"))
+			},
+			[]types.RecentLocation{{Name: "Paris", Latitude: "48.85", Longitude: "2.35"}, {Name: "Berlin", Latitude: "52.52", Longitude: "13.40"}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("UserLocationRepo.GetUserLocationHistory() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewUserLocationRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.GetUserLocationHistory(ctx, userID, limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserLocationRepo.GetUserLocationHistory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UserLocationRepo.GetUserLocationHistory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
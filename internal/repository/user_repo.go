@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/jmoiron/sqlx"
@@ -20,12 +22,16 @@ func NewUserDataRepo(db *sqlx.DB) *UserDataRepo {
 }
 
 const addUserIfNotExistsQuery = `
+	-- name: add_user_if_not_exists
 	INSERT INTO users (user_id, username, first_name, last_name, language_code, is_bot)
 	VALUES ($1, $2, $3, $4, $5, $6)
 	ON CONFLICT (user_id) DO NOTHING;
 `
 
 func (r UserDataRepo) AddUserIfNotExists(ctx context.Context, user *bot.User) error {
+	ctx, span := tracing.Start(ctx, "UserDataRepo.AddUserIfNotExists")
+	defer span.End()
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"username": user.UserName,
 		"user_id":  user.ID,
@@ -44,3 +50,98 @@ func (r UserDataRepo) AddUserIfNotExists(ctx context.Context, user *bot.User) er
 
 	return nil
 }
+
+const (
+	forgetUserProfileQuery = `
+	-- name: forget_user_profile
+	DELETE FROM users
+	WHERE user_id = $1;
+	`
+	forgetUserLocationsQuery = `
+	-- name: forget_user_locations
+	DELETE FROM locations
+	WHERE user_id = $1;
+	`
+	forgetUserFavoritesQuery = `
+	-- name: forget_user_favorites
+	DELETE FROM favorites
+	WHERE user_id = $1;
+	`
+	forgetUserConversationQuery = `
+	-- name: forget_user_conversation
+	DELETE FROM conversations
+	WHERE chat_id = $1;
+	`
+	forgetUserGroupsQuery = `
+	-- name: forget_user_groups
+	UPDATE group_settings
+	SET updated_by = 0
+	WHERE updated_by = $1;
+	`
+	forgetUserUpdatesQuery = `
+	-- name: forget_user_updates
+	DELETE FROM inbox
+	WHERE $1::text IN (
+		payload #>> '{message,from,id}',
+		payload #>> '{edited_message,from,id}',
+		payload #>> '{channel_post,from,id}',
+		payload #>> '{edited_channel_post,from,id}',
+		payload #>> '{callback_query,from,id}',
+		payload #>> '{inline_query,from,id}',
+		payload #>> '{chosen_inline_result,from,id}'
+	);
+	`
+)
+
+// ForgetUser deletes everything kept about the user in one transaction: the profile, the locations, the favorites
+// and the conversation of the private chat. Group settings stay with the group, only the user's ID is erased from them.
+// Stored updates sent by the user are deleted whatever their status: the ones being handled are not marked again,
+// and the ones waiting for a retry are never retried.
+func (r UserDataRepo) ForgetUser(ctx context.Context, userID int) (*types.ForgottenUser, error) {
+	ctx, span := tracing.Start(ctx, "UserDataRepo.ForgetUser")
+	defer span.End()
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+	})
+	log.Debug("Forgetting user")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot begin forgetting user")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	forgotten := &types.ForgottenUser{}
+	steps := []struct {
+		query string
+		rows  *int64
+	}{
+		{forgetUserProfileQuery, &forgotten.Profiles},
+		{forgetUserLocationsQuery, &forgotten.Locations},
+		{forgetUserFavoritesQuery, &forgotten.Favorites},
+		{forgetUserConversationQuery, &forgotten.Conversations},
+		{forgetUserGroupsQuery, &forgotten.Groups},
+		{forgetUserUpdatesQuery, &forgotten.Updates},
+	}
+	for _, step := range steps {
+		res, err := tx.ExecContext(ctx, step.query, userID)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot forget user")
+		}
+
+		*step.rows, err = res.RowsAffected()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot count forgotten rows")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot commit forgetting user")
+	}
+
+	log.Infof("Forgot %d rows of the user", forgotten.Total())
+
+	return forgotten, nil
+}
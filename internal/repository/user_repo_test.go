@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"reflect"
 	"testing"
+	"weather-or-not-bot/internal/types"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
@@ -86,3 +88,96 @@ func TestUserDataRepo_AddUserIfNotExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserDataRepo_ForgetUser(t *testing.T) {
+	ctx := context.Background()
+	userID := 123
+
+	queries := []string{
+		`DELETE FROM users WHERE user_id = \$1`,
+		`DELETE FROM locations WHERE user_id = \$1`,
+		`DELETE FROM favorites WHERE user_id = \$1`,
+		`DELETE FROM conversations WHERE chat_id = \$1`,
+		`UPDATE group_settings SET updated_by = 0 WHERE updated_by = \$1`,
+		`DELETE FROM inbox WHERE \$1::text IN \( payload #>> '{message,from,id}', payload #>> '{edited_message,from,id}'`,
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(mock sqlmock.Sqlmock)
+		want    *types.ForgottenUser
+		wantErr bool
+	}{
+		{
+			"1. Error on begin",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"2. Error on deleting favorites, rolled back",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(queries[0]).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queries[1]).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 12))
+				mock.ExpectExec(queries[2]).WithArgs(userID).WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			nil,
+			true,
+		},
+		{
+			"3. Error on commit",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				for _, q := range queries {
+					mock.ExpectExec(q).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit().WillReturnError(errors.New("some error"))
+			},
+			nil,
+			true,
+		},
+		{
+			"4. Success on forgetting user",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				for i, q := range queries {
+					mock.ExpectExec(q).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, int64(i)))
+				}
+				mock.ExpectCommit()
+			},
+			&types.ForgottenUser{Profiles: 0, Locations: 1, Favorites: 2, Conversations: 3, Groups: 4, Updates: 5},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			defer func() {
+				if expErr := mock.ExpectationsWereMet(); expErr != nil {
+					t.Errorf("UserDataRepo.ForgetUser() there were unfulfilled expectations: %s", expErr)
+				}
+			}()
+
+			tt.prepare(mock)
+
+			repo := NewUserDataRepo(sqlx.NewDb(db, "postgres"))
+			got, err := repo.ForgetUser(ctx, userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserDataRepo.ForgetUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UserDataRepo.ForgetUser() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
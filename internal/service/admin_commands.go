@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const Replay = "/replay"
+
+// PurgeCmd deletes everything kept about the user with the given ID, as /forget does for oneself.
+const PurgeCmd = "purge"
+
+// WithAdmins allows users with given IDs to run admin commands.
+func (s *MessageService) WithAdmins(userIDs []int) *MessageService {
+	s.admins = make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		s.admins[id] = true
+	}
+
+	return s
+}
+
+// WithInbox lets admins replay dead-lettered updates.
+func (s *MessageService) WithInbox(inbox InboxRepo) *MessageService {
+	s.inbox = inbox
+	return s
+}
+
+func (s *MessageService) isAdmin(userID int) bool {
+	return s.admins[userID]
+}
+
+// refuse answers a user who is not an admin as if there were no such command.
+// Nothing is looked up, so the command is never taken for a location.
+func (s *MessageService) refuse(ctx context.Context, req *bot.Message) error {
+	resp := bot.NewMessage(req.Chat.ID, commentsEn["Unknown"])
+	resp.ReplyMarkup = s.botRepo.GetMainMenuKeyboard()
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+func (s *MessageService) handleReplay(ctx context.Context, req *bot.Message) error {
+	log := ctxlogrus.Extract(ctx)
+	log.Debugf("Handling '%s'", req.Text)
+
+	if !s.isAdmin(req.From.ID) {
+		log.Warnf("User %d is not allowed to replay updates", req.From.ID)
+		return s.refuse(ctx, req)
+	}
+
+	text := commentsEn["InboxOff"]
+	if s.inbox != nil {
+		replayed, err := s.inbox.ReplayDead(ctx)
+		if err != nil {
+			return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+		}
+		text = fmt.Sprintf(commentsEn["Replayed"], replayed)
+	}
+
+	_, err := s.send(ctx, bot.NewMessage(req.Chat.ID, text))
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+func (s *MessageService) handlePurge(ctx context.Context, req *bot.Message) error {
+	log := ctxlogrus.Extract(ctx)
+	log.Debugf("Handling '%s'", req.Text)
+
+	if !s.isAdmin(req.From.ID) {
+		log.Warnf("User %d is not allowed to purge users", req.From.ID)
+		return s.refuse(ctx, req)
+	}
+
+	userID, err := strconv.Atoi(req.CommandArguments())
+	if err != nil || userID <= 0 {
+		return s.reply(ctx, req, commentsEn["UsagePurge"])
+	}
+
+	forgotten, err := s.usrRepo.ForgetUser(ctx, userID)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+	log.Infof("User %d purged by admin %d", userID, req.From.ID)
+
+	return s.reply(ctx, req, fmt.Sprintf(commentsEn["Purged"], userID, forgottenSummary(forgotten)))
+}
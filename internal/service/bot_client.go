@@ -1,9 +1,28 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
 	bot "gopkg.in/telegram-bot-api.v4"
 )
 
+const (
+	// pollingRetryDelay is a pause before the next getUpdates call after a failed one.
+	pollingRetryDelay = 3 * time.Second
+	// webhookErrorWindow is how long a failed webhook delivery keeps the bot unready.
+	webhookErrorWindow = 5 * time.Minute
+)
+
 type BotCmd struct {
 	cmd *bot.BotAPI
 }
@@ -16,6 +35,220 @@ func (c BotCmd) Send(msg bot.MessageConfig) (bot.Message, error) {
 	return c.cmd.Send(msg)
 }
 
+func (c BotCmd) Edit(msg bot.EditMessageTextConfig) (bot.Message, error) {
+	return c.cmd.Send(msg)
+}
+
+func (c BotCmd) AnswerCallback(cb bot.CallbackConfig) error {
+	_, err := c.cmd.AnswerCallbackQuery(cb)
+	return err
+}
+
+func (c BotCmd) AnswerInlineQuery(answer bot.InlineConfig) error {
+	_, err := c.cmd.AnswerInlineQuery(answer)
+	return err
+}
+
+func (c BotCmd) GetChatMember(member bot.ChatConfigWithUser) (bot.ChatMember, error) {
+	return c.cmd.GetChatMember(member)
+}
+
+// Self returns the user the bot is authorized as.
+func (c BotCmd) Self() bot.User {
+	return c.cmd.Self
+}
+
 func (c BotCmd) ListenForWebhook(webhook string) bot.UpdatesChannel {
 	return c.cmd.ListenForWebhook(webhook)
 }
+
+// SetMyCommands sets the command menu shown in chats of the scope to users with the language,
+// or to everyone else if languageCode is empty.
+func (c BotCmd) SetMyCommands(ctx context.Context, commands []types.BotCommand, scope types.BotCommandScope, languageCode string) error {
+	rawCommands, err := json.Marshal(commands)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal commands")
+	}
+
+	rawScope, err := json.Marshal(scope)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal command scope")
+	}
+
+	params := url.Values{}
+	params.Set("commands", string(rawCommands))
+	params.Set("scope", string(rawScope))
+	if languageCode != "" {
+		params.Set("language_code", languageCode)
+	}
+
+	_, err = c.makeRequest(ctx, "setMyCommands", params)
+	if err != nil {
+		return errors.Wrap(err, "cannot set commands")
+	}
+
+	return nil
+}
+
+// makeRequest calls the Bot API method like the library does, but gives up once ctx is done.
+func (c BotCmd) makeRequest(ctx context.Context, method string, params url.Values) (bot.APIResponse, error) {
+	endpoint := fmt.Sprintf(bot.APIEndpoint, c.cmd.Token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return bot.APIResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.cmd.Client.Do(req)
+	if err != nil {
+		return bot.APIResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp bot.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return apiResp, err
+	}
+
+	if !apiResp.Ok {
+		var parameters bot.ResponseParameters
+		if apiResp.Parameters != nil {
+			parameters = *apiResp.Parameters
+		}
+		return apiResp, bot.Error{Message: apiResp.Description, ResponseParameters: parameters}
+	}
+
+	return apiResp, nil
+}
+
+// CheckWebhook tells whether Telegram delivers updates the way we expect: to wantURL,
+// or with no webhook at all if wantURL is empty, and without recent delivery errors.
+// The webhook and its errors are shared by every instance of the bot.
+func (c BotCmd) CheckWebhook(ctx context.Context, wantURL string) error {
+	resp, err := c.makeRequest(ctx, "getWebhookInfo", url.Values{})
+	if err != nil {
+		return errors.Wrap(err, "cannot get webhook info")
+	}
+
+	var info bot.WebhookInfo
+	if err := json.Unmarshal(resp.Result, &info); err != nil {
+		return errors.Wrap(err, "cannot unmarshal webhook info")
+	}
+
+	if info.URL != wantURL {
+		return errors.Errorf("webhook is set to '%s', want '%s'", info.URL, wantURL)
+	}
+
+	lastError := time.Unix(int64(info.LastErrorDate), 0)
+	if info.LastErrorMessage != "" && time.Since(lastError) < webhookErrorWindow {
+		return errors.Errorf("webhook delivery failed at %s: %s", lastError.Format(time.RFC3339), info.LastErrorMessage)
+	}
+
+	return nil
+}
+
+// ListenForPolling starts long polling for updates, resuming from the offset stored in offsets.
+// Polled updates are put into store first if it is not nil. The channel is closed once ctx is done.
+func (c BotCmd) ListenForPolling(ctx context.Context, offsets UpdateOffsetRepo, store UpdateStore, timeout int) (bot.UpdatesChannel, error) {
+	offset, err := offsets.GetUpdateOffset(ctx, c.cmd.Self.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot start polling")
+	}
+
+	cfg := bot.NewUpdate(offset)
+	cfg.Timeout = timeout
+
+	ch := make(chan bot.Update, c.cmd.Buffer)
+	go c.poll(ctx, offsets, store, cfg, ch)
+
+	return ch, nil
+}
+
+// poll requests updates until ctx is done. Telegram forgets an update once it is asked for the ones after it,
+// so the offset is moved past an update only when it is stored, or passed on if there is no store.
+// Without a store an update passed on but not handled before a crash is lost.
+func (c BotCmd) poll(ctx context.Context, offsets UpdateOffsetRepo, store UpdateStore, cfg bot.UpdateConfig, ch chan<- bot.Update) {
+	log := ctxlogrus.Extract(ctx)
+	log.Infof("Polling for updates starting from offset %d", cfg.Offset)
+	defer close(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Stopped polling for updates")
+			return
+		default:
+		}
+
+		updates, err := c.getUpdates(ctx, cfg)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Warnf("Cannot get updates, retrying in %v", pollingRetryDelay)
+			pause(ctx, pollingRetryDelay)
+		}
+
+		for _, update := range updates {
+			if update.UpdateID < cfg.Offset {
+				continue
+			}
+
+			if store != nil {
+				err = store.Save(ctx, update)
+				if err != nil {
+					log.WithError(err).Warnf("Cannot store update %d, getting it again in %v", update.UpdateID, pollingRetryDelay)
+					pause(ctx, pollingRetryDelay)
+					break
+				}
+			}
+
+			select {
+			case ch <- update:
+			case <-ctx.Done():
+				return
+			}
+
+			cfg.Offset = update.UpdateID + 1
+			err = offsets.SaveUpdateOffset(ctx, c.cmd.Self.ID, cfg.Offset)
+			if err != nil {
+				log.WithError(err).Warnf("cannot persist update offset %d", cfg.Offset)
+			}
+		}
+	}
+}
+
+// getUpdates requests updates starting from the offset, giving up once ctx is done.
+func (c BotCmd) getUpdates(ctx context.Context, cfg bot.UpdateConfig) ([]bot.Update, error) {
+	params := url.Values{}
+	if cfg.Offset != 0 {
+		params.Set("offset", strconv.Itoa(cfg.Offset))
+	}
+	if cfg.Limit > 0 {
+		params.Set("limit", strconv.Itoa(cfg.Limit))
+	}
+	if cfg.Timeout > 0 {
+		params.Set("timeout", strconv.Itoa(cfg.Timeout))
+	}
+
+	resp, err := c.makeRequest(ctx, "getUpdates", params)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get updates")
+	}
+
+	var updates []bot.Update
+	err = json.Unmarshal(resp.Result, &updates)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal updates")
+	}
+
+	return updates, nil
+}
+
+// pause waits for the delay or until ctx is done.
+func pause(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
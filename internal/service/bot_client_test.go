@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"weather-or-not-bot/internal/fakebotapi"
+	"weather-or-not-bot/internal/service/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestBotCmd_CheckWebhook(t *testing.T) {
+	hook := "https://example.org/hook"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantURL string
+		wantErr bool
+	}{
+		{"1. Webhook set where expected", context.Background(), hook, false},
+		{"2. Webhook set elsewhere", context.Background(), "https://example.org/other", true},
+		{"3. Polling expected while the webhook is set", context.Background(), "", true},
+		{"4. Caller gave up", cancelled, hook, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakebotapi.NewServer("123:fake_token")
+			defer fake.Close()
+
+			api, err := fake.NewBotAPI()
+			if err != nil {
+				t.Fatalf("cannot create bot API: %v", err)
+			}
+			if _, err := api.SetWebhook(bot.NewWebhook(hook)); err != nil {
+				t.Fatalf("cannot set webhook: %v", err)
+			}
+
+			if err := NewBotCmd(api).CheckWebhook(tt.ctx, tt.wantURL); (err != nil) != tt.wantErr {
+				t.Errorf("CheckWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBotCmd_ListenForPolling(t *testing.T) {
+	botID := 1
+	chat := &bot.Chat{ID: 555, Type: "private"}
+	user := &bot.User{ID: 777, FirstName: "John"}
+
+	type mocks struct {
+		offsets *mock.MockUpdateOffsetRepo
+		store   *mock.MockUpdateStore
+	}
+
+	tests := []struct {
+		name      string
+		prepare   func(m mocks)
+		withStore bool
+		wantIDs   []int
+	}{
+		{
+			name: "1. Resumed from the stored offset, moved past every update passed on",
+			prepare: func(m mocks) {
+				m.offsets.EXPECT().GetUpdateOffset(gomock.Any(), botID).Return(2, nil)
+				gomock.InOrder(
+					m.offsets.EXPECT().SaveUpdateOffset(gomock.Any(), botID, 3).Return(nil),
+					m.offsets.EXPECT().SaveUpdateOffset(gomock.Any(), botID, 4).Return(nil),
+				)
+			},
+			withStore: false,
+			wantIDs:   []int{2, 3},
+		},
+		{
+			name: "2. Updates stored before the offset is moved past them",
+			prepare: func(m mocks) {
+				m.offsets.EXPECT().GetUpdateOffset(gomock.Any(), botID).Return(2, nil)
+				gomock.InOrder(
+					m.store.EXPECT().Save(gomock.Any(), updateWithID(2)).Return(nil),
+					m.offsets.EXPECT().SaveUpdateOffset(gomock.Any(), botID, 3).Return(nil),
+					m.store.EXPECT().Save(gomock.Any(), updateWithID(3)).Return(nil),
+					m.offsets.EXPECT().SaveUpdateOffset(gomock.Any(), botID, 4).Return(nil),
+				)
+			},
+			withStore: true,
+			wantIDs:   []int{2, 3},
+		},
+		{
+			name: "3. Update not stored is neither passed on nor confirmed",
+			prepare: func(m mocks) {
+				m.offsets.EXPECT().GetUpdateOffset(gomock.Any(), botID).Return(2, nil)
+				m.store.EXPECT().Save(gomock.Any(), updateWithID(2)).Return(errors.New("some error"))
+			},
+			withStore: true,
+			wantIDs:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fake := fakebotapi.NewServer("123:fake_token")
+			defer fake.Close()
+
+			api, err := fake.NewBotAPI()
+			if err != nil {
+				t.Fatalf("cannot create bot API: %v", err)
+			}
+			for i := 1; i <= 3; i++ {
+				fake.PushUpdate(bot.Update{Message: &bot.Message{MessageID: i, From: user, Chat: chat, Text: "Berlin"}})
+			}
+
+			m := mocks{offsets: mock.NewMockUpdateOffsetRepo(ctrl), store: mock.NewMockUpdateStore(ctrl)}
+			tt.prepare(m)
+
+			var store UpdateStore
+			if tt.withStore {
+				store = m.store
+			}
+
+			updates, err := NewBotCmd(api).ListenForPolling(ctx, m.offsets, store, 60)
+			if err != nil {
+				t.Fatalf("ListenForPolling() unexpected error = %v", err)
+			}
+
+			var gotIDs []int
+			for len(gotIDs) < len(tt.wantIDs) {
+				select {
+				case upd := <-updates:
+					gotIDs = append(gotIDs, upd.UpdateID)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for updates, got %v", gotIDs)
+				}
+			}
+			if len(tt.wantIDs) == 0 {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			// Long polling is cut short, not waited out, once ctx is done.
+			cancel()
+			deadline := time.After(500 * time.Millisecond)
+			for closed := false; !closed; {
+				select {
+				case upd, ok := <-updates:
+					if ok {
+						gotIDs = append(gotIDs, upd.UpdateID)
+					}
+					closed = !ok
+				case <-deadline:
+					t.Fatal("polling has not stopped once ctx is done")
+				}
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("ListenForPolling() passed on updates %v, want %v", gotIDs, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// updateWithID matches an update by its ID.
+type updateWithID int
+
+func (id updateWithID) Matches(x interface{}) bool {
+	upd, ok := x.(bot.Update)
+	return ok && upd.UpdateID == int(id)
+}
+
+func (id updateWithID) String() string {
+	return "is the update " + strconv.Itoa(int(id))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	pflag.Duration("forecast_cache_ttl", 10*time.Minute, "How long forecasts are reused for the same place and period, 0 for no caching")
+}
+
+type cachedForecast struct {
+	report  *types.FullWeatherReport
+	expires time.Time
+}
+
+// CachedForecastClient reuses forecasts got by the wrapped ForecastClient for the same place and period
+// within the TTL, so that repeated questions don't spend the provider's quota. Failures are not cached.
+type CachedForecastClient struct {
+	ForecastClient
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedForecast
+}
+
+func NewCachedForecastClient(next ForecastClient, ttl time.Duration) *CachedForecastClient {
+	return &CachedForecastClient{ForecastClient: next, ttl: ttl, now: time.Now, entries: map[string]cachedForecast{}}
+}
+
+// GetForecast returns the cached forecast if it is fresh enough, otherwise gets and caches a new one.
+func (c *CachedForecastClient) GetForecast(ctx context.Context, loc *types.UserCoordinates, period types.Period) (*types.FullWeatherReport, error) {
+	key := loc.Latitude + "|" + loc.Longitude + "|" + period.String() + "|" + types.LanguageFrom(ctx)
+
+	if report, ok := c.get(key); ok {
+		ctxlogrus.Extract(ctx).Debugf("Using the cached '%s' forecast", period)
+		return report, nil
+	}
+
+	report, err := c.ForecastClient.GetForecast(ctx, loc, period)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, report)
+
+	return report, nil
+}
+
+func (c *CachedForecastClient) get(key string) (*types.FullWeatherReport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || c.now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.report, true
+}
+
+// put caches the report and forgets the expired ones.
+func (c *CachedForecastClient) put(key string, report *types.FullWeatherReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = cachedForecast{report: report, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+func TestCachedForecastClient_GetForecast(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	berlin := &types.UserCoordinates{Latitude: "52.520000", Longitude: "13.400000"}
+	paris := &types.UserCoordinates{Latitude: "48.856600", Longitude: "2.352200"}
+	wr := &types.FullWeatherReport{CityName: "some_location"}
+
+	type call struct {
+		loc    *types.UserCoordinates
+		period types.Period
+		// after is how long after the first call this one is made.
+		after time.Duration
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(fc *mock.MockForecastClient)
+		calls   []call
+		wantErr bool
+	}{
+		{
+			name: "1. Same place and period within the TTL is got once",
+			prepare: func(fc *mock.MockForecastClient) {
+				fc.EXPECT().GetForecast(gomock.Any(), berlin, types.Now()).Return(wr, nil).Times(1)
+			},
+			calls:   []call{{berlin, types.Now(), 0}, {berlin, types.Now(), time.Minute}},
+			wantErr: false,
+		},
+		{
+			name: "2. Other places and periods are got separately",
+			prepare: func(fc *mock.MockForecastClient) {
+				fc.EXPECT().GetForecast(gomock.Any(), berlin, types.Now()).Return(wr, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), berlin, types.Days(5)).Return(wr, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), paris, types.Now()).Return(wr, nil)
+			},
+			calls:   []call{{berlin, types.Now(), 0}, {berlin, types.Days(5), 0}, {paris, types.Now(), 0}},
+			wantErr: false,
+		},
+		{
+			name: "3. Expired forecast is got again",
+			prepare: func(fc *mock.MockForecastClient) {
+				fc.EXPECT().GetForecast(gomock.Any(), berlin, types.Now()).Return(wr, nil).Times(2)
+			},
+			calls:   []call{{berlin, types.Now(), 0}, {berlin, types.Now(), time.Hour}},
+			wantErr: false,
+		},
+		{
+			name: "4. Failure is not cached",
+			prepare: func(fc *mock.MockForecastClient) {
+				fc.EXPECT().GetForecast(gomock.Any(), berlin, types.Now()).Return(nil, someErr).Times(2)
+			},
+			calls:   []call{{berlin, types.Now(), 0}, {berlin, types.Now(), 0}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fc := mock.NewMockForecastClient(ctrl)
+			tt.prepare(fc)
+
+			start := time.Now()
+			c := NewCachedForecastClient(fc, 10*time.Minute)
+
+			for _, cl := range tt.calls {
+				c.now = func() time.Time { return start.Add(cl.after) }
+
+				got, err := c.GetForecast(ctx, cl.loc, cl.period)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("GetForecast() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err == nil && got != wr {
+					t.Errorf("GetForecast() = %v, want %v", got, wr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// notModified is the description Telegram gives when an edit changes nothing, e.g. the same button is pressed twice.
+const notModified = "message is not modified"
+
+// WithInlineKeyboards makes the service offer forecast periods as buttons under its message,
+// which is edited in place as the buttons are pressed, instead of sending a new message every step.
+func (s *MessageService) WithInlineKeyboards() *MessageService {
+	s.inline = true
+	return s
+}
+
+// periodTypeKeyboard returns the keyboard to choose the period type with once the location is known.
+func (s *MessageService) periodTypeKeyboard(loc *bot.Location) interface{} {
+	if s.inline {
+		return s.botRepo.GetDaysOrHoursInlineKeyboard(coordinatesOf(loc))
+	}
+
+	return s.botRepo.GetDaysOrHoursKeyboard()
+}
+
+// HandleCallbackQuery handles a press of an inline button by editing the message the button is under.
+func (s *MessageService) HandleCallbackQuery(ctx context.Context, upd *bot.Update) error {
+	cq := upd.CallbackQuery
+	if cq == nil || cq.From == nil || cq.Message == nil || cq.Message.Chat == nil {
+		return errors.Errorf("update %d carries no callback query to handle", upd.UpdateID)
+	}
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"username":      cq.From.UserName,
+		"user_id":       cq.From.ID,
+		"callback_data": cq.Data,
+	})
+	log.Info("Handling a callback query")
+
+	ctx, span := tracing.Start(ctx, "MessageService.handleCallbackQuery", attribute.Int("user_id", cq.From.ID))
+	defer span.End()
+
+	start := time.Now()
+	err := s.handleCallbackQuery(ctx, cq)
+	metrics.ObserveHandler("handleCallbackQuery", start, err)
+	tracing.RecordError(ctx, err)
+
+	if err != nil {
+		return errors.Wrap(err, "cannot handle a callback query")
+	}
+
+	return nil
+}
+
+func (s *MessageService) handleCallbackQuery(ctx context.Context, cq *bot.CallbackQuery) error {
+	log := ctxlogrus.Extract(ctx)
+
+	data, err := types.ParseCallbackData(cq.Data)
+	if err != nil {
+		log.WithError(err).Warn("Pressed button is unknown")
+		return s.answer(ctx, cq, commentsEn["ButtonExpired"])
+	}
+
+	// The button stops spinning right away, the forecast may take a while.
+	err = s.answer(ctx, cq, "")
+	if err != nil {
+		return err
+	}
+
+	loc := data.Coordinates()
+	text := cq.Message.Text
+
+	var keyboard bot.InlineKeyboardMarkup
+	switch data.Action {
+	case types.CallbackMenu:
+		switch data.Option {
+		case types.MenuHours:
+			keyboard = s.botRepo.GetHoursInlineKeyboard(loc)
+		case types.MenuDays:
+			keyboard = s.botRepo.GetDaysInlineKeyboard(loc)
+		default:
+			keyboard = s.botRepo.GetDaysOrHoursInlineKeyboard(loc)
+		}
+	case types.CallbackForecast:
+		period, err := types.ParsePeriod(data.Option)
+		if err != nil {
+			return errors.Wrapf(err, types.ErrOnHandling, cq.Data)
+		}
+
+		wr, err := s.forecast.GetForecast(ctx, loc, period)
+		if err != nil {
+			return errors.Wrapf(err, types.ErrOnHandling, cq.Data)
+		}
+
+		text = s.formatPeriod(ctx, wr, period)
+		switch period.Unit {
+		case types.PeriodHours:
+			keyboard = s.botRepo.GetHoursInlineKeyboard(loc)
+		case types.PeriodDays:
+			keyboard = s.botRepo.GetDaysInlineKeyboard(loc)
+		default:
+			keyboard = s.botRepo.GetDaysOrHoursInlineKeyboard(loc)
+		}
+	}
+
+	edit := bot.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text)
+	edit.ReplyMarkup = &keyboard
+
+	_, err = s.edit(ctx, edit)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, cq.Data)
+	}
+
+	return nil
+}
+
+// answer tells Telegram the button press is handled, showing the text to the user if there is any.
+func (s *MessageService) answer(ctx context.Context, cq *bot.CallbackQuery, text string) error {
+	err := s.botCmd.AnswerCallback(bot.NewCallback(cq.ID, text))
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, cq.Data)
+	}
+
+	return nil
+}
+
+// edit edits the message within its own span. An edit changing nothing is not an error.
+func (s *MessageService) edit(ctx context.Context, msg bot.EditMessageTextConfig) (bot.Message, error) {
+	ctx, span := tracing.Start(ctx, "BotClient.Edit", attribute.Int64("chat_id", msg.ChatID))
+	defer span.End()
+
+	resp, err := s.botCmd.Edit(msg)
+
+	var apiErr bot.Error
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Message, notModified) {
+		return resp, nil
+	}
+	tracing.RecordError(ctx, err)
+
+	return resp, err
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleCallbackQuery(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	messageID := 42
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	loc := &types.UserCoordinates{Latitude: "52.520000", Longitude: "13.400000"}
+	wr := &types.FullWeatherReport{CityName: "some_location"}
+
+	hours := bot.NewInlineKeyboardMarkup(bot.NewInlineKeyboardRow(bot.NewInlineKeyboardButtonData("hours", "hours")))
+	daysOrHours := bot.NewInlineKeyboardMarkup(bot.NewInlineKeyboardRow(bot.NewInlineKeyboardButtonData("days_or_hours", "days_or_hours")))
+
+	newUpdate := func(action, option string) *bot.Update {
+		data := types.CallbackData{Action: action, Option: option, Latitude: loc.Latitude, Longitude: loc.Longitude}.String()
+		return &bot.Update{UpdateID: 1, CallbackQuery: &bot.CallbackQuery{
+			ID: "cq_id", From: user, Data: data,
+			Message: &bot.Message{MessageID: messageID, Text: "old_text", Chat: &bot.Chat{ID: chatID}},
+		}}
+	}
+	newEdit := func(text string, keyboard bot.InlineKeyboardMarkup) bot.EditMessageTextConfig {
+		edit := bot.NewEditMessageText(chatID, messageID, text)
+		edit.ReplyMarkup = &keyboard
+		return edit
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Menu keeps the text and changes the buttons",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", "")).Return(nil)
+				br.EXPECT().GetHoursInlineKeyboard(loc).Return(hours)
+				bc.EXPECT().Edit(newEdit("old_text", hours)).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(types.CallbackMenu, types.MenuHours),
+			wantErr: false,
+		},
+		{
+			name: "2. Forecast for hours at the location of the button",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", "")).Return(nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Hours(48)).Return(wr, nil)
+				f.EXPECT().FormatHours(gomock.Any(), wr, 48).Return("hours_report")
+				br.EXPECT().GetHoursInlineKeyboard(loc).Return(hours)
+				bc.EXPECT().Edit(newEdit("hours_report", hours)).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(types.CallbackForecast, "48 hours"),
+			wantErr: false,
+		},
+		{
+			name: "3. Pressing the same button twice is fine",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", "")).Return(nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Now()).Return(wr, nil)
+				f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				br.EXPECT().GetDaysOrHoursInlineKeyboard(loc).Return(daysOrHours)
+				bc.EXPECT().Edit(newEdit("now_report", daysOrHours)).
+					Return(bot.Message{}, bot.Error{Message: "Bad Request: message is not modified"})
+			},
+			upd:     newUpdate(types.CallbackForecast, CurrentWeather),
+			wantErr: false,
+		},
+		{
+			name: "4. Unknown button is answered",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", commentsEn["ButtonExpired"])).Return(nil)
+			},
+			upd: &bot.Update{UpdateID: 1, CallbackQuery: &bot.CallbackQuery{
+				ID: "cq_id", From: user, Data: "48 hours",
+				Message: &bot.Message{MessageID: messageID, Chat: &bot.Chat{ID: chatID}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "5. Error on getting a forecast",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", "")).Return(nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Days(7)).Return(nil, someErr)
+			},
+			upd:     newUpdate(types.CallbackForecast, "7 days"),
+			wantErr: true,
+		},
+		{
+			name: "6. Callback query from an inline message",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+			},
+			upd:     &bot.Update{UpdateID: 1, CallbackQuery: &bot.CallbackQuery{ID: "cq_id", From: user, InlineMessageID: "inline_id"}},
+			wantErr: true,
+		},
+		{
+			name: "7. Button with a forged location is answered as unknown",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", commentsEn["ButtonExpired"])).Return(nil)
+			},
+			upd: &bot.Update{UpdateID: 1, CallbackQuery: &bot.CallbackQuery{
+				ID: "cq_id", From: user, Data: "f|Now|1&key=x|2",
+				Message: &bot.Message{MessageID: messageID, Chat: &bot.Chat{ID: chatID}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "8. Button with a location out of range is answered as unknown",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, br *mock.MockBotUIRepo) {
+				bc.EXPECT().AnswerCallback(bot.NewCallback("cq_id", commentsEn["ButtonExpired"])).Return(nil)
+			},
+			upd: &bot.Update{UpdateID: 1, CallbackQuery: &bot.CallbackQuery{
+				ID: "cq_id", From: user, Data: "m|type|95|13.4",
+				Message: &bot.Message{MessageID: messageID, Chat: &bot.Chat{ID: chatID}},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bc := mock.NewMockBotClient(ctrl)
+			fc := mock.NewMockForecastClient(ctrl)
+			f := mock.NewMockReportFormatter(ctrl)
+			br := mock.NewMockBotUIRepo(ctrl)
+
+			tt.prepare(bc, fc, f, br)
+
+			s := NewMessageService(bc, fc, f, br, mock.NewMockLocationRepo(ctrl), mock.NewMockUserLocationRepo(ctrl), mock.NewMockUserDataRepo(ctrl)).
+				WithInlineKeyboards()
+			if err := s.HandleCallbackQuery(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleCallbackQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const Help = "/help"
+
+// defaultLanguage is the language of command descriptions shown when there are none in the user's language.
+const defaultLanguage = "en"
+
+// syncTimeout limits setting the command menu of a single scope in a single language.
+const syncTimeout = 5 * time.Second
+
+// Command is a bot command with its descriptions in every supported language.
+type Command struct {
+	Name         string
+	Descriptions map[string]string
+	// Scopes are the chats where the command is offered, see types.Scope*.
+	Scopes []string
+}
+
+// CommandRegistry lists the commands the bot offers, so that the command menu
+// in Telegram and the /help answer are built from the same place.
+type CommandRegistry struct {
+	commands []Command
+}
+
+var (
+	everywhere  = []string{types.ScopeAllPrivateChats, types.ScopeAllGroupChats}
+	privateOnly = []string{types.ScopeAllPrivateChats}
+	groupsOnly  = []string{types.ScopeAllGroupChats}
+)
+
+// NewCommandRegistry returns the registry of the commands the bot has.
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: []Command{
+		{Name: "start", Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Start over from the main menu",
+			"ru": "Начать сначала с главного меню",
+		}},
+		{Name: NowCmd, Scopes: everywhere, Descriptions: map[string]string{
+			"en": "Current weather, e.g. /now Berlin",
+			"ru": "Погода сейчас, например /now Berlin",
+		}},
+		{Name: HourlyCmd, Scopes: everywhere, Descriptions: map[string]string{
+			"en": "Forecast by hours, e.g. /hourly 36 Paris",
+			"ru": "Прогноз по часам, например /hourly 36 Paris",
+		}},
+		{Name: DailyCmd, Scopes: everywhere, Descriptions: map[string]string{
+			"en": "Forecast by days, e.g. /daily 7",
+			"ru": "Прогноз по дням, например /daily 7",
+		}},
+		{Name: WeatherCmd, Scopes: everywhere, Descriptions: map[string]string{
+			"en": "Current weather at coordinates, e.g. /weather 52.52,13.40",
+			"ru": "Погода сейчас по координатам, например /weather 52.52,13.40",
+		}},
+		{Name: CompareCmd, Scopes: everywhere, Descriptions: map[string]string{
+			"en": "Compare cities, e.g. /compare London, Paris, Rome 5",
+			"ru": "Сравнить города, например /compare London, Paris, Rome 5",
+		}},
+		{Name: "help", Scopes: everywhere, Descriptions: map[string]string{
+			"en": "What I can do",
+			"ru": "Что я умею",
+		}},
+		{Name: SaveCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Save a favorite, e.g. /save home or /save office Berlin",
+			"ru": "Сохранить в избранное, например /save home или /save office Berlin",
+		}},
+		{Name: FavoritesCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "List your favorites",
+			"ru": "Список избранного",
+		}},
+		{Name: RenameCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Rename a favorite, e.g. /rename home flat",
+			"ru": "Переименовать избранное, например /rename home flat",
+		}},
+		{Name: DeleteCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Delete a favorite, e.g. /delete office",
+			"ru": "Удалить из избранного, например /delete office",
+		}},
+		{Name: HistoryCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Recent places to pick again",
+			"ru": "Недавние места, чтобы выбрать снова",
+		}},
+		{Name: ForgetCmd, Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Delete everything I know about you",
+			"ru": "Удалить всё, что я о вас знаю",
+		}},
+		{Name: SetLocationCmd, Scopes: groupsOnly, Descriptions: map[string]string{
+			"en": "Admins: default city of the group, e.g. /setlocation Lisbon",
+			"ru": "Админам: город группы по умолчанию, например /setlocation Lisbon",
+		}},
+		{Name: SetLanguageCmd, Scopes: groupsOnly, Descriptions: map[string]string{
+			"en": "Admins: language of weather descriptions and /help in the group, e.g. /setlanguage pt",
+			"ru": "Админам: язык описаний погоды и /help в группе, например /setlanguage pt",
+		}},
+		{Name: "stop", Scopes: privateOnly, Descriptions: map[string]string{
+			"en": "Hide the keyboard and say goodbye",
+			"ru": "Спрятать клавиатуру и попрощаться",
+		}},
+	}}
+}
+
+// Register adds a command to the registry.
+func (r *CommandRegistry) Register(cmd Command) *CommandRegistry {
+	r.commands = append(r.commands, cmd)
+	return r
+}
+
+// Languages returns every language the commands are described in, sorted.
+func (r *CommandRegistry) Languages() []string {
+	seen := map[string]bool{}
+	var langs []string
+	for _, cmd := range r.commands {
+		for lang := range cmd.Descriptions {
+			if !seen[lang] {
+				seen[lang] = true
+				langs = append(langs, lang)
+			}
+		}
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// BotCommands returns the commands offered in the scope, described in the language or in the default one.
+func (r *CommandRegistry) BotCommands(scope, lang string) []types.BotCommand {
+	var cmds []types.BotCommand
+	for _, cmd := range r.commands {
+		if !inScope(cmd, scope) {
+			continue
+		}
+		cmds = append(cmds, types.BotCommand{Command: cmd.Name, Description: describe(cmd, lang)})
+	}
+
+	return cmds
+}
+
+// Help returns the list of commands offered in the scope for the /help answer.
+func (r *CommandRegistry) Help(scope, lang string) string {
+	var buf bytes.Buffer
+	buf.WriteString(helpHeaders[helpLanguage(lang)])
+	for _, cmd := range r.BotCommands(scope, lang) {
+		buf.WriteString(fmt.Sprintf("\n/%s - %s", cmd.Command, cmd.Description))
+	}
+
+	return buf.String()
+}
+
+// Sync sets the command menu of every scope in every language, the default language is set without a language code.
+// A menu that cannot be set is logged and the others are set anyway.
+func (r *CommandRegistry) Sync(ctx context.Context, setter CommandSetter) error {
+	log := ctxlogrus.Extract(ctx)
+
+	failed := 0
+	for _, scope := range everywhere {
+		for _, lang := range append([]string{""}, r.Languages()...) {
+			err := r.syncMenu(ctx, setter, scope, lang)
+			if err != nil {
+				log.WithError(err).WithFields(logrus.Fields{
+					"scope":    scope,
+					"language": lang,
+				}).Warn("Cannot set bot commands")
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("cannot set %d command menus", failed)
+	}
+
+	log.WithFields(logrus.Fields{
+		"commands":  len(r.commands),
+		"languages": r.Languages(),
+	}).Info("Bot commands are synced")
+
+	return nil
+}
+
+// syncMenu sets the command menu of the scope in the language, giving up after syncTimeout.
+func (r *CommandRegistry) syncMenu(ctx context.Context, setter CommandSetter, scope, lang string) error {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	return setter.SetMyCommands(ctx, r.BotCommands(scope, lang), types.BotCommandScope{Type: scope}, lang)
+}
+
+func inScope(cmd Command, scope string) bool {
+	for _, s := range cmd.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describe(cmd Command, lang string) string {
+	if desc, ok := cmd.Descriptions[lang]; ok {
+		return desc
+	}
+
+	return cmd.Descriptions[defaultLanguage]
+}
+
+var helpHeaders = map[string]string{
+	"en": "Here is what I can do:",
+	"ru": "Вот что я умею:",
+}
+
+func helpLanguage(lang string) string {
+	if _, ok := helpHeaders[lang]; ok {
+		return lang
+	}
+
+	return defaultLanguage
+}
+
+// WithCommands makes /help list the commands of the registry.
+func (s *MessageService) WithCommands(commands *CommandRegistry) *MessageService {
+	s.commands = commands
+	return s
+}
+
+func (s *MessageService) handleHelp(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	scope := types.ScopeAllPrivateChats
+	if isGroupChat(req.Chat) {
+		scope = types.ScopeAllGroupChats
+	}
+
+	// Groups may have a language of their own.
+	lang := types.LanguageFrom(ctx)
+	if lang == "" {
+		lang = req.From.LanguageCode
+	}
+
+	return s.reply(ctx, req, s.commands.Help(scope, lang))
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"weather-or-not-bot/internal/fakebotapi"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+func TestCommandRegistry_Help(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    string
+		lang     string
+		want     []string
+		notWant  []string
+		wantHead string
+	}{
+		{"1. Private chat in English", types.ScopeAllPrivateChats, "en",
+			[]string{"/start - Start over", "/now - Current weather", "/help - What I can do"}, nil, helpHeaders["en"]},
+		{"2. Group chat has no private commands", types.ScopeAllGroupChats, "en",
+			[]string{"/hourly - Forecast by hours"}, []string{"/start", "/stop"}, helpHeaders["en"]},
+		{"3. Described in the user's language", types.ScopeAllPrivateChats, "ru",
+			[]string{"/daily - Прогноз по дням"}, nil, helpHeaders["ru"]},
+		{"4. Unknown language falls back to English", types.ScopeAllPrivateChats, "pt",
+			[]string{"/daily - Forecast by days"}, nil, helpHeaders["en"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewCommandRegistry().Help(tt.scope, tt.lang)
+
+			if !strings.HasPrefix(got, tt.wantHead) {
+				t.Errorf("Help() = %q, want it to start with %q", got, tt.wantHead)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(got, line) {
+					t.Errorf("Help() = %q, want it to contain %q", got, line)
+				}
+			}
+			for _, line := range tt.notWant {
+				if strings.Contains(got, line) {
+					t.Errorf("Help() = %q, want it not to contain %q", got, line)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandRegistry_Sync(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	tests := []struct {
+		name    string
+		prepare func(cs *mock.MockCommandSetter, r *CommandRegistry)
+		wantErr bool
+	}{
+		{
+			name: "1. Every scope in every language",
+			prepare: func(cs *mock.MockCommandSetter, r *CommandRegistry) {
+				for _, scope := range []string{types.ScopeAllPrivateChats, types.ScopeAllGroupChats} {
+					for _, lang := range []string{"", "en", "ru"} {
+						cs.EXPECT().SetMyCommands(gomock.Any(), r.BotCommands(scope, lang), types.BotCommandScope{Type: scope}, lang).Return(nil)
+					}
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "2. Error on setting commands, the other menus set anyway",
+			prepare: func(cs *mock.MockCommandSetter, r *CommandRegistry) {
+				cs.EXPECT().SetMyCommands(gomock.Any(), gomock.Any(), types.BotCommandScope{Type: types.ScopeAllPrivateChats}, "").Return(someErr)
+				for _, scope := range []string{types.ScopeAllPrivateChats, types.ScopeAllGroupChats} {
+					for _, lang := range []string{"", "en", "ru"} {
+						if scope == types.ScopeAllPrivateChats && lang == "" {
+							continue
+						}
+						cs.EXPECT().SetMyCommands(gomock.Any(), r.BotCommands(scope, lang), types.BotCommandScope{Type: scope}, lang).Return(nil)
+					}
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "3. Every call bounded in time",
+			prepare: func(cs *mock.MockCommandSetter, r *CommandRegistry) {
+				cs.EXPECT().SetMyCommands(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, _ []types.BotCommand, _ types.BotCommandScope, _ string) error {
+						if _, ok := ctx.Deadline(); !ok {
+							return errors.New("no deadline")
+						}
+						return nil
+					}).Times(6)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cs := mock.NewMockCommandSetter(ctrl)
+			r := NewCommandRegistry()
+			tt.prepare(cs, r)
+
+			if err := r.Sync(ctx, cs); (err != nil) != tt.wantErr {
+				t.Errorf("Sync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestBotCmd_SetMyCommands checks that commands reach the fake Bot API as Telegram expects them.
+func TestBotCmd_SetMyCommands(t *testing.T) {
+	fake := fakebotapi.NewServer("123:fake_token")
+	defer fake.Close()
+
+	api, err := fake.NewBotAPI()
+	if err != nil {
+		t.Fatalf("cannot create bot API: %v", err)
+	}
+
+	want := []types.BotCommand{{Command: "now", Description: "Current weather"}}
+	err = NewBotCmd(api).SetMyCommands(context.Background(), want, types.BotCommandScope{Type: types.ScopeAllGroupChats}, "ru")
+	if err != nil {
+		t.Fatalf("SetMyCommands() unexpected error = %v", err)
+	}
+
+	calls := fake.CallsTo("setMyCommands")
+	if len(calls) != 1 {
+		t.Fatalf("setMyCommands called %d times, want 1", len(calls))
+	}
+
+	var got []types.BotCommand
+	if err := json.Unmarshal([]byte(calls[0].Params.Get("commands")), &got); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %s, want %+v", calls[0].Params.Get("commands"), want)
+	}
+	if scope := calls[0].Params.Get("scope"); scope != `{"type":"all_group_chats"}` {
+		t.Errorf("scope = %s, want all_group_chats", scope)
+	}
+	if lang := calls[0].Params.Get("language_code"); lang != "ru" {
+		t.Errorf("language_code = %s, want ru", lang)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// CompareCmd compares the weather in several cities, e.g. '/compare London, Paris, Rome 5'.
+const CompareCmd = "compare"
+
+const (
+	defaultCompareDays = 3
+	minCompareCities   = 2
+	maxCompareCities   = 5
+)
+
+// parseCompareCommand reads the cities separated by commas and the optional number of days after the last one.
+func parseCompareCommand(args string) ([]string, int, error) {
+	usage := usageError(fmt.Sprintf(commentsEn["UsageCompare"], maxCompareCities, types.MaxForecastDays))
+
+	var cities []string
+	for _, city := range strings.Split(args, ",") {
+		if city = strings.TrimSpace(city); city != "" {
+			cities = append(cities, city)
+		}
+	}
+	if len(cities) == 0 {
+		return nil, 0, usage
+	}
+
+	days := defaultCompareDays
+	last := strings.Fields(cities[len(cities)-1])
+	if n, err := strconv.Atoi(last[len(last)-1]); err == nil {
+		if n < 1 || n > types.MaxForecastDays {
+			return nil, 0, usage
+		}
+		days = n
+
+		if len(last) == 1 {
+			cities = cities[:len(cities)-1]
+		} else {
+			cities[len(cities)-1] = strings.Join(last[:len(last)-1], " ")
+		}
+	}
+
+	if len(cities) < minCompareCities || len(cities) > maxCompareCities {
+		return nil, 0, usage
+	}
+
+	return cities, days, nil
+}
+
+// handleCompare replies with the forecasts for the cities side by side, getting them all at once.
+func (s *MessageService) handleCompare(ctx context.Context, req *bot.Message) error {
+	var usage usageError
+
+	cities, days, err := parseCompareCommand(req.CommandArguments())
+	if errors.As(err, &usage) {
+		return s.reply(ctx, req, usage.Error())
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	locs := make([]*types.UserCoordinates, len(cities))
+	for i, city := range cities {
+		locs[i], err = s.locationOf(ctx, req, &forecastQuery{city: city})
+		if errors.As(err, &usage) {
+			return s.reply(ctx, req, usage.Error())
+		}
+		if err != nil {
+			return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+		}
+	}
+
+	reports, err := s.getForecasts(ctx, locs, types.Days(days))
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.reply(ctx, req, s.format.FormatComparison(ctx, reports, days))
+}
+
+// getForecasts gets the forecasts for all the locations concurrently, in the order of the locations.
+func (s *MessageService) getForecasts(ctx context.Context, locs []*types.UserCoordinates, period types.Period) ([]*types.FullWeatherReport, error) {
+	var (
+		wg      sync.WaitGroup
+		reports = make([]*types.FullWeatherReport, len(locs))
+		errs    = make([]error, len(locs))
+	)
+	for i := range locs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i], errs[i] = s.forecast.GetForecast(ctx, locs[i], period)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestParseCompareCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		wantCities []string
+		wantDays   int
+		wantUsage  bool
+	}{
+		{"1. Cities with days", "London, Paris, Rome 5", []string{"London", "Paris", "Rome"}, 5, false},
+		{"2. Default days", "London,Paris", []string{"London", "Paris"}, defaultCompareDays, false},
+		{"3. Days after a comma and cities with spaces", "New York, Rio de Janeiro, 7", []string{"New York", "Rio de Janeiro"}, 7, false},
+		{"4. One city", "London 5", nil, 0, true},
+		{"5. Too many cities", "A, B, C, D, E, F", nil, 0, true},
+		{"6. Too many days", "London, Paris 17", nil, 0, true},
+		{"7. Nothing", "", nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cities, days, err := parseCompareCommand(tt.args)
+
+			var usage usageError
+			if errors.As(err, &usage) != tt.wantUsage {
+				t.Fatalf("parseCompareCommand() error = %v, wantUsage %v", err, tt.wantUsage)
+			}
+			if !reflect.DeepEqual(cities, tt.wantCities) || days != tt.wantDays {
+				t.Errorf("parseCompareCommand() = %v, %d, want %v, %d", cities, days, tt.wantCities, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestMessageService_HandleCompare(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	london := &bot.Location{Latitude: 51.5072, Longitude: -0.1276}
+	paris := &bot.Location{Latitude: 48.8566, Longitude: 2.3522}
+	londonWR := &types.FullWeatherReport{CityName: "London"}
+	parisWR := &types.FullWeatherReport{CityName: "Paris"}
+
+	newUpdate := func(args string) *bot.Update {
+		entities := []bot.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(CompareCmd) + 1}}
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{
+			MessageID: 11, Text: "/" + CompareCmd + " " + args, Entities: &entities, From: user, Chat: &bot.Chat{ID: chatID},
+		}}
+	}
+
+	type mocks struct {
+		bc *mock.MockBotClient
+		fc *mock.MockForecastClient
+		f  *mock.MockReportFormatter
+		lr *mock.MockLocationRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Cities compared in the given order",
+			prepare: func(m mocks) {
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "London").Return(london, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Paris").Return(paris, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(london), types.Days(5)).Return(londonWR, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(paris), types.Days(5)).Return(parisWR, nil)
+				m.f.EXPECT().FormatComparison(gomock.Any(), []*types.FullWeatherReport{londonWR, parisWR}, 5).Return("comparison")
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, "comparison")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("London, Paris 5"),
+			wantErr: false,
+		},
+		{
+			name: "2. City not found",
+			prepare: func(m mocks) {
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "London").Return(london, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Nowhere").Return(&bot.Location{}, errors.Wrap(sql.ErrNoRows, "cannot get coordinates"))
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["CityNotFound"], "Nowhere"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("London, Nowhere"),
+			wantErr: false,
+		},
+		{
+			name: "3. Wrong arguments",
+			prepare: func(m mocks) {
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["UsageCompare"], maxCompareCities, types.MaxForecastDays))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("London"),
+			wantErr: false,
+		},
+		{
+			name: "4. Error on getting one of the forecasts",
+			prepare: func(m mocks) {
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "London").Return(london, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Paris").Return(paris, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(london), types.Days(defaultCompareDays)).Return(londonWR, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(paris), types.Days(defaultCompareDays)).Return(nil, someErr)
+			},
+			upd:     newUpdate("London, Paris"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc: mock.NewMockBotClient(ctrl),
+				fc: mock.NewMockForecastClient(ctrl),
+				f:  mock.NewMockReportFormatter(ctrl),
+				lr: mock.NewMockLocationRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, m.fc, m.f, mock.NewMockBotUIRepo(ctrl), m.lr, mock.NewMockUserLocationRepo(ctrl), mock.NewMockUserDataRepo(ctrl))
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// WithConversations makes the service keep the conversation state of every chat,
+// so that a message is handled according to the step the chat is at.
+func (s *MessageService) WithConversations(conversations ConversationRepo) *MessageService {
+	s.conversations = conversations
+	return s
+}
+
+// input is what a message means regardless of the conversation state.
+type input int
+
+const (
+	inputText input = iota
+	inputEmpty
+	inputStart
+	inputStop
+	inputReplay
+	inputBack
+	inputLocation
+	inputWeatherElsewhere
+	inputByHours
+	inputByDays
+	inputNow
+	inputHours
+	inputDays
+	inputForecastCommand
+	inputHelp
+	inputFavorite
+	inputFavoriteCommand
+	inputRecentLocation
+	inputHistory
+	inputForget
+	inputForgetConfirmed
+	inputPurge
+)
+
+// acceptedEverywhere are inputs that make sense at any step of the conversation.
+var acceptedEverywhere = map[input]bool{
+	inputStart:           true,
+	inputStop:            true,
+	inputReplay:          true,
+	inputBack:            true,
+	inputLocation:        true,
+	inputForecastCommand: true,
+	inputHelp:            true,
+	inputFavorite:        true,
+	inputFavoriteCommand: true,
+	inputRecentLocation:  true,
+	inputHistory:         true,
+	inputForget:          true,
+	inputPurge:           true,
+}
+
+// accepted tells which other inputs each conversation state accepts.
+var accepted = map[types.ConversationState]map[input]bool{
+	types.StateMainMenu:           {inputWeatherElsewhere: true},
+	types.StateAwaitingCity:       {inputText: true, inputWeatherElsewhere: true},
+	types.StateChoosingPeriodType: {inputByHours: true, inputByDays: true, inputNow: true},
+	types.StateChoosingHours:      {inputHours: true, inputByDays: true},
+	types.StateChoosingDays:       {inputDays: true, inputByHours: true},
+	// Deleting the user is never done by accident, it takes /forget right before.
+	types.StateAwaitingForgetConfirmation: {inputForgetConfirmed: true},
+}
+
+// transitions tells where the conversation goes once an input is handled.
+// Inputs that are not listed either keep the state or move it in their own way.
+var transitions = map[input]types.ConversationState{
+	inputLocation:         types.StateChoosingPeriodType,
+	inputFavorite:         types.StateChoosingPeriodType,
+	inputFavoriteCommand:  types.StateMainMenu,
+	inputRecentLocation:   types.StateChoosingPeriodType,
+	inputHistory:          types.StateMainMenu,
+	inputForget:           types.StateAwaitingForgetConfirmation,
+	inputWeatherElsewhere: types.StateAwaitingCity,
+	inputByHours:          types.StateChoosingHours,
+	inputByDays:           types.StateChoosingDays,
+	inputNow:              types.StateChoosingPeriodType,
+	inputHours:            types.StateChoosingHours,
+	inputDays:             types.StateChoosingDays,
+}
+
+// maxSaveAttempts limits saving a conversation that keeps being changed meanwhile.
+const maxSaveAttempts = 3
+
+// handlerFunc handles a message in a conversation.
+type handlerFunc func(ctx context.Context, req *bot.Message) error
+
+func inputOf(req *bot.Message) input {
+	if isForecastCommand(req) {
+		return inputForecastCommand
+	}
+
+	if req.Text == Help || req.Command() == "help" {
+		return inputHelp
+	}
+
+	if isFavoriteCommand(req) {
+		return inputFavoriteCommand
+	}
+
+	if strings.HasPrefix(req.Text, FavoriteMark) {
+		return inputFavorite
+	}
+
+	if req.Command() == HistoryCmd {
+		return inputHistory
+	}
+
+	if strings.HasPrefix(req.Text, HistoryMark) {
+		return inputRecentLocation
+	}
+
+	switch req.Command() {
+	case ForgetCmd:
+		return inputForget
+	case PurgeCmd:
+		return inputPurge
+	}
+
+	switch req.Text {
+	case Start:
+		return inputStart
+	case Stop:
+		return inputStop
+	case Replay:
+		return inputReplay
+	case ForgetMe:
+		return inputForgetConfirmed
+	case BackToMainMenu, Back:
+		return inputBack
+	case WeatherHere:
+		return inputLocation
+	case WeatherElsewhere:
+		return inputWeatherElsewhere
+	case ByHours:
+		return inputByHours
+	case ByDays:
+		return inputByDays
+	case CurrentWeather:
+		return inputNow
+	case EmptyMessage:
+		if req.Location != nil {
+			return inputLocation
+		}
+		return inputEmpty
+	}
+
+	if period, err := types.ParsePeriod(req.Text); err == nil {
+		switch period.Unit {
+		case types.PeriodHours:
+			return inputHours
+		case types.PeriodDays:
+			return inputDays
+		}
+	}
+
+	return inputText
+}
+
+// route picks the handler for the message at the current step of the conversation.
+// The handler moves the conversation on once it succeeds.
+func (s *MessageService) route(conv *types.Conversation, req *bot.Message) (string, handlerFunc) {
+	// Groups share a chat, so they have no conversation to follow.
+	if s.groups != nil && isGroupChat(req.Chat) {
+		return s.routeGroup(req)
+	}
+
+	in := inputOf(req)
+
+	// Queries like 'tomorrow in Paris' make sense at any step, but the one where anything typed is a city name.
+	if in == inputText && conv.State != types.StateAwaitingCity {
+		if q, ok := s.naturalQuery(req.Text, req.From.LanguageCode); ok {
+			return "handleNaturalQuery", func(ctx context.Context, req *bot.Message) error {
+				return s.handleNaturalQuery(ctx, req, q)
+			}
+		}
+	}
+
+	// Chats with no state stored are handled as before states were kept, whatever the input,
+	// but the user is forgotten only in the state /forget has left the chat in.
+	stateless := conv.State == types.StateNone
+	if (!stateless || in == inputForgetConfirmed) && !acceptedEverywhere[in] && !accepted[conv.State][in] {
+		return "handleOutOfTurn", func(ctx context.Context, req *bot.Message) error {
+			return s.handleOutOfTurn(ctx, req, conv.State)
+		}
+	}
+
+	switch in {
+	case inputStart:
+		return "handleStart", reset(conv, s.handleStart)
+	case inputStop:
+		return "handleStop", reset(conv, s.handleStop)
+	case inputReplay:
+		return "handleReplay", s.handleReplay
+	case inputBack:
+		switch {
+		case req.Text == BackToMainMenu:
+			return "handleBackToMainMenu", reset(conv, s.handleBackToMainMenu)
+		case !stateless:
+			return "handleBack", func(ctx context.Context, req *bot.Message) error {
+				return s.handlePrompt(ctx, req, conv.Back())
+			}
+		default:
+			return "handleBack", forward(conv, types.StateChoosingPeriodType, s.handleBack)
+		}
+	case inputLocation:
+		if req.Location == nil {
+			return "handleLocationByCoordinates", s.handleLocationByCoordinates
+		}
+		return "handleLocationByCoordinates", forward(conv, transitions[in], s.handleLocationByCoordinates)
+	case inputWeatherElsewhere:
+		return "handleWeatherElsewhere", forward(conv, transitions[in], s.handleWeatherElsewhere)
+	case inputByHours:
+		return "handleByHours", forward(conv, transitions[in], s.handleByHours)
+	case inputByDays:
+		return "handleByDays", forward(conv, transitions[in], s.handleByDays)
+	case inputNow:
+		return "handleNow", forward(conv, transitions[in], s.handleNow)
+	case inputHours, inputDays:
+		return "handlePeriod", forward(conv, transitions[in], s.handlePeriod)
+	case inputForecastCommand:
+		return "handleForecastCommand", s.handleForecastCommand
+	case inputHelp:
+		return "handleHelp", s.handleHelp
+	case inputFavorite:
+		return "handleFavorite", func(ctx context.Context, req *bot.Message) error {
+			found, err := s.handleFavorite(ctx, req)
+			if err == nil && found {
+				conv.Forward(transitions[in])
+			}
+			return err
+		}
+	case inputFavoriteCommand:
+		return "handleFavoriteCommand", forward(conv, transitions[in], s.handleFavoriteCommand)
+	case inputHistory:
+		return "handleHistory", forward(conv, transitions[in], s.handleHistory)
+	case inputRecentLocation:
+		return "handleRecentLocation", func(ctx context.Context, req *bot.Message) error {
+			found, err := s.handleRecentLocation(ctx, req)
+			if err == nil && found {
+				conv.Forward(transitions[in])
+			}
+			return err
+		}
+	case inputForget:
+		return "handleForget", forward(conv, transitions[in], s.handleForget)
+	case inputForgetConfirmed:
+		// The conversation is deleted with the rest and is left unchanged, so that it is not saved again.
+		return "handleForgetConfirmed", s.handleForgetConfirmed
+	case inputPurge:
+		return "handlePurge", s.handlePurge
+	case inputEmpty:
+		return "handleEmptyMessage", s.handleEmptyMessage
+	}
+
+	if stateless {
+		return "handleUnknown", s.handleUnknown
+	}
+
+	// Only a chat awaiting a city gets here, anything typed is taken for the city name.
+	return "handleLocationByText", func(ctx context.Context, req *bot.Message) error {
+		found, err := s.handleLocationByText(ctx, req)
+		if err == nil && found {
+			conv.Forward(types.StateChoosingPeriodType)
+		}
+		return err
+	}
+}
+
+// forward makes the handler move the conversation to the next state once it succeeds.
+func forward(conv *types.Conversation, next types.ConversationState, handle handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *bot.Message) error {
+		err := handle(ctx, req)
+		if err == nil {
+			conv.Forward(next)
+		}
+		return err
+	}
+}
+
+// reset makes the handler start the conversation over once it succeeds.
+func reset(conv *types.Conversation, handle handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *bot.Message) error {
+		err := handle(ctx, req)
+		if err == nil {
+			conv.Reset()
+		}
+		return err
+	}
+}
+
+// prompt returns the question and the keyboard of the conversation state.
+func (s *MessageService) prompt(ctx context.Context, req *bot.Message, state types.ConversationState) (string, interface{}) {
+	switch state {
+	case types.StateAwaitingCity:
+		return commentsEn["DiffPlaceAccepted"], bot.ReplyKeyboardHide{HideKeyboard: true}
+	case types.StateChoosingPeriodType:
+		return commentsEn["ChoosePeriodType"], s.botRepo.GetDaysOrHoursKeyboard()
+	case types.StateChoosingHours:
+		return commentsEn["ChoosePeriod"], s.botRepo.GetHoursKeyboard()
+	case types.StateChoosingDays:
+		return commentsEn["ChoosePeriod"], s.botRepo.GetDaysKeyboard()
+	case types.StateAwaitingForgetConfirmation:
+		return commentsEn["ForgetAsk"], s.botRepo.GetForgetKeyboard()
+	default:
+		return commentsEn["ChooseLocation"], s.mainMenu(ctx, req.From.ID)
+	}
+}
+
+// handlePrompt asks the question of the state the conversation has come back to.
+func (s *MessageService) handlePrompt(ctx context.Context, req *bot.Message, state types.ConversationState) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s', back to '%s'", req.Text, state)
+
+	text, keyboard := s.prompt(ctx, req, state)
+	resp := bot.NewMessage(req.Chat.ID, text)
+	resp.ReplyMarkup = keyboard
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+// handleOutOfTurn answers a message that makes no sense at the current step and asks the question of the step again.
+func (s *MessageService) handleOutOfTurn(ctx context.Context, req *bot.Message, state types.ConversationState) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s', not expected in '%s'", req.Text, state)
+
+	text, keyboard := s.prompt(ctx, req, state)
+	resp := bot.NewMessage(req.Chat.ID, fmt.Sprintf("%s %s", commentsEn["Unknown"], text))
+	resp.ReplyMarkup = keyboard
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+// loadConversation returns the conversation of the chat and tells whether it is kept.
+// When it is not kept or cannot be read, the conversation has no state and must not be saved.
+func (s *MessageService) loadConversation(ctx context.Context, chat *bot.Chat) (*types.Conversation, bool) {
+	if s.conversations == nil || (s.groups != nil && isGroupChat(chat)) {
+		return &types.Conversation{State: types.StateNone}, false
+	}
+
+	conv, err := s.conversations.GetConversation(ctx, chat.ID)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).Warn("cannot get conversation state, handling the message without it")
+		return &types.Conversation{State: types.StateNone}, false
+	}
+
+	return conv, true
+}
+
+// saveConversation saves the state the message has left the conversation in. Another message of the chat
+// may have been handled meanwhile, e.g. by another replica, then the state is moved to on top of the newer one.
+func (s *MessageService) saveConversation(ctx context.Context, chatID int64, conv *types.Conversation) {
+	log := ctxlogrus.Extract(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := s.conversations.SaveConversation(ctx, chatID, conv)
+		if errors.Is(err, types.ErrConversationChanged) && attempt < maxSaveAttempts {
+			newer, err := s.conversations.GetConversation(ctx, chatID)
+			if err != nil {
+				log.WithError(err).Warn("cannot get conversation state changed meanwhile")
+				return
+			}
+			conv.Rebase(newer)
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Warn("cannot save conversation state")
+		}
+		return
+	}
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleNewMessageInConversation(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	botLoc := &bot.Location{Longitude: 45.16, Latitude: 12.32}
+
+	mainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("main_menu")))
+	daysOrHours := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("days_or_hours")))
+	days := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("days")))
+	backToMainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("back_to_main_menu")))
+
+	newUpdate := func(text string) *bot.Update {
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{MessageID: 11, Text: text, From: user, Chat: &bot.Chat{ID: chatID}}}
+	}
+
+	type mocks struct {
+		bc  *mock.MockBotClient
+		lr  *mock.MockLocationRepo
+		ulr *mock.MockUserLocationRepo
+		ur  *mock.MockUserDataRepo
+		br  *mock.MockBotUIRepo
+		cr  *mock.MockConversationRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. City typed in the main menu is not taken for a location",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateMainMenu}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s %s", commentsEn["Unknown"], commentsEn["ChooseLocation"]))
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("Berlin"),
+			wantErr: false,
+		},
+		{
+			name: "2. City found while awaiting a city",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateAwaitingCity,
+					History: []types.ConversationState{types.StateMainMenu},
+				}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Berlin").Return(botLoc, nil)
+				m.br.EXPECT().GetDaysOrHoursKeyboard().Return(daysOrHours)
+				m.ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, botLoc).Return(nil)
+				resp := bot.NewMessage(chatID, commentsEn["CoordsAccepted"])
+				resp.ReplyMarkup = daysOrHours
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+					State:   types.StateChoosingPeriodType,
+					History: []types.ConversationState{types.StateMainMenu, types.StateAwaitingCity},
+				}).Return(nil)
+			},
+			upd:     newUpdate("Berlin"),
+			wantErr: false,
+		},
+		{
+			name: "3. City not found while awaiting a city",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateAwaitingCity,
+					History: []types.ConversationState{types.StateMainMenu},
+				}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Nowhere").Return(&bot.Location{}, nil)
+				m.br.EXPECT().GetBackToMainMenuKeyboard().Return(backToMainMenu)
+				resp := bot.NewMessage(chatID, commentsEn["TryAgain"])
+				resp.ReplyMarkup = backToMainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("Nowhere"),
+			wantErr: false,
+		},
+		{
+			name: "4. Back from choosing period type returns to awaiting a city",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateChoosingPeriodType,
+					History: []types.ConversationState{types.StateMainMenu, types.StateAwaitingCity},
+				}, nil)
+				resp := bot.NewMessage(chatID, commentsEn["DiffPlaceAccepted"])
+				resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+					State:   types.StateAwaitingCity,
+					History: []types.ConversationState{types.StateMainMenu},
+				}).Return(nil)
+			},
+			upd:     newUpdate(Back),
+			wantErr: false,
+		},
+		{
+			name: "5. Back to the main menu from choosing period type after naming a city",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateChoosingPeriodType,
+					History: []types.ConversationState{types.StateMainMenu, types.StateAwaitingCity},
+				}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, commentsEn["ChooseLocation"])
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{State: types.StateMainMenu}).Return(nil)
+			},
+			upd:     newUpdate(BackToMainMenu),
+			wantErr: false,
+		},
+		{
+			name: "6. Choosing period by days",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateChoosingPeriodType,
+					History: []types.ConversationState{types.StateMainMenu},
+				}, nil)
+				m.br.EXPECT().GetDaysKeyboard().Return(days)
+				resp := bot.NewMessage(chatID, commentsEn["ChoosePeriod"])
+				resp.ReplyMarkup = days
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+					State:   types.StateChoosingDays,
+					History: []types.ConversationState{types.StateMainMenu, types.StateChoosingPeriodType},
+				}).Return(nil)
+			},
+			upd:     newUpdate(ByDays),
+			wantErr: false,
+		},
+		{
+			name: "7. Period of the wrong type is not expected",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateChoosingDays,
+					History: []types.ConversationState{types.StateMainMenu, types.StateChoosingPeriodType},
+				}, nil)
+				m.br.EXPECT().GetDaysKeyboard().Return(days)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s %s", commentsEn["Unknown"], commentsEn["ChoosePeriod"]))
+				resp.ReplyMarkup = days
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("24 hours"),
+			wantErr: false,
+		},
+		{
+			name: "8. Start with no state stored begins the conversation",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateNone}, nil)
+				m.ur.EXPECT().AddUserIfNotExists(gomock.Any(), user).Return(nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s\n%s", commentsEn["DefaultMessage"], pickASaying(11, sayingsEn)))
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{State: types.StateMainMenu}).Return(nil)
+			},
+			upd:     newUpdate(Start),
+			wantErr: false,
+		},
+		{
+			name: "9. Error on getting the state, handled as before states were kept",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(nil, someErr)
+				m.br.EXPECT().GetDaysOrHoursKeyboard().Return(daysOrHours)
+				resp := bot.NewMessage(chatID, commentsEn["ChoosePeriodType"])
+				resp.ReplyMarkup = daysOrHours
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(Back),
+			wantErr: false,
+		},
+		{
+			name: "10. Error on sending keeps the state",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateMainMenu}, nil)
+				resp := bot.NewMessage(chatID, commentsEn["DiffPlaceAccepted"])
+				resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, someErr)
+			},
+			upd:     newUpdate(WeatherElsewhere),
+			wantErr: true,
+		},
+		{
+			name: "11. Help in the middle of a conversation keeps the state",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateChoosingDays}, nil)
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, NewCommandRegistry().Help(types.ScopeAllPrivateChats, ""))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(Help),
+			wantErr: false,
+		},
+		{
+			name: "12. Too many days typed while choosing days",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateChoosingDays}, nil)
+				m.br.EXPECT().GetDaysKeyboard().Return(days)
+				resp := bot.NewMessage(chatID, fmt.Sprintf(commentsEn["DaysOutOfRange"], types.MaxForecastDays))
+				resp.ReplyMarkup = days
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("next 30 days"),
+			wantErr: false,
+		},
+		{
+			name: "13. Conversation changed meanwhile is saved on top of the newer state",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateAwaitingCity,
+					History: []types.ConversationState{types.StateMainMenu},
+				}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Berlin").Return(botLoc, nil)
+				m.br.EXPECT().GetDaysOrHoursKeyboard().Return(daysOrHours)
+				m.ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, botLoc).Return(nil)
+				resp := bot.NewMessage(chatID, commentsEn["CoordsAccepted"])
+				resp.ReplyMarkup = daysOrHours
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				gomock.InOrder(
+					m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+						State:   types.StateChoosingPeriodType,
+						History: []types.ConversationState{types.StateMainMenu, types.StateAwaitingCity},
+					}).Return(types.ErrConversationChanged),
+					m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+						State:   types.StateChoosingDays,
+						History: []types.ConversationState{types.StateMainMenu, types.StateChoosingPeriodType},
+						Version: 3,
+					}, nil),
+					m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+						State:   types.StateChoosingPeriodType,
+						History: []types.ConversationState{types.StateMainMenu},
+						Version: 3,
+					}).Return(nil),
+				)
+			},
+			upd:     newUpdate("Berlin"),
+			wantErr: false,
+		},
+		{
+			name: "14. Query typed while awaiting a city is taken for the city name",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateAwaitingCity,
+					History: []types.ConversationState{types.StateMainMenu},
+				}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "tomorrow").Return(&bot.Location{}, nil)
+				m.br.EXPECT().GetBackToMainMenuKeyboard().Return(backToMainMenu)
+				resp := bot.NewMessage(chatID, commentsEn["TryAgain"])
+				resp.ReplyMarkup = backToMainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("tomorrow"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc:  mock.NewMockBotClient(ctrl),
+				lr:  mock.NewMockLocationRepo(ctrl),
+				ulr: mock.NewMockUserLocationRepo(ctrl),
+				ur:  mock.NewMockUserDataRepo(ctrl),
+				br:  mock.NewMockBotUIRepo(ctrl),
+				cr:  mock.NewMockConversationRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), m.br, m.lr, m.ulr, m.ur).
+				WithConversations(m.cr).
+				WithGrammars(EnglishGrammar{})
+
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInputOf_Periods(t *testing.T) {
+	tests := []struct {
+		text string
+		want input
+	}{
+		{"36 hours", inputHours},
+		{"1 hour", inputHours},
+		{"12h", inputHours},
+		{"500 hours", inputHours},
+		{"9 days", inputDays},
+		{"Next 2 days", inputDays},
+		{"3d", inputDays},
+		{CurrentWeather, inputNow},
+		{"Berlin", inputText},
+		{"2 weeks", inputText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := inputOf(&bot.Message{Text: tt.text}); got != tt.want {
+				t.Errorf("inputOf(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"weather-or-not-bot/internal/fakebotapi"
+	"weather-or-not-bot/internal/repository"
+	"weather-or-not-bot/internal/service/mock"
+
+	"github.com/golang/mock/gomock"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// TestConversation runs a conversation against the fake Bot API, so that real JSON goes both ways.
+func TestConversation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fake := fakebotapi.NewServer("123:fake_token")
+	defer fake.Close()
+
+	api, err := fake.NewBotAPI()
+	if err != nil {
+		t.Fatalf("cannot create bot API: %v", err)
+	}
+
+	chat := &bot.Chat{ID: 555, Type: "private"}
+	user := &bot.User{ID: 777, FirstName: "John", UserName: "the_john", LanguageCode: "en"}
+	for i, text := range []string{Start, WeatherElsewhere, Stop} {
+		fake.PushUpdate(bot.Update{Message: &bot.Message{MessageID: i + 1, From: user, Chat: chat, Text: text}})
+	}
+
+	offsets := mock.NewMockUpdateOffsetRepo(ctrl)
+	offsets.EXPECT().GetUpdateOffset(gomock.Any(), fake.Self().ID).Return(0, nil)
+	offsets.EXPECT().SaveUpdateOffset(gomock.Any(), fake.Self().ID, gomock.Any()).Return(nil).Times(3)
+
+	ur := mock.NewMockUserDataRepo(ctrl)
+	ur.EXPECT().AddUserIfNotExists(gomock.Any(), user).Return(nil)
+
+	botCmd := NewBotCmd(api)
+	s := NewMessageService(botCmd, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), repository.NewBotUIRepo(),
+		mock.NewMockLocationRepo(ctrl), mock.NewMockUserLocationRepo(ctrl), ur)
+
+	updates, err := botCmd.ListenForPolling(ctx, offsets, nil, 1)
+	if err != nil {
+		t.Fatalf("ListenForPolling() unexpected error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case upd := <-updates:
+			if err := s.HandleNewMessage(ctx, &upd); err != nil {
+				t.Fatalf("HandleNewMessage() unexpected error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for updates")
+		}
+	}
+
+	sent := fake.CallsTo("sendMessage")
+	if len(sent) != 3 {
+		t.Fatalf("got %d sent messages, want 3", len(sent))
+	}
+
+	wants := []struct {
+		text   string
+		markup string
+	}{
+		{commentsEn["DefaultMessage"], WeatherHere},
+		{commentsEn["DiffPlaceAccepted"], `"hide_keyboard":true`},
+		{commentsEn["End"], `"hide_keyboard":true`},
+	}
+	for i, want := range wants {
+		if got := sent[i].Params.Get("chat_id"); got != "555" {
+			t.Errorf("message %d chat_id = %s, want 555", i, got)
+		}
+		if got := sent[i].Params.Get("text"); !strings.HasPrefix(got, want.text) {
+			t.Errorf("message %d text = %q, want prefix %q", i, got, want.text)
+		}
+		if got := sent[i].Params.Get("reply_markup"); !strings.Contains(got, want.markup) {
+			t.Errorf("message %d reply_markup = %s, want it to contain %s", i, got, want.markup)
+		}
+	}
+}
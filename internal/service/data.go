@@ -48,6 +48,49 @@ var commentsEn = map[string]string{
 	"End":               "Bye! Have a nice day!",
 	"Unknown":           "Sorry, did not quite get you.",
 	"TryAgain":          "Sorry, the place with such name was not found. Please try again.",
+	"InboxOff":          "The inbox is off, there is nothing to replay.",
+	"Replayed":          "%d dead-lettered updates will be handled again shortly.",
+	"UsageHourly":       "Please give the number of hours from 1 to %d and a city if you like, e.g. /hourly 36 Paris.",
+	"UsageDaily":        "Please give the number of days from 1 to %d and a city if you like, e.g. /daily 7 Berlin.",
+	"UsageWeather":      "Please give the latitude and the longitude, e.g. /weather 52.52,13.40.",
+	"NoRecentLocation":  "I don't know where you are yet. Please share your location or name a city, e.g. /now Berlin.",
+	"CityNotFound":      "Sorry, the place '%s' was not found.",
+	"ButtonExpired":     "Sorry, this button does not work anymore.",
+	"GroupAdminsOnly":   "Sorry, only admins of the group can change its settings.",
+	"UsageSetLocation":  "Please give the city, e.g. /setlocation Lisbon.",
+	"LocationTooLong":   "Sorry, the name of the city must be at most %d characters long.",
+	"UsageSetLanguage":  "Please give the two-letter language code, e.g. /setlanguage pt.",
+	"GroupLocationSet":  "Done! Forecasts here are for %s unless asked otherwise.",
+	"GroupLanguageSet":  "Done! Weather descriptions and /help here come in '%s' now, the rest stays in English.",
+	"FavoritesOff":      "Sorry, favorites are not available.",
+	"UsageSave":         "Please give a one-word name and a city if you like, e.g. /save home or /save office Berlin.",
+	"UsageRename":       "Please give the old and the new name, e.g. /rename home flat.",
+	"UsageDelete":       "Please give the name, e.g. /delete office.",
+	"FavoritesFull":     "Sorry, you can have up to %d favorites. Please /delete one first.",
+	"FavoriteSaved":     "Saved as '%s'! Tap it in the menu any time.",
+	"FavoriteRenamed":   "'%s' is now '%s'.",
+	"RenameFailed":      "Sorry, there is no favorite '%s' or '%s' is taken already.",
+	"FavoriteDeleted":   "'%s' is gone.",
+	"FavoriteNotFound":  "Sorry, there is no favorite '%s'.",
+	"FavoriteChosen":    "%s it is! Please choose the forecast period.",
+	"Favorites":         "Your favorites:",
+	"NoFavorites":       "You have no favorites yet. Save one with /save home.",
+	"History":           "Recently you asked about these places:",
+	"NoHistory":         "No places yet. Share your location or name a city first.",
+	"HistoryNotFound":   "Sorry, '%s' is not among your recent places.",
+	"HistoryChosen":     "%s again! Please choose the forecast period.",
+	"HoursOutOfRange":   "Sorry, I can only look from 1 to %d hours ahead, e.g. 36 hours or 12h.",
+	"UsageCompare":      "Please give 2 to %d cities separated by commas and the number of days up to %d if you like, e.g. /compare London, Paris, Rome 5.",
+	"NothingForecast":   "Sorry, there is no forecast for that time yet.",
+	"ForgetAsk":         "I will delete your profile, the places you asked about, your favorites and our conversation for good. Are you sure?",
+	"Forgotten":         "Done, I have forgotten you: %s. Send /start any time to begin anew.",
+	"ForgottenRows":     "%d profile, %d locations, %d favorites, %d conversation, %d group settings changed by you and %d stored messages",
+	"UsagePurge":        "Please give the Telegram ID of the user, e.g. /purge 123456.",
+	"Purged":            "User %d is forgotten: %s.",
+	"DaysOutOfRange":    "Sorry, I can only look from 1 to %d days ahead, e.g. 9 days or next 2 days.",
+	"InlineNow":         "Now in %s",
+	"InlineHours":       "Next 24 hours in %s",
+	"InlineDays":        "Next 5 days in %s",
 	"AtMyLocation":      "Weather at my location",
 	"AtADiffPlace":      "Weather elsewhere",
 	"Back0":             "< Back",
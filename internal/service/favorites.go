@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// Commands managing favorites.
+const (
+	SaveCmd      = "save"
+	FavoritesCmd = "favorites"
+	RenameCmd    = "rename"
+	DeleteCmd    = "delete"
+)
+
+// FavoriteMark starts the text of favorite buttons in the main menu, followed by the name.
+const FavoriteMark = "⭐ "
+
+// maxFavorites keeps the main menu small enough to fit the screen.
+const maxFavorites = 9
+
+var favoriteCommands = map[string]bool{SaveCmd: true, FavoritesCmd: true, RenameCmd: true, DeleteCmd: true}
+
+// favoriteNamePattern matches names that fit a button, e.g. 'home' or 'client-2'.
+var favoriteNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// WithFavorites lets users save locations under names and pick them from the main menu.
+func (s *MessageService) WithFavorites(favorites FavoriteRepo) *MessageService {
+	s.favorites = favorites
+	return s
+}
+
+func isFavoriteCommand(req *bot.Message) bool {
+	return favoriteCommands[req.Command()]
+}
+
+// mainMenu returns the main menu keyboard with a button for every favorite of the user.
+func (s *MessageService) mainMenu(ctx context.Context, userID int) bot.ReplyKeyboardMarkup {
+	if s.favorites == nil {
+		return s.botRepo.GetMainMenuKeyboard()
+	}
+
+	favorites, err := s.favorites.ListFavorites(ctx, userID)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).Warn("cannot list favorites, showing the menu without them")
+		return s.botRepo.GetMainMenuKeyboard()
+	}
+
+	names := make([]string, 0, len(favorites))
+	for _, f := range favorites {
+		names = append(names, f.Name)
+	}
+
+	return s.botRepo.GetMainMenuKeyboard(names...)
+}
+
+// handleFavorite makes the tapped favorite the current location and tells whether there is such a favorite.
+func (s *MessageService) handleFavorite(ctx context.Context, req *bot.Message) (bool, error) {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	if s.favorites == nil {
+		return false, s.replyWithMenu(ctx, req, commentsEn["FavoritesOff"])
+	}
+
+	name := strings.TrimPrefix(req.Text, FavoriteMark)
+	favorite, err := s.favorites.GetFavorite(ctx, req.From.ID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, s.replyWithMenu(ctx, req, fmt.Sprintf(commentsEn["FavoriteNotFound"], name))
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	loc, err := botLocation(name, favorite.Coordinates())
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	err = s.usrLocRepo.AddUserLocationByCoordinates(ctx, req.From.ID, loc)
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	resp := bot.NewMessage(req.Chat.ID, fmt.Sprintf(commentsEn["FavoriteChosen"], name))
+	resp.ReplyMarkup = s.periodTypeKeyboard(loc)
+
+	_, err = s.send(ctx, resp)
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return true, nil
+}
+
+// handleFavoriteCommand saves, lists, renames or deletes favorites, showing the main menu with them afterwards.
+func (s *MessageService) handleFavoriteCommand(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	if s.favorites == nil {
+		return s.replyWithMenu(ctx, req, commentsEn["FavoritesOff"])
+	}
+
+	args := strings.Fields(req.CommandArguments())
+
+	var (
+		text string
+		err  error
+	)
+	switch req.Command() {
+	case SaveCmd:
+		text, err = s.saveFavorite(ctx, req, args)
+	case RenameCmd:
+		text, err = s.renameFavorite(ctx, req, args)
+	case DeleteCmd:
+		text, err = s.deleteFavorite(ctx, req, args)
+	default:
+		text, err = s.listFavorites(ctx, req)
+	}
+
+	var usage usageError
+	if errors.As(err, &usage) {
+		text = usage.Error()
+	} else if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.replyWithMenu(ctx, req, text)
+}
+
+// saveFavorite reads '/save home' for the recent location or '/save office Berlin' for a city.
+func (s *MessageService) saveFavorite(ctx context.Context, req *bot.Message, args []string) (string, error) {
+	if len(args) == 0 || !favoriteNamePattern.MatchString(args[0]) {
+		return "", usageError(commentsEn["UsageSave"])
+	}
+	name, city := args[0], strings.Join(args[1:], " ")
+
+	favorites, err := s.favorites.ListFavorites(ctx, req.From.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(favorites) >= maxFavorites && !hasFavorite(favorites, name) {
+		return "", usageError(fmt.Sprintf(commentsEn["FavoritesFull"], maxFavorites))
+	}
+
+	loc, err := s.locationOf(ctx, req, &forecastQuery{city: city})
+	if err != nil {
+		return "", err
+	}
+
+	err = s.favorites.SaveFavorite(ctx, req.From.ID, name, loc)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(commentsEn["FavoriteSaved"], name), nil
+}
+
+func (s *MessageService) listFavorites(ctx context.Context, req *bot.Message) (string, error) {
+	favorites, err := s.favorites.ListFavorites(ctx, req.From.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(favorites) == 0 {
+		return commentsEn["NoFavorites"], nil
+	}
+
+	lines := []string{commentsEn["Favorites"]}
+	for _, f := range favorites {
+		lines = append(lines, FavoriteMark+f.Name)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *MessageService) renameFavorite(ctx context.Context, req *bot.Message, args []string) (string, error) {
+	if len(args) != 2 || !favoriteNamePattern.MatchString(args[0]) || !favoriteNamePattern.MatchString(args[1]) {
+		return "", usageError(commentsEn["UsageRename"])
+	}
+
+	renamed, err := s.favorites.RenameFavorite(ctx, req.From.ID, args[0], args[1])
+	if err != nil {
+		return "", err
+	}
+
+	if !renamed {
+		return fmt.Sprintf(commentsEn["RenameFailed"], args[0], args[1]), nil
+	}
+
+	return fmt.Sprintf(commentsEn["FavoriteRenamed"], args[0], args[1]), nil
+}
+
+func (s *MessageService) deleteFavorite(ctx context.Context, req *bot.Message, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", usageError(commentsEn["UsageDelete"])
+	}
+
+	deleted, err := s.favorites.DeleteFavorite(ctx, req.From.ID, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	if !deleted {
+		return fmt.Sprintf(commentsEn["FavoriteNotFound"], args[0]), nil
+	}
+
+	return fmt.Sprintf(commentsEn["FavoriteDeleted"], args[0]), nil
+}
+
+// replyWithMenu answers the message with text and the main menu.
+func (s *MessageService) replyWithMenu(ctx context.Context, req *bot.Message, text string) error {
+	resp := bot.NewMessage(req.Chat.ID, text)
+	resp.ReplyMarkup = s.mainMenu(ctx, req.From.ID)
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+func hasFavorite(favorites []types.Favorite, name string) bool {
+	for _, f := range favorites {
+		if f.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// botLocation parses the stored coordinates of the named place.
+func botLocation(name string, c *types.UserCoordinates) (*bot.Location, error) {
+	lat, err := strconv.ParseFloat(c.Latitude, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed latitude of '%s'", name)
+	}
+
+	long, err := strconv.ParseFloat(c.Longitude, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed longitude of '%s'", name)
+	}
+
+	return &bot.Location{Latitude: lat, Longitude: long}, nil
+}
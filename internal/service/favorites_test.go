@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleFavorites(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	uLoc := &types.UserCoordinates{LocationID: 31415, Latitude: "12.32", Longitude: "45.16"}
+	home := types.Favorite{Name: "home", Latitude: "52.520000", Longitude: "13.400000"}
+	office := types.Favorite{Name: "office", Latitude: "48.856600", Longitude: "2.352200"}
+
+	mainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("main_menu")))
+	daysOrHours := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("days_or_hours")))
+
+	newUpdate := func(text string) *bot.Update {
+		var entities []bot.MessageEntity
+		if text[0] == '/' {
+			length := len(text)
+			for i, r := range text {
+				if r == ' ' {
+					length = i
+					break
+				}
+			}
+			entities = append(entities, bot.MessageEntity{Type: "bot_command", Length: length})
+		}
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{MessageID: 11, Text: text, Entities: &entities, From: user, Chat: &bot.Chat{ID: chatID}}}
+	}
+	withMenu := func(text string) bot.MessageConfig {
+		resp := bot.NewMessage(chatID, text)
+		resp.ReplyMarkup = mainMenu
+		return resp
+	}
+
+	type mocks struct {
+		bc  *mock.MockBotClient
+		br  *mock.MockBotUIRepo
+		ulr *mock.MockUserLocationRepo
+		fr  *mock.MockFavoriteRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Tapped favorite becomes the current location",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().GetFavorite(gomock.Any(), user.ID, "home").Return(&home, nil)
+				m.ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, &bot.Location{Latitude: 52.52, Longitude: 13.4}).Return(nil)
+				m.br.EXPECT().GetDaysOrHoursKeyboard().Return(daysOrHours)
+				resp := bot.NewMessage(chatID, fmt.Sprintf(commentsEn["FavoriteChosen"], "home"))
+				resp.ReplyMarkup = daysOrHours
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(FavoriteMark + "home"),
+			wantErr: false,
+		},
+		{
+			name: "2. Tapped favorite is gone",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().GetFavorite(gomock.Any(), user.ID, "home").Return(nil, errors.Wrap(sql.ErrNoRows, "cannot get favorite"))
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{office}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard("office").Return(mainMenu)
+				m.bc.EXPECT().Send(withMenu(fmt.Sprintf(commentsEn["FavoriteNotFound"], "home"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(FavoriteMark + "home"),
+			wantErr: false,
+		},
+		{
+			name: "3. Recent location saved as a favorite",
+			prepare: func(m mocks) {
+				gomock.InOrder(
+					m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{office}, nil),
+					m.ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil),
+					m.fr.EXPECT().SaveFavorite(gomock.Any(), user.ID, "home", uLoc).Return(nil),
+					m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{office, home}, nil),
+					m.br.EXPECT().GetMainMenuKeyboard("office", "home").Return(mainMenu),
+					m.bc.EXPECT().Send(withMenu(fmt.Sprintf(commentsEn["FavoriteSaved"], "home"))).Return(bot.Message{}, nil),
+				)
+			},
+			upd:     newUpdate("/save home"),
+			wantErr: false,
+		},
+		{
+			name: "4. Name with spaces is not taken",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return(nil, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				m.bc.EXPECT().Send(withMenu(commentsEn["UsageSave"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/save"),
+			wantErr: false,
+		},
+		{
+			name: "5. No room for another favorite",
+			prepare: func(m mocks) {
+				full := make([]types.Favorite, maxFavorites)
+				for i := range full {
+					full[i] = types.Favorite{Name: fmt.Sprintf("place%d", i)}
+				}
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return(full, nil).Times(2)
+				m.br.EXPECT().GetMainMenuKeyboard(gomock.Any()).Return(mainMenu)
+				m.bc.EXPECT().Send(withMenu(fmt.Sprintf(commentsEn["FavoritesFull"], maxFavorites))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/save home"),
+			wantErr: false,
+		},
+		{
+			name: "6. New name is taken",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().RenameFavorite(gomock.Any(), user.ID, "home", "office").Return(false, nil)
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{home, office}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard("home", "office").Return(mainMenu)
+				m.bc.EXPECT().Send(withMenu(fmt.Sprintf(commentsEn["RenameFailed"], "home", "office"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/rename home office"),
+			wantErr: false,
+		},
+		{
+			name: "7. Favorite deleted",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().DeleteFavorite(gomock.Any(), user.ID, "office").Return(true, nil)
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{home}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard("home").Return(mainMenu)
+				m.bc.EXPECT().Send(withMenu(fmt.Sprintf(commentsEn["FavoriteDeleted"], "office"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/delete office"),
+			wantErr: false,
+		},
+		{
+			name: "8. Favorites listed",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return([]types.Favorite{home, office}, nil).Times(2)
+				m.br.EXPECT().GetMainMenuKeyboard("home", "office").Return(mainMenu)
+				text := fmt.Sprintf("%s\n%shome\n%soffice", commentsEn["Favorites"], FavoriteMark, FavoriteMark)
+				m.bc.EXPECT().Send(withMenu(text)).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/favorites"),
+			wantErr: false,
+		},
+		{
+			name: "9. Error on listing favorites",
+			prepare: func(m mocks) {
+				m.fr.EXPECT().ListFavorites(gomock.Any(), user.ID).Return(nil, someErr)
+			},
+			upd:     newUpdate("/favorites"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc:  mock.NewMockBotClient(ctrl),
+				br:  mock.NewMockBotUIRepo(ctrl),
+				ulr: mock.NewMockUserLocationRepo(ctrl),
+				fr:  mock.NewMockFavoriteRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), m.br,
+				mock.NewMockLocationRepo(ctrl), m.ulr, mock.NewMockUserDataRepo(ctrl)).
+				WithFavorites(m.fr)
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// One-shot forecast commands, answered in a single message.
+const (
+	NowCmd     = "now"
+	HourlyCmd  = "hourly"
+	DailyCmd   = "daily"
+	WeatherCmd = "weather"
+)
+
+const (
+	defaultForecastHours = 24
+	defaultForecastDays  = 5
+)
+
+var forecastCommands = map[string]bool{NowCmd: true, HourlyCmd: true, DailyCmd: true, WeatherCmd: true, CompareCmd: true}
+
+// forecastQuery is a forecast command with its arguments.
+type forecastQuery struct {
+	command string
+	period  types.Period
+	city    string
+	coords  *types.UserCoordinates
+}
+
+// usageError is a mistake in a command the user is told about.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func isForecastCommand(req *bot.Message) bool {
+	return forecastCommands[req.Command()]
+}
+
+// parseForecastCommand reads commands like "/now Berlin", "/hourly 36 Paris", "/daily 7" or "/weather 52.52,13.40".
+func parseForecastCommand(command, args string) (*forecastQuery, error) {
+	args = strings.TrimSpace(args)
+	q := &forecastQuery{command: command}
+
+	switch command {
+	case NowCmd:
+		q.period, q.city = types.Now(), args
+	case HourlyCmd:
+		hours, city, err := splitLength(args, defaultForecastHours, types.MaxForecastHours)
+		if err != nil {
+			return nil, usageError(fmt.Sprintf(commentsEn["UsageHourly"], types.MaxForecastHours))
+		}
+		q.period, q.city = types.Hours(hours), city
+	case DailyCmd:
+		days, city, err := splitLength(args, defaultForecastDays, types.MaxForecastDays)
+		if err != nil {
+			return nil, usageError(fmt.Sprintf(commentsEn["UsageDaily"], types.MaxForecastDays))
+		}
+		q.period, q.city = types.Days(days), city
+	case WeatherCmd:
+		coords, err := parseCoordinates(args)
+		if err != nil {
+			return nil, usageError(commentsEn["UsageWeather"])
+		}
+		q.period, q.coords = types.Now(), coords
+	default:
+		return nil, errors.Errorf("unknown forecast command '%s'", command)
+	}
+
+	return q, nil
+}
+
+// splitLength reads an optional number of hours or days followed by an optional city name.
+func splitLength(args string, byDefault, max int) (int, string, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return byDefault, "", nil
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return byDefault, args, nil
+	}
+
+	if n < 1 || n > max {
+		return 0, "", errors.Errorf("%d is out of range from 1 to %d", n, max)
+	}
+
+	return n, strings.Join(fields[1:], " "), nil
+}
+
+// parseCoordinates reads coordinates like "52.52,13.40".
+func parseCoordinates(s string) (*types.UserCoordinates, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return nil, errors.Errorf("'%s' are not coordinates", s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, errors.Errorf("'%s' is not a latitude", parts[0])
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, errors.Errorf("'%s' is not a longitude", parts[1])
+	}
+
+	return coordinatesOf(&bot.Location{Latitude: lat, Longitude: lon}), nil
+}
+
+func coordinatesOf(loc *bot.Location) *types.UserCoordinates {
+	return &types.UserCoordinates{
+		Latitude:  fmt.Sprintf("%f", loc.Latitude),
+		Longitude: fmt.Sprintf("%f", loc.Longitude),
+	}
+}
+
+func (s *MessageService) handleForecastCommand(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	if req.Command() == CompareCmd {
+		return s.handleCompare(ctx, req)
+	}
+
+	q, err := parseForecastCommand(req.Command(), req.CommandArguments())
+	var usage usageError
+	if errors.As(err, &usage) {
+		return s.reply(ctx, req, usage.Error())
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.answerForecastQuery(ctx, req, q)
+}
+
+// answerForecastQuery replies with the forecast the query asks for, or tells what is wrong with it.
+func (s *MessageService) answerForecastQuery(ctx context.Context, req *bot.Message, q *forecastQuery) error {
+	var usage usageError
+
+	loc, err := s.locationOf(ctx, req, q)
+	if errors.As(err, &usage) {
+		return s.reply(ctx, req, usage.Error())
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	wr, err := s.forecast.GetForecast(ctx, loc, q.period)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.reply(ctx, req, s.formatPeriod(ctx, wr, q.period))
+}
+
+// locationOf returns the place the forecast is asked for: given coordinates,
+// a city by name, the default location of the group or the location the user shared or named last.
+func (s *MessageService) locationOf(ctx context.Context, req *bot.Message, q *forecastQuery) (*types.UserCoordinates, error) {
+	if q.coords != nil {
+		return q.coords, nil
+	}
+
+	if q.city == "" && isGroupChat(req.Chat) {
+		if settings := s.groupOf(ctx, req.Chat); settings.HasLocation() {
+			return settings.Coordinates(), nil
+		}
+	}
+
+	if q.city == "" {
+		loc, err := s.usrLocRepo.GetUserRecentLocation(ctx, req.From.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usageError(commentsEn["NoRecentLocation"])
+		}
+		return loc, err
+	}
+
+	loc, err := s.locRepo.GetCoordinatesByCityName(ctx, q.city)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && loc.Latitude == 0 && loc.Longitude == 0) {
+		return nil, usageError(fmt.Sprintf(commentsEn["CityNotFound"], q.city))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return coordinatesOf(loc), nil
+}
+
+// reply answers the message with text, leaving the keyboard as it is.
+// In groups the answer is a reply, so that it is clear who it is for.
+func (s *MessageService) reply(ctx context.Context, req *bot.Message, text string) error {
+	resp := bot.NewMessage(req.Chat.ID, text)
+	if isGroupChat(req.Chat) {
+		resp.ReplyToMessageID = req.MessageID
+	}
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestParseForecastCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		args      string
+		want      *forecastQuery
+		wantUsage bool
+	}{
+		{"1. Now at a city", NowCmd, "Berlin", &forecastQuery{command: NowCmd, period: types.Now(), city: "Berlin"}, false},
+		{"2. Now at the recent location", NowCmd, "", &forecastQuery{command: NowCmd, period: types.Now()}, false},
+		{"3. Hours and a city with spaces", HourlyCmd, "36 New York", &forecastQuery{command: HourlyCmd, period: types.Hours(36), city: "New York"}, false},
+		{"4. Default hours", HourlyCmd, "Paris", &forecastQuery{command: HourlyCmd, period: types.Hours(24), city: "Paris"}, false},
+		{"5. Too many hours", HourlyCmd, "121 Paris", nil, true},
+		{"6. Days at the recent location", DailyCmd, "7", &forecastQuery{command: DailyCmd, period: types.Days(7)}, false},
+		{"7. Days between periods", DailyCmd, "9", &forecastQuery{command: DailyCmd, period: types.Days(9)}, false},
+		{"8. No days", DailyCmd, "0", nil, true},
+		{"9. Coordinates", WeatherCmd, "52.52, 13.40", &forecastQuery{command: WeatherCmd, period: types.Now(),
+			coords: &types.UserCoordinates{Latitude: "52.520000", Longitude: "13.400000"}}, false},
+		{"10. Latitude out of range", WeatherCmd, "91,13.40", nil, true},
+		{"11. Not coordinates", WeatherCmd, "Berlin", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseForecastCommand(tt.command, tt.args)
+
+			var usage usageError
+			if errors.As(err, &usage) != tt.wantUsage {
+				t.Errorf("parseForecastCommand() error = %v, wantUsage %v", err, tt.wantUsage)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseForecastCommand() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageService_HandleForecastCommand(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	uLoc := &types.UserCoordinates{LocationID: 31415, Latitude: "12.32", Longitude: "45.16"}
+	wr := &types.FullWeatherReport{CityName: "some_location"}
+
+	newUpdate := func(command, args string) *bot.Update {
+		text := "/" + command
+		if args != "" {
+			text += " " + args
+		}
+		entities := []bot.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command) + 1}}
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{
+			MessageID: 11, Text: text, Entities: &entities, From: user, Chat: &bot.Chat{ID: chatID},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Now at a city",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Berlin").Return(&bot.Location{Latitude: 52.52, Longitude: 13.4}, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), &types.UserCoordinates{Latitude: "52.520000", Longitude: "13.400000"}, types.Now()).Return(wr, nil)
+				f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				bc.EXPECT().Send(bot.NewMessage(chatID, "now_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(NowCmd, "Berlin"),
+			wantErr: false,
+		},
+		{
+			name: "2. City not found",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Nowhere").Return(&bot.Location{}, errors.Wrap(sql.ErrNoRows, "cannot get coordinates"))
+				bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["CityNotFound"], "Nowhere"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(HourlyCmd, "36 Nowhere"),
+			wantErr: false,
+		},
+		{
+			name: "3. Days at the recent location",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Days(7)).Return(wr, nil)
+				f.EXPECT().FormatDays(gomock.Any(), wr, 7).Return("days_report")
+				bc.EXPECT().Send(bot.NewMessage(chatID, "days_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(DailyCmd, "7"),
+			wantErr: false,
+		},
+		{
+			name: "4. No recent location",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(nil, errors.Wrap(sql.ErrNoRows, "cannot get user's recent location"))
+				bc.EXPECT().Send(bot.NewMessage(chatID, commentsEn["NoRecentLocation"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(DailyCmd, ""),
+			wantErr: false,
+		},
+		{
+			name: "5. Hours out of range",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["UsageHourly"], types.MaxForecastHours))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(HourlyCmd, "500"),
+			wantErr: false,
+		},
+		{
+			name: "6. Error on getting a forecast at coordinates",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo) {
+				fc.EXPECT().GetForecast(gomock.Any(), &types.UserCoordinates{Latitude: "52.520000", Longitude: "13.400000"}, types.Now()).Return(nil, someErr)
+			},
+			upd:     newUpdate(WeatherCmd, "52.52,13.40"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bc := mock.NewMockBotClient(ctrl)
+			fc := mock.NewMockForecastClient(ctrl)
+			f := mock.NewMockReportFormatter(ctrl)
+			lr := mock.NewMockLocationRepo(ctrl)
+			ulr := mock.NewMockUserLocationRepo(ctrl)
+
+			tt.prepare(bc, fc, f, lr, ulr)
+
+			s := NewMessageService(bc, fc, f, mock.NewMockBotUIRepo(ctrl), lr, ulr, mock.NewMockUserDataRepo(ctrl))
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
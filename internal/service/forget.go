@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// ForgetCmd asks to delete everything the bot keeps about the user.
+const ForgetCmd = "forget"
+
+// ForgetMe is the button confirming that the user wants to be forgotten.
+const ForgetMe = "🗑 Forget me"
+
+// handleForget tells what is going to be deleted and asks to confirm.
+func (s *MessageService) handleForget(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	resp := bot.NewMessage(req.Chat.ID, commentsEn["ForgetAsk"])
+	resp.ReplyMarkup = s.botRepo.GetForgetKeyboard()
+
+	_, err := s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+// handleForgetConfirmed deletes everything kept about the user and tells how much it was.
+// The conversation is deleted too, so it must not be saved afterwards.
+func (s *MessageService) handleForgetConfirmed(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	forgotten, err := s.usrRepo.ForgetUser(ctx, req.From.ID)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	resp := bot.NewMessage(req.Chat.ID, fmt.Sprintf(commentsEn["Forgotten"], forgottenSummary(forgotten)))
+	resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
+
+	_, err = s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+func forgottenSummary(f *types.ForgottenUser) string {
+	return fmt.Sprintf(commentsEn["ForgottenRows"], f.Profiles, f.Locations, f.Favorites, f.Conversations, f.Groups, f.Updates)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleForget(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(122334)
+	admin := &bot.User{ID: 1, UserName: "the_admin"}
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	forgetKeyboard := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("forget")))
+	mainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("main_menu")))
+	forgotten := &types.ForgottenUser{Profiles: 1, Locations: 12, Favorites: 2, Conversations: 1, Groups: 0, Updates: 3}
+
+	newUpdate := func(from *bot.User, text string) *bot.Update {
+		msg := &bot.Message{MessageID: 11, Text: text, From: from, Chat: &bot.Chat{ID: chatID}}
+		if strings.HasPrefix(text, "/") {
+			msg.Entities = &[]bot.MessageEntity{{Type: "bot_command", Offset: 0, Length: strings.IndexByte(text+" ", ' ')}}
+		}
+		return &bot.Update{UpdateID: 1, Message: msg}
+	}
+
+	type mocks struct {
+		bc *mock.MockBotClient
+		lr *mock.MockLocationRepo
+		ur *mock.MockUserDataRepo
+		br *mock.MockBotUIRepo
+		cr *mock.MockConversationRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Confirmation asked",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateChoosingPeriodType}, nil)
+				m.br.EXPECT().GetForgetKeyboard().Return(forgetKeyboard)
+				resp := bot.NewMessage(chatID, commentsEn["ForgetAsk"])
+				resp.ReplyMarkup = forgetKeyboard
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+				m.cr.EXPECT().SaveConversation(gomock.Any(), chatID, &types.Conversation{
+					State:   types.StateAwaitingForgetConfirmation,
+					History: []types.ConversationState{types.StateChoosingPeriodType},
+				}).Return(nil)
+			},
+			upd:     newUpdate(user, "/"+ForgetCmd),
+			wantErr: false,
+		},
+		{
+			name: "2. User forgotten and the conversation not saved again",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{
+					State:   types.StateAwaitingForgetConfirmation,
+					History: []types.ConversationState{types.StateChoosingPeriodType},
+				}, nil)
+				m.ur.EXPECT().ForgetUser(gomock.Any(), user.ID).Return(forgotten, nil)
+				resp := bot.NewMessage(chatID, fmt.Sprintf(commentsEn["Forgotten"], fmt.Sprintf(commentsEn["ForgottenRows"], 1, 12, 2, 1, 0, 3)))
+				resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(user, ForgetMe),
+			wantErr: false,
+		},
+		{
+			name: "3. Error on forgetting user",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateAwaitingForgetConfirmation}, nil)
+				m.ur.EXPECT().ForgetUser(gomock.Any(), user.ID).Return(nil, someErr)
+			},
+			upd:     newUpdate(user, ForgetMe),
+			wantErr: true,
+		},
+		{
+			name: "4. Purge by a user, answered like an unknown command",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateNone}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, commentsEn["Unknown"])
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(user, "/"+PurgeCmd+" 2"),
+			wantErr: false,
+		},
+		{
+			name: "5. Purge with no user ID",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateNone}, nil)
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, commentsEn["UsagePurge"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(admin, "/"+PurgeCmd+" the_john"),
+			wantErr: false,
+		},
+		{
+			name: "6. Error on purging user",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateNone}, nil)
+				m.ur.EXPECT().ForgetUser(gomock.Any(), user.ID).Return(nil, someErr)
+			},
+			upd:     newUpdate(admin, fmt.Sprintf("/%s %d", PurgeCmd, user.ID)),
+			wantErr: true,
+		},
+		{
+			name: "7. User purged by an admin",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateMainMenu}, nil)
+				m.ur.EXPECT().ForgetUser(gomock.Any(), user.ID).Return(forgotten, nil)
+				text := fmt.Sprintf(commentsEn["Purged"], user.ID, fmt.Sprintf(commentsEn["ForgottenRows"], 1, 12, 2, 1, 0, 3))
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, text)).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(admin, fmt.Sprintf("/%s %d", PurgeCmd, user.ID)),
+			wantErr: false,
+		},
+		{
+			name: "8. Confirmation not asked for is not taken",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateMainMenu}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s %s", commentsEn["Unknown"], commentsEn["ChooseLocation"]))
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(user, ForgetMe),
+			wantErr: false,
+		},
+		{
+			name: "9. Confirmation not taken with no state stored",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateNone}, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s %s", commentsEn["Unknown"], commentsEn["ChooseLocation"]))
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(user, ForgetMe),
+			wantErr: false,
+		},
+		{
+			name: "10. Anything else while awaiting the confirmation asks for it again",
+			prepare: func(m mocks) {
+				m.cr.EXPECT().GetConversation(gomock.Any(), chatID).Return(&types.Conversation{State: types.StateAwaitingForgetConfirmation}, nil)
+				m.br.EXPECT().GetForgetKeyboard().Return(forgetKeyboard)
+				resp := bot.NewMessage(chatID, fmt.Sprintf("%s %s", commentsEn["Unknown"], commentsEn["ForgetAsk"]))
+				resp.ReplyMarkup = forgetKeyboard
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(user, "Berlin"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc: mock.NewMockBotClient(ctrl),
+				lr: mock.NewMockLocationRepo(ctrl),
+				ur: mock.NewMockUserDataRepo(ctrl),
+				br: mock.NewMockBotUIRepo(ctrl),
+				cr: mock.NewMockConversationRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), m.br, m.lr,
+				mock.NewMockUserLocationRepo(ctrl), m.ur).WithAdmins([]int{admin.ID}).WithConversations(m.cr)
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
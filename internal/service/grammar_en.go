@@ -0,0 +1,142 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"weather-or-not-bot/internal/types"
+)
+
+// EnglishGrammar reads queries like 'tomorrow in Paris', 'weekend Berlin' or 'Friday evening Oslo'.
+type EnglishGrammar struct{}
+
+var (
+	englishDays = map[string]types.TimeWindow{
+		"today":    {},
+		"tonight":  {Part: &types.Tonight},
+		"tomorrow": {From: 1, To: 1},
+		"weekend":  {OnWeekday: true, Weekday: time.Saturday, To: 1},
+		"week":     {To: 6},
+	}
+	englishParts = map[string]*types.DayPart{
+		"morning":   &types.Morning,
+		"afternoon": &types.Afternoon,
+		"evening":   &types.Evening,
+		"night":     &types.Night,
+	}
+	// englishLinks are the words that may come before the time, as in 'on Friday' or 'in the evening'.
+	englishLinks = map[string]bool{"on": true, "in": true, "at": true, "for": true, "the": true, "this": true}
+	// englishFillers are the words around the place that are not part of its name, as in 'weather in Paris'.
+	englishFillers = map[string]bool{
+		"weather": true, "forecast": true, "in": true, "at": true, "for": true, "what's": true, "whats": true,
+		"what": true, "is": true, "how": true, "about": true,
+	}
+)
+
+func (EnglishGrammar) Language() string {
+	return "en"
+}
+
+// Parse finds the time in the text and takes the rest for the place. Text with no time in it is no query.
+func (EnglishGrammar) Parse(text string) (*types.NaturalQuery, bool) {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '?' || r == '!'
+	})
+
+	var (
+		days *types.TimeWindow
+		part *types.DayPart
+		used = make([]bool, len(words))
+	)
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		n := 1
+
+		var window types.TimeWindow
+		switch {
+		case word == "day" && i+2 < len(words) &&
+			strings.EqualFold(words[i+1], "after") && strings.EqualFold(words[i+2], "tomorrow"):
+			window, n = types.TimeWindow{From: 2, To: 2}, 3
+		case isEnglishDay(word):
+			window = englishDay(word)
+		case englishParts[word] != nil:
+			if part != nil {
+				return nil, false
+			}
+			part = englishParts[word]
+			markEnglishTime(words, used, i, n)
+			continue
+		default:
+			continue
+		}
+
+		if days != nil {
+			return nil, false
+		}
+		days = &window
+		markEnglishTime(words, used, i, n)
+		i += n - 1
+	}
+
+	if days == nil && part == nil {
+		return nil, false
+	}
+
+	q := &types.NaturalQuery{}
+	if days != nil {
+		q.Window = *days
+	}
+	if part != nil {
+		q.Window.Part = part
+	}
+
+	var place []string
+	for i, word := range words {
+		if !used[i] {
+			place = append(place, word)
+		}
+	}
+	for len(place) > 0 && englishFillers[strings.ToLower(place[0])] {
+		place = place[1:]
+	}
+	for len(place) > 0 && englishFillers[strings.ToLower(place[len(place)-1])] {
+		place = place[:len(place)-1]
+	}
+	q.Place = strings.Join(place, " ")
+
+	return q, true
+}
+
+func isEnglishDay(word string) bool {
+	if _, ok := englishDays[word]; ok {
+		return true
+	}
+	_, ok := englishWeekday(word)
+	return ok
+}
+
+func englishDay(word string) types.TimeWindow {
+	if weekday, ok := englishWeekday(word); ok {
+		return types.TimeWindow{OnWeekday: true, Weekday: weekday}
+	}
+	return englishDays[word]
+}
+
+func englishWeekday(word string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if word == strings.ToLower(d.String()) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// markEnglishTime marks the n words of the time at i as used, together with the links right before them.
+func markEnglishTime(words []string, used []bool, i, n int) {
+	for j := i; j < i+n; j++ {
+		used[j] = true
+	}
+	for j := i - 1; j >= 0 && !used[j] && englishLinks[strings.ToLower(words[j])]; j-- {
+		used[j] = true
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"weather-or-not-bot/internal/types"
+)
+
+func TestEnglishGrammar_Parse(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   *types.NaturalQuery
+		wantOk bool
+	}{
+		{"1. Tomorrow in a city", "tomorrow in Paris", &types.NaturalQuery{Place: "Paris", Window: types.TimeWindow{From: 1, To: 1}}, true},
+		{"2. Weekend", "weekend Berlin", &types.NaturalQuery{Place: "Berlin",
+			Window: types.TimeWindow{OnWeekday: true, Weekday: time.Saturday, To: 1}}, true},
+		{"3. Weekday and a part of the day", "Friday evening Oslo", &types.NaturalQuery{Place: "Oslo",
+			Window: types.TimeWindow{OnWeekday: true, Weekday: time.Friday, Part: &types.Evening}}, true},
+		{"4. Several words and fillers", "Weather in New York the day after tomorrow?", &types.NaturalQuery{Place: "New York",
+			Window: types.TimeWindow{From: 2, To: 2}}, true},
+		{"5. Part of the day at the recent location", "in the morning", &types.NaturalQuery{
+			Window: types.TimeWindow{Part: &types.Morning}}, true},
+		{"6. Tonight", "tonight, Lisbon", &types.NaturalQuery{Place: "Lisbon", Window: types.TimeWindow{Part: &types.Tonight}}, true},
+		{"7. No time is no query", "Berlin", nil, false},
+		{"8. Two days are not understood", "today or tomorrow in Paris", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EnglishGrammar{}.Parse(tt.text)
+			if ok != tt.wantOk {
+				t.Fatalf("Parse() ok = %v, wantOk %v", ok, tt.wantOk)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
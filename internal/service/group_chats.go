@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// Group commands, for admins only.
+const (
+	SetLocationCmd = "setlocation"
+	SetLanguageCmd = "setlanguage"
+)
+
+// maxLocationNameLength is the longest group location name that can be saved, as long as city names are.
+const maxLocationNameLength = 64
+
+// groupKey is the key of the settings of the group within the context of a group message.
+type groupKey struct{}
+
+// languagePattern matches language codes the forecast provider takes, e.g. 'pt' or 'zh-tw'.
+var languagePattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]{2})?$`)
+
+// WithGroups makes the service answer in group chats only when it is addressed, as if privacy mode were on,
+// and lets group admins choose the default location of their group and the language of weather descriptions and /help.
+func (s *MessageService) WithGroups(self bot.User, groups GroupSettingsRepo) *MessageService {
+	s.self = self
+	s.groups = groups
+	return s
+}
+
+func isGroupChat(chat *bot.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// routeGroup picks the handler for a group message. Messages not meant for the bot are ignored,
+// the rest get weather descriptions and /help in the language of the group. Other replies are in English.
+func (s *MessageService) routeGroup(req *bot.Message) (string, handlerFunc) {
+	if !s.addressedToBot(req) {
+		return "ignoreGroupMessage", s.ignoreGroupMessage
+	}
+
+	var (
+		name   string
+		handle handlerFunc
+	)
+	switch {
+	case req.Command() == SetLocationCmd:
+		name, handle = "handleSetGroupLocation", s.handleSetGroupLocation
+	case req.Command() == SetLanguageCmd:
+		name, handle = "handleSetGroupLanguage", s.handleSetGroupLanguage
+	case isForecastCommand(req):
+		name, handle = "handleForecastCommand", s.handleForecastCommand
+	case req.Command() == "help":
+		name, handle = "handleHelp", s.handleHelp
+	case req.IsCommand():
+		// Commands without the bot's name reach every bot in the group, this one is someone else's.
+		return "ignoreGroupMessage", s.ignoreGroupMessage
+	default:
+		name, handle = "handleGroupMention", s.handleGroupMention
+	}
+
+	return name, func(ctx context.Context, req *bot.Message) error {
+		settings := s.groupOf(ctx, req.Chat)
+		ctx = context.WithValue(ctx, groupKey{}, settings)
+		return handle(types.ContextWithLanguage(ctx, settings.Language), req)
+	}
+}
+
+// addressedToBot tells whether a group message is meant for the bot: a command not addressed to another bot,
+// a mention of the bot or a reply to its message. These are the messages Telegram delivers in privacy mode.
+func (s *MessageService) addressedToBot(req *bot.Message) bool {
+	if req.IsCommand() {
+		cmd := req.CommandWithAt()
+		at := strings.Index(cmd, "@")
+		return at < 0 || strings.EqualFold(cmd[at+1:], s.self.UserName)
+	}
+
+	if req.ReplyToMessage != nil && req.ReplyToMessage.From != nil && s.self.ID != 0 && req.ReplyToMessage.From.ID == s.self.ID {
+		return true
+	}
+
+	_, mentioned := s.withoutMention(req)
+	return mentioned
+}
+
+// withoutMention returns the text of the message with mentions of the bot cut out and tells whether there were any.
+func (s *MessageService) withoutMention(req *bot.Message) (string, bool) {
+	if req.Entities == nil || s.self.UserName == "" {
+		return strings.TrimSpace(req.Text), false
+	}
+
+	// Entity offsets are counted in UTF-16 code units.
+	text := utf16.Encode([]rune(req.Text))
+	var (
+		rest      []uint16
+		last      int
+		mentioned bool
+	)
+	for _, e := range *req.Entities {
+		if e.Type != "mention" || e.Offset < last || e.Offset+e.Length > len(text) {
+			continue
+		}
+
+		if !strings.EqualFold(string(utf16.Decode(text[e.Offset:e.Offset+e.Length])), "@"+s.self.UserName) {
+			continue
+		}
+
+		rest = append(rest, text[last:e.Offset]...)
+		last = e.Offset + e.Length
+		mentioned = true
+	}
+	rest = append(rest, text[last:]...)
+
+	return strings.Join(strings.Fields(string(utf16.Decode(rest))), " "), mentioned
+}
+
+// groupOf returns the settings of the group, empty if there are none or they cannot be read.
+// Within the handling of a group message they are the ones read once for it.
+func (s *MessageService) groupOf(ctx context.Context, chat *bot.Chat) *types.GroupSettings {
+	if settings, ok := ctx.Value(groupKey{}).(*types.GroupSettings); ok {
+		return settings
+	}
+
+	if s.groups == nil {
+		return &types.GroupSettings{}
+	}
+
+	settings, err := s.groups.GetGroupSettings(ctx, chat.ID)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).Warn("cannot get group settings, using the defaults")
+		return &types.GroupSettings{}
+	}
+
+	return settings
+}
+
+// isGroupAdmin tells whether the sender of the message administers the group.
+func (s *MessageService) isGroupAdmin(req *bot.Message) (bool, error) {
+	member, err := s.botCmd.GetChatMember(bot.ChatConfigWithUser{ChatID: req.Chat.ID, UserID: req.From.ID})
+	if err != nil {
+		return false, errors.Wrap(err, "cannot get chat member")
+	}
+
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+func (s *MessageService) ignoreGroupMessage(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debug("Ignoring a group message not meant for the bot")
+	return nil
+}
+
+// handleGroupMention answers a mention of the bot or a reply to it with the current weather
+// at the place named in the message or at the default location of the group.
+func (s *MessageService) handleGroupMention(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	city, _ := s.withoutMention(req)
+
+	lang := types.LanguageFrom(ctx)
+	if lang == "" {
+		lang = req.From.LanguageCode
+	}
+	if nq, ok := s.naturalQuery(city, lang); ok {
+		return s.handleNaturalQuery(ctx, req, nq)
+	}
+
+	q, err := parseForecastCommand(NowCmd, city)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.answerForecastQuery(ctx, req, q)
+}
+
+func (s *MessageService) handleSetGroupLocation(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	admin, err := s.isGroupAdmin(req)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+	if !admin {
+		return s.reply(ctx, req, commentsEn["GroupAdminsOnly"])
+	}
+
+	city := strings.TrimSpace(req.CommandArguments())
+	if city == "" {
+		return s.reply(ctx, req, commentsEn["UsageSetLocation"])
+	}
+	if utf8.RuneCountInString(city) > maxLocationNameLength {
+		return s.reply(ctx, req, fmt.Sprintf(commentsEn["LocationTooLong"], maxLocationNameLength))
+	}
+
+	loc, err := s.locRepo.GetCoordinatesByCityName(ctx, city)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && loc.Latitude == 0 && loc.Longitude == 0) {
+		return s.reply(ctx, req, fmt.Sprintf(commentsEn["CityNotFound"], city))
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	err = s.groups.SaveGroupLocation(ctx, req.Chat.ID, city, coordinatesOf(loc), req.From.ID)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.reply(ctx, req, fmt.Sprintf(commentsEn["GroupLocationSet"], city))
+}
+
+func (s *MessageService) handleSetGroupLanguage(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	admin, err := s.isGroupAdmin(req)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+	if !admin {
+		return s.reply(ctx, req, commentsEn["GroupAdminsOnly"])
+	}
+
+	lang := strings.ToLower(strings.TrimSpace(req.CommandArguments()))
+	if !languagePattern.MatchString(lang) {
+		return s.reply(ctx, req, commentsEn["UsageSetLanguage"])
+	}
+
+	err = s.groups.SaveGroupLanguage(ctx, req.Chat.ID, lang, req.From.ID)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return s.reply(ctx, req, fmt.Sprintf(commentsEn["GroupLanguageSet"], lang))
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleNewMessageInGroup(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	self := bot.User{ID: 1, UserName: "wearthebot", IsBot: true}
+	chat := &bot.Chat{ID: -100123, Type: "supergroup"}
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	lisbon := &bot.Location{Latitude: 38.7223, Longitude: -9.1393}
+	wr := &types.FullWeatherReport{CityName: "Lisbon"}
+	settings := &types.GroupSettings{LocationName: "Lisbon", Latitude: "38.722300", Longitude: "-9.139300", Language: "pt"}
+
+	// newUpdate marks up commands and mentions at the start of the text the way Telegram does.
+	newUpdate := func(text string) *bot.Update {
+		var entities []bot.MessageEntity
+		first := strings.Fields(text + " ")[0]
+		switch {
+		case strings.HasPrefix(first, "/"):
+			entities = append(entities, bot.MessageEntity{Type: "bot_command", Length: len(first)})
+		case strings.HasPrefix(first, "@"):
+			entities = append(entities, bot.MessageEntity{Type: "mention", Length: len(first)})
+		}
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{MessageID: 11, Text: text, Entities: &entities, From: user, Chat: chat}}
+	}
+	newReply := func(text string) bot.MessageConfig {
+		resp := bot.NewMessage(chat.ID, text)
+		resp.ReplyToMessageID = 11
+		return resp
+	}
+
+	type mocks struct {
+		bc *mock.MockBotClient
+		fc *mock.MockForecastClient
+		f  *mock.MockReportFormatter
+		lr *mock.MockLocationRepo
+		gs *mock.MockGroupSettingsRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name:    "1. Chatter is ignored",
+			prepare: func(m mocks) {},
+			upd:     newUpdate("Lisbon is lovely this time of year"),
+			wantErr: false,
+		},
+		{
+			name:    "2. Command for another bot is ignored",
+			prepare: func(m mocks) {},
+			upd:     newUpdate("/now@otherbot Lisbon"),
+			wantErr: false,
+		},
+		{
+			name: "3. Mention is answered with the weather at the named place",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(lisbon, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(lisbon), types.Now()).Return(wr, nil)
+				m.f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				m.bc.EXPECT().Send(newReply("now_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("@WeartheBot  Lisbon"),
+			wantErr: false,
+		},
+		{
+			name: "4. Reply to the bot is answered",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(lisbon, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), coordinatesOf(lisbon), types.Now()).Return(wr, nil)
+				m.f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				m.bc.EXPECT().Send(newReply("now_report")).Return(bot.Message{}, nil)
+			},
+			upd: func() *bot.Update {
+				upd := newUpdate("Lisbon")
+				upd.Message.ReplyToMessage = &bot.Message{MessageID: 10, From: &self}
+				return upd
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "5. Command without a place uses the group location and language",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(settings, nil)
+				m.fc.EXPECT().GetForecast(inLanguage("pt"), settings.Coordinates(), types.Days(7)).Return(wr, nil)
+				m.f.EXPECT().FormatDays(inLanguage("pt"), wr, 7).Return("days_report")
+				m.bc.EXPECT().Send(newReply("days_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/daily@wearthebot 7"),
+			wantErr: false,
+		},
+		{
+			name: "6. Only admins set the group location",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.bc.EXPECT().GetChatMember(bot.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID}).Return(bot.ChatMember{Status: "member"}, nil)
+				m.bc.EXPECT().Send(newReply(commentsEn["GroupAdminsOnly"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/setlocation Lisbon"),
+			wantErr: false,
+		},
+		{
+			name: "7. Admin sets the group location",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.bc.EXPECT().GetChatMember(bot.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID}).Return(bot.ChatMember{Status: "creator"}, nil)
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(lisbon, nil)
+				m.gs.EXPECT().SaveGroupLocation(gomock.Any(), chat.ID, "Lisbon", coordinatesOf(lisbon), user.ID).Return(nil)
+				m.bc.EXPECT().Send(newReply(fmt.Sprintf(commentsEn["GroupLocationSet"], "Lisbon"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/setlocation Lisbon"),
+			wantErr: false,
+		},
+		{
+			name: "8. Wrong language code",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.bc.EXPECT().GetChatMember(bot.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID}).Return(bot.ChatMember{Status: "administrator"}, nil)
+				m.bc.EXPECT().Send(newReply(commentsEn["UsageSetLanguage"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/setlanguage portuguese"),
+			wantErr: false,
+		},
+		{
+			name: "9. Error on saving the group language",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.bc.EXPECT().GetChatMember(bot.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID}).Return(bot.ChatMember{Status: "administrator"}, nil)
+				m.gs.EXPECT().SaveGroupLanguage(gomock.Any(), chat.ID, "pt", user.ID).Return(someErr)
+			},
+			upd:     newUpdate("/setlanguage PT"),
+			wantErr: true,
+		},
+		{
+			name: "10. Group location name too long to be saved",
+			prepare: func(m mocks) {
+				m.gs.EXPECT().GetGroupSettings(gomock.Any(), chat.ID).Return(&types.GroupSettings{}, nil)
+				m.bc.EXPECT().GetChatMember(bot.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID}).Return(bot.ChatMember{Status: "creator"}, nil)
+				m.bc.EXPECT().Send(newReply(fmt.Sprintf(commentsEn["LocationTooLong"], maxLocationNameLength))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/setlocation " + strings.Repeat("Ä", maxLocationNameLength+1)),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc: mock.NewMockBotClient(ctrl),
+				fc: mock.NewMockForecastClient(ctrl),
+				f:  mock.NewMockReportFormatter(ctrl),
+				lr: mock.NewMockLocationRepo(ctrl),
+				gs: mock.NewMockGroupSettingsRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, m.fc, m.f, mock.NewMockBotUIRepo(ctrl), m.lr, mock.NewMockUserLocationRepo(ctrl), mock.NewMockUserDataRepo(ctrl)).
+				WithConversations(mock.NewMockConversationRepo(ctrl)).
+				WithGroups(self, m.gs)
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// inLanguage matches a context forecasts are asked in the language within.
+type inLanguage string
+
+func (l inLanguage) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && types.LanguageFrom(ctx) == string(l)
+}
+
+func (l inLanguage) String() string {
+	return "is a context in language " + string(l)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// HistoryCmd lists the recent locations of the user.
+const HistoryCmd = "history"
+
+// HistoryMark starts the text of recent location buttons, followed by the name.
+const HistoryMark = "🕘 "
+
+func init() {
+	pflag.Int("history_size", 5, "Number of recent locations /history offers")
+}
+
+// handleHistory offers the recent named locations of the user to pick from.
+func (s *MessageService) handleHistory(ctx context.Context, req *bot.Message) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	history, err := s.usrLocRepo.GetUserLocationHistory(ctx, req.From.ID, viper.GetInt("history_size"))
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	if len(history) == 0 {
+		return s.replyWithMenu(ctx, req, commentsEn["NoHistory"])
+	}
+
+	names := make([]string, 0, len(history))
+	for _, l := range history {
+		names = append(names, l.Name)
+	}
+
+	resp := bot.NewMessage(req.Chat.ID, commentsEn["History"])
+	resp.ReplyMarkup = s.botRepo.GetHistoryKeyboard(names...)
+
+	_, err = s.send(ctx, resp)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return nil
+}
+
+// handleRecentLocation makes the tapped recent location the current one and tells whether there is such a location.
+func (s *MessageService) handleRecentLocation(ctx context.Context, req *bot.Message) (bool, error) {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
+
+	name := strings.TrimPrefix(req.Text, HistoryMark)
+	recent, err := s.usrLocRepo.GetUserLocationByName(ctx, req.From.ID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, s.replyWithMenu(ctx, req, fmt.Sprintf(commentsEn["HistoryNotFound"], name))
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	loc, err := botLocation(name, recent.Coordinates())
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	err = s.usrLocRepo.AddUserLocationByCoordinates(ctx, req.From.ID, loc)
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	// The name is known already, so the location tops the history even before a forecast is asked for.
+	err = s.usrLocRepo.SaveUserLocationName(ctx, req.From.ID, name)
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	resp := bot.NewMessage(req.Chat.ID, fmt.Sprintf(commentsEn["HistoryChosen"], name))
+	resp.ReplyMarkup = s.periodTypeKeyboard(loc)
+
+	_, err = s.send(ctx, resp)
+	if err != nil {
+		return false, errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleHistory(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+	viper.Set("history_size", 5)
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	paris := types.RecentLocation{Name: "Paris", Latitude: "48.856600", Longitude: "2.352200"}
+	berlin := types.RecentLocation{Name: "Berlin", Latitude: "52.520000", Longitude: "13.400000"}
+
+	mainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("main_menu")))
+	historyMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("history")))
+	daysOrHours := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("days_or_hours")))
+
+	newUpdate := func(text string) *bot.Update {
+		var entities []bot.MessageEntity
+		if text[0] == '/' {
+			entities = append(entities, bot.MessageEntity{Type: "bot_command", Length: strings.IndexByte(text+" ", ' ')})
+		}
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{MessageID: 11, Text: text, Entities: &entities, From: user, Chat: &bot.Chat{ID: chatID}}}
+	}
+
+	type mocks struct {
+		bc  *mock.MockBotClient
+		br  *mock.MockBotUIRepo
+		ulr *mock.MockUserLocationRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Recent locations offered",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserLocationHistory(gomock.Any(), user.ID, 5).Return([]types.RecentLocation{paris, berlin}, nil)
+				m.br.EXPECT().GetHistoryKeyboard("Paris", "Berlin").Return(historyMenu)
+				resp := bot.NewMessage(chatID, commentsEn["History"])
+				resp.ReplyMarkup = historyMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/history"),
+			wantErr: false,
+		},
+		{
+			name: "2. No recent locations",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserLocationHistory(gomock.Any(), user.ID, 5).Return(nil, nil)
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, commentsEn["NoHistory"])
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("/history"),
+			wantErr: false,
+		},
+		{
+			name: "3. Error on getting recent locations",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserLocationHistory(gomock.Any(), user.ID, 5).Return(nil, someErr)
+			},
+			upd:     newUpdate("/history"),
+			wantErr: true,
+		},
+		{
+			name: "4. Tapped recent location becomes the current one",
+			prepare: func(m mocks) {
+				gomock.InOrder(
+					m.ulr.EXPECT().GetUserLocationByName(gomock.Any(), user.ID, "Paris").Return(&paris, nil),
+					m.ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, &bot.Location{Latitude: 48.8566, Longitude: 2.3522}).Return(nil),
+					m.ulr.EXPECT().SaveUserLocationName(gomock.Any(), user.ID, "Paris").Return(nil),
+				)
+				m.br.EXPECT().GetDaysOrHoursKeyboard().Return(daysOrHours)
+				resp := bot.NewMessage(chatID, fmt.Sprintf(commentsEn["HistoryChosen"], "Paris"))
+				resp.ReplyMarkup = daysOrHours
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(HistoryMark + "Paris"),
+			wantErr: false,
+		},
+		{
+			name: "5. Tapped recent location is unknown",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserLocationByName(gomock.Any(), user.ID, "Oslo").Return(nil, errors.Wrap(sql.ErrNoRows, "cannot get user's location by name"))
+				m.br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				resp := bot.NewMessage(chatID, fmt.Sprintf(commentsEn["HistoryNotFound"], "Oslo"))
+				resp.ReplyMarkup = mainMenu
+				m.bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate(HistoryMark + "Oslo"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc:  mock.NewMockBotClient(ctrl),
+				br:  mock.NewMockBotUIRepo(ctrl),
+				ulr: mock.NewMockUserLocationRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), m.br,
+				mock.NewMockLocationRepo(ctrl), m.ulr, mock.NewMockUserDataRepo(ctrl))
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
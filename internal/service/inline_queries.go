@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+	"weather-or-not-bot/internal/tracing"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func init() {
+	pflag.Duration("inline_cache_time", 5*time.Minute, "How long Telegram may keep answers to the same inline query")
+}
+
+// inlineCards are the forecasts offered for a place typed after the bot's name in any chat.
+var inlineCards = []struct {
+	id     string
+	title  string
+	period types.Period
+}{
+	{"now", "InlineNow", types.Now()},
+	{"24h", "InlineHours", types.Hours(24)},
+	{"5d", "InlineDays", types.Days(5)},
+}
+
+// HandleInlineQuery answers '@wearthebot Lisbon' typed in any chat with forecast cards to share.
+func (s *MessageService) HandleInlineQuery(ctx context.Context, upd *bot.Update) error {
+	iq := upd.InlineQuery
+	if iq == nil || iq.From == nil {
+		return errors.Errorf("update %d carries no inline query to handle", upd.UpdateID)
+	}
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"username": iq.From.UserName,
+		"user_id":  iq.From.ID,
+		"query":    iq.Query,
+	})
+	log.Info("Handling an inline query")
+
+	ctx, span := tracing.Start(ctx, "MessageService.handleInlineQuery", attribute.Int("user_id", iq.From.ID))
+	defer span.End()
+
+	start := time.Now()
+	err := s.handleInlineQuery(ctx, iq)
+	metrics.ObserveHandler("handleInlineQuery", start, err)
+	tracing.RecordError(ctx, err)
+
+	if err != nil {
+		return errors.Wrap(err, "cannot handle an inline query")
+	}
+
+	return nil
+}
+
+func (s *MessageService) handleInlineQuery(ctx context.Context, iq *bot.InlineQuery) error {
+	log := ctxlogrus.Extract(ctx)
+
+	city := strings.TrimSpace(iq.Query)
+	results := []interface{}{}
+
+	if city != "" {
+		loc, err := s.locRepo.GetCoordinatesByCityName(ctx, city)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(err, types.ErrOnHandling, iq.Query)
+		}
+
+		// Unknown places get no cards, the user may still be typing.
+		if err == nil && (loc.Latitude != 0 || loc.Longitude != 0) {
+			results = s.inlineResults(ctx, city, coordinatesOf(loc))
+		}
+	}
+
+	answer := bot.InlineConfig{
+		InlineQueryID: iq.ID,
+		Results:       results,
+		CacheTime:     int(viper.GetDuration("inline_cache_time").Seconds()),
+	}
+
+	err := s.botCmd.AnswerInlineQuery(answer)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, iq.Query)
+	}
+
+	log.Debugf("Answered with %d cards", len(results))
+
+	return nil
+}
+
+// inlineResults returns a card for every forecast that could be got, leaving out the failed ones.
+func (s *MessageService) inlineResults(ctx context.Context, city string, loc *types.UserCoordinates) []interface{} {
+	results := []interface{}{}
+	for _, card := range inlineCards {
+		wr, err := s.forecast.GetForecast(ctx, loc, card.period)
+		if err != nil {
+			ctxlogrus.Extract(ctx).WithError(err).Warnf("cannot get '%s' forecast for an inline card", card.period)
+			continue
+		}
+
+		results = append(results, bot.NewInlineQueryResultArticle(card.id, fmt.Sprintf(commentsEn[card.title], city), s.formatPeriod(ctx, wr, card.period)))
+	}
+
+	return results
+}
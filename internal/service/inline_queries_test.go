@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestMessageService_HandleInlineQuery(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	viper.Set("inline_cache_time", "5m")
+	defer viper.Set("inline_cache_time", nil)
+
+	user := &bot.User{ID: 122334, UserName: "the_john"}
+	lisbon := &bot.Location{Latitude: 38.7223, Longitude: -9.1393}
+	loc := coordinatesOf(lisbon)
+	wr := &types.FullWeatherReport{CityName: "Lisbon"}
+
+	newUpdate := func(query string) *bot.Update {
+		return &bot.Update{UpdateID: 1, InlineQuery: &bot.InlineQuery{ID: "iq_id", From: user, Query: query}}
+	}
+	newAnswer := func(results ...interface{}) bot.InlineConfig {
+		return bot.InlineConfig{InlineQueryID: "iq_id", Results: append([]interface{}{}, results...), CacheTime: 300}
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Cards for a known city",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(lisbon, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Now()).Return(wr, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Hours(24)).Return(wr, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Days(5)).Return(wr, nil)
+				f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				f.EXPECT().FormatHours(gomock.Any(), wr, 24).Return("hours_report")
+				f.EXPECT().FormatDays(gomock.Any(), wr, 5).Return("days_report")
+				bc.EXPECT().AnswerInlineQuery(newAnswer(
+					bot.NewInlineQueryResultArticle("now", fmt.Sprintf(commentsEn["InlineNow"], "Lisbon"), "now_report"),
+					bot.NewInlineQueryResultArticle("24h", fmt.Sprintf(commentsEn["InlineHours"], "Lisbon"), "hours_report"),
+					bot.NewInlineQueryResultArticle("5d", fmt.Sprintf(commentsEn["InlineDays"], "Lisbon"), "days_report"),
+				)).Return(nil)
+			},
+			upd:     newUpdate(" Lisbon "),
+			wantErr: false,
+		},
+		{
+			name: "2. Failed forecast is left out",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(lisbon, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Now()).Return(wr, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Hours(24)).Return(nil, someErr)
+				fc.EXPECT().GetForecast(gomock.Any(), loc, types.Days(5)).Return(nil, someErr)
+				f.EXPECT().FormatNow(gomock.Any(), wr).Return("now_report")
+				bc.EXPECT().AnswerInlineQuery(newAnswer(
+					bot.NewInlineQueryResultArticle("now", fmt.Sprintf(commentsEn["InlineNow"], "Lisbon"), "now_report"),
+				)).Return(nil)
+			},
+			upd:     newUpdate("Lisbon"),
+			wantErr: false,
+		},
+		{
+			name: "3. No cards for an unknown city",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisb").Return(&bot.Location{}, errors.Wrap(sql.ErrNoRows, "cannot get coordinates"))
+				bc.EXPECT().AnswerInlineQuery(newAnswer()).Return(nil)
+			},
+			upd:     newUpdate("Lisb"),
+			wantErr: false,
+		},
+		{
+			name: "4. No cards for an empty query",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo) {
+				bc.EXPECT().AnswerInlineQuery(newAnswer()).Return(nil)
+			},
+			upd:     newUpdate(""),
+			wantErr: false,
+		},
+		{
+			name: "5. Error on getting coordinates",
+			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, f *mock.MockReportFormatter, lr *mock.MockLocationRepo) {
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Lisbon").Return(&bot.Location{}, someErr)
+			},
+			upd:     newUpdate("Lisbon"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bc := mock.NewMockBotClient(ctrl)
+			fc := mock.NewMockForecastClient(ctrl)
+			f := mock.NewMockReportFormatter(ctrl)
+			lr := mock.NewMockLocationRepo(ctrl)
+
+			tt.prepare(bc, fc, f, lr)
+
+			s := NewMessageService(bc, fc, f, mock.NewMockBotUIRepo(ctrl), lr, mock.NewMockUserLocationRepo(ctrl), mock.NewMockUserDataRepo(ctrl))
+			if err := s.HandleInlineQuery(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleInlineQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
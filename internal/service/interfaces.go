@@ -8,23 +8,43 @@ import (
 
 type BotClient interface {
 	Send(msg bot.MessageConfig) (bot.Message, error)
+	Edit(msg bot.EditMessageTextConfig) (bot.Message, error)
+	AnswerCallback(cb bot.CallbackConfig) error
+	AnswerInlineQuery(answer bot.InlineConfig) error
+	GetChatMember(member bot.ChatConfigWithUser) (bot.ChatMember, error)
 	ListenForWebhook(webhook string) bot.UpdatesChannel
 }
 
+type CommandSetter interface {
+	SetMyCommands(ctx context.Context, commands []types.BotCommand, scope types.BotCommandScope, languageCode string) error
+}
+
 type ForecastClient interface {
-	GetForecast(ctx context.Context, loc *types.UserCoordinates, period string) (*types.FullWeatherReport, error)
+	GetForecast(ctx context.Context, loc *types.UserCoordinates, period types.Period) (*types.FullWeatherReport, error)
+}
+
+// Grammar reads a place and a time out of a query typed in words in its language.
+type Grammar interface {
+	Language() string
+	Parse(text string) (*types.NaturalQuery, bool)
 }
 
 type UserDataRepo interface {
 	AddUserIfNotExists(ctx context.Context, user *bot.User) error
+	ForgetUser(ctx context.Context, userID int) (*types.ForgottenUser, error)
 }
 
 type BotUIRepo interface {
-	GetMainMenuKeyboard() bot.ReplyKeyboardMarkup
+	GetMainMenuKeyboard(favorites ...string) bot.ReplyKeyboardMarkup
+	GetHistoryKeyboard(locations ...string) bot.ReplyKeyboardMarkup
 	GetBackToMainMenuKeyboard() bot.ReplyKeyboardMarkup
+	GetForgetKeyboard() bot.ReplyKeyboardMarkup
 	GetDaysOrHoursKeyboard() bot.ReplyKeyboardMarkup
 	GetDaysKeyboard() bot.ReplyKeyboardMarkup
 	GetHoursKeyboard() bot.ReplyKeyboardMarkup
+	GetDaysOrHoursInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup
+	GetDaysInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup
+	GetHoursInlineKeyboard(loc *types.UserCoordinates) bot.InlineKeyboardMarkup
 }
 
 type LocationRepo interface {
@@ -35,10 +55,46 @@ type UserLocationRepo interface {
 	GetUserRecentLocation(ctx context.Context, userID int) (*types.UserCoordinates, error)
 	SaveUserLocationName(ctx context.Context, userID int, locationName string) error
 	AddUserLocationByCoordinates(ctx context.Context, userID int, loc *bot.Location) error
+	GetUserLocationHistory(ctx context.Context, userID int, limit int) ([]types.RecentLocation, error)
+	GetUserLocationByName(ctx context.Context, userID int, locationName string) (*types.RecentLocation, error)
+}
+
+type FavoriteRepo interface {
+	ListFavorites(ctx context.Context, userID int) ([]types.Favorite, error)
+	GetFavorite(ctx context.Context, userID int, name string) (*types.Favorite, error)
+	SaveFavorite(ctx context.Context, userID int, name string, loc *types.UserCoordinates) error
+	RenameFavorite(ctx context.Context, userID int, oldName, newName string) (bool, error)
+	DeleteFavorite(ctx context.Context, userID int, name string) (bool, error)
+}
+
+type ConversationRepo interface {
+	GetConversation(ctx context.Context, chatID int64) (*types.Conversation, error)
+	SaveConversation(ctx context.Context, chatID int64, conv *types.Conversation) error
+}
+
+type GroupSettingsRepo interface {
+	GetGroupSettings(ctx context.Context, chatID int64) (*types.GroupSettings, error)
+	SaveGroupLocation(ctx context.Context, chatID int64, locationName string, loc *types.UserCoordinates, userID int) error
+	SaveGroupLanguage(ctx context.Context, chatID int64, language string, userID int) error
+}
+
+type UpdateOffsetRepo interface {
+	GetUpdateOffset(ctx context.Context, botID int) (int, error)
+	SaveUpdateOffset(ctx context.Context, botID int, offset int) error
+}
+
+// UpdateStore keeps polled updates durably, so that they can be confirmed to Telegram before they are handled.
+type UpdateStore interface {
+	Save(ctx context.Context, upd bot.Update) error
+}
+
+type InboxRepo interface {
+	ReplayDead(ctx context.Context) (int64, error)
 }
 
 type ReportFormatter interface {
 	FormatNow(ctx context.Context, report *types.FullWeatherReport) string
 	FormatHours(ctx context.Context, report *types.FullWeatherReport, hours int) string
 	FormatDays(ctx context.Context, report *types.FullWeatherReport, days int) string
+	FormatComparison(ctx context.Context, reports []*types.FullWeatherReport, days int) string
 }
@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"strconv"
-	"strings"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+	"weather-or-not-bot/internal/tracing"
 	"weather-or-not-bot/internal/types"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	bot "gopkg.in/telegram-bot-api.v4"
 )
 
@@ -22,10 +24,20 @@ type MessageService struct {
 	locRepo    LocationRepo
 	usrLocRepo UserLocationRepo
 	usrRepo    UserDataRepo
+	inbox      InboxRepo
+	admins     map[int]bool
+	commands   *CommandRegistry
+	inline     bool
+	self       bot.User
+	groups     GroupSettingsRepo
+	favorites  FavoriteRepo
+	grammars   []Grammar
+
+	conversations ConversationRepo
 }
 
 func NewMessageService(botCmd BotClient, forecast ForecastClient, format ReportFormatter, botRepo BotUIRepo, locRepo LocationRepo, usrLocRepo UserLocationRepo, usrRepo UserDataRepo) *MessageService {
-	return &MessageService{botCmd: botCmd, forecast: forecast, format: format, botRepo: botRepo, locRepo: locRepo, usrLocRepo: usrLocRepo, usrRepo: usrRepo}
+	return &MessageService{botCmd: botCmd, forecast: forecast, format: format, botRepo: botRepo, locRepo: locRepo, usrLocRepo: usrLocRepo, usrRepo: usrRepo, commands: NewCommandRegistry()}
 }
 
 const (
@@ -39,23 +51,13 @@ const (
 	WeatherHere      = "Weather at my location"
 	WeatherElsewhere = "Weather elsewhere"
 	Stop             = "/stop"
-
-	ThreeDays          = "3 days"
-	FiveDays           = "5 days"
-	SevenDays          = "7 days"
-	TenDays            = "10 days"
-	SixteenDays        = "16 days"
-	TwentyFourHours    = "24 hours"
-	FortyEightHours    = "48 hours"
-	SeventyTwoHours    = "72 hours"
-	NinetySixHours     = "96 hours"
-	HundredTwentyHours = "120 hours"
-
-	HOURLY = true
-	DAILY  = false
 )
 
 func (s *MessageService) HandleNewMessage(ctx context.Context, upd *bot.Update) error {
+	if upd.Message == nil || upd.Message.From == nil || upd.Message.Chat == nil {
+		return errors.Errorf("update %d carries no message to handle", upd.UpdateID)
+	}
+
 	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
 		"username":     upd.Message.From.UserName,
 		"user_id":      upd.Message.From.ID,
@@ -63,40 +65,27 @@ func (s *MessageService) HandleNewMessage(ctx context.Context, upd *bot.Update)
 	})
 	log.Info("Handling a new message")
 
-	var err error
-	switch upd.Message.Text {
-	case Start:
-		err = s.handleStart(ctx, upd.Message)
-	case BackToMainMenu:
-		err = s.handleBackToMainMenu(ctx, upd.Message)
-	case Back:
-		err = s.handleBack(ctx, upd.Message)
-	case ByHours:
-		err = s.handleByHours(ctx, upd.Message)
-	case ByDays:
-		err = s.handleByDays(ctx, upd.Message)
-	case CurrentWeather:
-		err = s.handleNow(ctx, upd.Message)
-	case ThreeDays, FiveDays, SevenDays, TenDays, SixteenDays:
-		err = s.handlePeriod(ctx, upd.Message, DAILY)
-	case TwentyFourHours, FortyEightHours, SeventyTwoHours, NinetySixHours, HundredTwentyHours:
-		err = s.handlePeriod(ctx, upd.Message, HOURLY)
-	case WeatherHere:
-		err = s.handleLocationByCoordinates(ctx, upd.Message)
-	case WeatherElsewhere:
-		err = s.handleWeatherElsewhere(ctx, upd.Message)
-	case EmptyMessage:
-		err = s.handleEmptyMessage(ctx, upd.Message)
-	case Stop:
-		err = s.handleStop(ctx, upd.Message)
-	default:
-		err = s.handleUnknown(ctx, upd.Message)
-	}
+	ctx, span := tracing.Start(ctx, "MessageService.HandleNewMessage", attribute.Int("user_id", upd.Message.From.ID))
+	defer span.End()
+
+	conv, kept := s.loadConversation(ctx, upd.Message.Chat)
+	state, depth := conv.State, len(conv.History)
+
+	handler, handle := s.route(conv, upd.Message)
+	start := time.Now()
+	err := handle(ctx, upd.Message)
+	metrics.ObserveHandler(handler, start, err)
+	span.SetName("MessageService." + handler)
+	tracing.RecordError(ctx, err)
 
 	if err != nil {
 		return errors.Wrap(err, "cannot handle a new message")
 	}
 
+	if kept && (conv.State != state || len(conv.History) != depth) {
+		s.saveConversation(ctx, upd.Message.Chat.ID, conv)
+	}
+
 	return nil
 }
 
@@ -106,7 +95,7 @@ func (s *MessageService) handleStop(ctx context.Context, req *bot.Message) error
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["End"])
 	resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -123,9 +112,9 @@ func (s *MessageService) handleStart(ctx context.Context, req *bot.Message) erro
 	}
 
 	resp := bot.NewMessage(req.Chat.ID, fmt.Sprintf("%s\n%s", commentsEn["DefaultMessage"], pickASaying(req.MessageID, sayingsEn)))
-	resp.ReplyMarkup = s.botRepo.GetMainMenuKeyboard()
+	resp.ReplyMarkup = s.mainMenu(ctx, req.From.ID)
 
-	_, err = s.botCmd.Send(resp)
+	_, err = s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -137,9 +126,9 @@ func (s *MessageService) handleBackToMainMenu(ctx context.Context, req *bot.Mess
 	ctxlogrus.Extract(ctx).Debugf("Handling '%s'", req.Text)
 
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["ChooseLocation"])
-	resp.ReplyMarkup = s.botRepo.GetMainMenuKeyboard()
+	resp.ReplyMarkup = s.mainMenu(ctx, req.From.ID)
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -153,7 +142,7 @@ func (s *MessageService) handleBack(ctx context.Context, req *bot.Message) error
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["ChoosePeriodType"])
 	resp.ReplyMarkup = s.botRepo.GetDaysOrHoursKeyboard()
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -167,7 +156,7 @@ func (s *MessageService) handleByHours(ctx context.Context, req *bot.Message) er
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["ChoosePeriod"])
 	resp.ReplyMarkup = s.botRepo.GetHoursKeyboard()
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -181,7 +170,7 @@ func (s *MessageService) handleByDays(ctx context.Context, req *bot.Message) err
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["ChoosePeriod"])
 	resp.ReplyMarkup = s.botRepo.GetDaysKeyboard()
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -198,7 +187,7 @@ func (s *MessageService) handleNow(ctx context.Context, req *bot.Message) error
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
 
-	wr, err := s.forecast.GetForecast(ctx, loc, req.Text)
+	wr, err := s.forecast.GetForecast(ctx, loc, types.Now())
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -206,7 +195,7 @@ func (s *MessageService) handleNow(ctx context.Context, req *bot.Message) error
 	resp := bot.NewMessage(req.Chat.ID, s.format.FormatNow(ctx, wr))
 	resp.ReplyMarkup = s.botRepo.GetDaysOrHoursKeyboard()
 
-	_, err = s.botCmd.Send(resp)
+	_, err = s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -219,30 +208,42 @@ func (s *MessageService) handleNow(ctx context.Context, req *bot.Message) error
 	return nil
 }
 
-func (s *MessageService) handlePeriod(ctx context.Context, req *bot.Message, byHours bool) error {
+// handlePeriod answers a period tapped or typed, e.g. '48 hours', '9 days' or '12h'.
+func (s *MessageService) handlePeriod(ctx context.Context, req *bot.Message) error {
 	log := ctxlogrus.Extract(ctx)
 	log.Debugf("Handling '%s'", req.Text)
 
-	loc, err := s.usrLocRepo.GetUserRecentLocation(ctx, req.From.ID)
+	period, err := types.ParsePeriod(req.Text)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
 
-	wr, err := s.forecast.GetForecast(ctx, loc, req.Text)
+	if err := period.Validate(); err != nil {
+		resp := bot.NewMessage(req.Chat.ID, periodUsage(period))
+		resp.ReplyMarkup = s.periodKeyboard(period)
+
+		_, err = s.send(ctx, resp)
+		if err != nil {
+			return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+		}
+
+		return nil
+	}
+
+	loc, err := s.usrLocRepo.GetUserRecentLocation(ctx, req.From.ID)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
 
-	var resp bot.MessageConfig
-	if byHours {
-		resp = bot.NewMessage(req.Chat.ID, s.format.FormatHours(ctx, wr, extractNumerals(req.Text)))
-		resp.ReplyMarkup = s.botRepo.GetHoursKeyboard()
-	} else {
-		resp = bot.NewMessage(req.Chat.ID, s.format.FormatDays(ctx, wr, extractNumerals(req.Text)))
-		resp.ReplyMarkup = s.botRepo.GetDaysKeyboard()
+	wr, err := s.forecast.GetForecast(ctx, loc, period)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
 
-	_, err = s.botCmd.Send(resp)
+	resp := bot.NewMessage(req.Chat.ID, s.formatPeriod(ctx, wr, period))
+	resp.ReplyMarkup = s.periodKeyboard(period)
+
+	_, err = s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -264,9 +265,9 @@ func (s *MessageService) handleLocationByCoordinates(ctx context.Context, req *b
 	}
 
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["CoordsAccepted"])
-	resp.ReplyMarkup = s.botRepo.GetDaysOrHoursKeyboard()
+	resp.ReplyMarkup = s.periodTypeKeyboard(req.Location)
 
-	_, err = s.botCmd.Send(resp)
+	_, err = s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrap(err, types.ErrHandlingLocByCoords)
 	}
@@ -280,7 +281,7 @@ func (s *MessageService) handleWeatherElsewhere(ctx context.Context, req *bot.Me
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["DiffPlaceAccepted"])
 	resp.ReplyMarkup = bot.ReplyKeyboardHide{HideKeyboard: true}
 
-	_, err := s.botCmd.Send(resp)
+	_, err := s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -288,50 +289,54 @@ func (s *MessageService) handleWeatherElsewhere(ctx context.Context, req *bot.Me
 	return nil
 }
 
-func (s *MessageService) handleLocationByText(ctx context.Context, req *bot.Message) error {
+// handleLocationByText takes the text for a city name and tells whether such a city is found.
+func (s *MessageService) handleLocationByText(ctx context.Context, req *bot.Message) (bool, error) {
 	ctxlogrus.Extract(ctx).Debugf("Handling location '%s' by text ", req.Text)
 
 	loc, err := s.locRepo.GetCoordinatesByCityName(ctx, req.Text)
 	if err != nil {
-		return errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
+		return false, errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
 	}
 
+	found := loc.Latitude != 0 || loc.Longitude != 0
+
 	var resp bot.MessageConfig
-	if loc.Latitude == 0 && loc.Longitude == 0 {
+	if !found {
+		// Keeping the last location the user had, forecasts for 0,0 are of no use.
 		resp = bot.NewMessage(req.Chat.ID, commentsEn["TryAgain"])
 		resp.ReplyMarkup = s.botRepo.GetBackToMainMenuKeyboard()
 	} else {
-		resp = bot.NewMessage(req.Chat.ID, commentsEn["CoordsAccepted"])
-		resp.ReplyMarkup = s.botRepo.GetDaysOrHoursKeyboard()
-	}
+		err = s.usrLocRepo.AddUserLocationByCoordinates(ctx, req.From.ID, loc)
+		if err != nil {
+			return false, errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
+		}
 
-	err = s.usrLocRepo.AddUserLocationByCoordinates(ctx, req.From.ID, loc)
-	if err != nil {
-		return errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
+		resp = bot.NewMessage(req.Chat.ID, commentsEn["CoordsAccepted"])
+		resp.ReplyMarkup = s.periodTypeKeyboard(loc)
 	}
 
-	_, err = s.botCmd.Send(resp)
+	_, err = s.send(ctx, resp)
 	if err != nil {
-		return errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
+		return false, errors.Wrapf(err, types.ErrHandlingLocByText, req.Text)
 	}
 
-	return nil
+	return found, nil
 }
 
 func (s *MessageService) handleUnknown(ctx context.Context, req *bot.Message) error {
 	log := ctxlogrus.Extract(ctx)
 	log.Debugf("Handling '%s'", req.Text)
 
-	err := s.handleLocationByText(ctx, req)
+	_, err := s.handleLocationByText(ctx, req)
 	if err == nil {
 		return nil
 	}
 
 	log.Debug("Location unknown, handling like any unknown message")
 	resp := bot.NewMessage(req.Chat.ID, commentsEn["Unknown"])
-	resp.ReplyMarkup = s.botRepo.GetMainMenuKeyboard()
+	resp.ReplyMarkup = s.mainMenu(ctx, req.From.ID)
 
-	_, err = s.botCmd.Send(resp)
+	_, err = s.send(ctx, resp)
 	if err != nil {
 		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
 	}
@@ -355,8 +360,41 @@ func pickASaying(seed int, sayings []string) string {
 	return sayings[rand.Intn(len(sayings))]
 }
 
-// Extract numeric part of the request. No error handling since string s is deterministic.
-func extractNumerals(s string) int {
-	num, _ := strconv.Atoi(strings.Split(s, " ")[0])
-	return num
+// formatPeriod formats the report the way the period is shown: by hours, by days or as it is now.
+func (s *MessageService) formatPeriod(ctx context.Context, wr *types.FullWeatherReport, period types.Period) string {
+	switch period.Unit {
+	case types.PeriodHours:
+		return s.format.FormatHours(ctx, wr, period.Length)
+	case types.PeriodDays:
+		return s.format.FormatDays(ctx, wr, period.Length)
+	default:
+		return s.format.FormatNow(ctx, wr)
+	}
+}
+
+// periodKeyboard returns the keyboard to choose another period of the same unit with.
+func (s *MessageService) periodKeyboard(period types.Period) bot.ReplyKeyboardMarkup {
+	if period.Unit == types.PeriodHours {
+		return s.botRepo.GetHoursKeyboard()
+	}
+	return s.botRepo.GetDaysKeyboard()
+}
+
+// periodUsage tells the user how far ahead forecasts go for the unit of the period.
+func periodUsage(period types.Period) string {
+	if period.Unit == types.PeriodHours {
+		return fmt.Sprintf(commentsEn["HoursOutOfRange"], period.Max())
+	}
+	return fmt.Sprintf(commentsEn["DaysOutOfRange"], period.Max())
+}
+
+// send sends the message within its own span, which covers throttling and retries.
+func (s *MessageService) send(ctx context.Context, msg bot.MessageConfig) (bot.Message, error) {
+	ctx, span := tracing.Start(ctx, "BotClient.Send", attribute.Int64("chat_id", msg.ChatID))
+	defer span.End()
+
+	resp, err := s.botCmd.Send(msg)
+	tracing.RecordError(ctx, err)
+
+	return resp, err
 }
@@ -84,8 +84,8 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		byHours = &bot.Update{UpdateID: 53, Message: &bot.Message{MessageID: 115, Text: ByHours, From: user, Chat: &bot.Chat{ID: chatID}}}
 		byDays  = &bot.Update{UpdateID: 69, Message: &bot.Message{MessageID: 116, Text: ByDays, From: user, Chat: &bot.Chat{ID: chatID}}}
 		current = &bot.Update{UpdateID: 72, Message: &bot.Message{MessageID: 117, Text: CurrentWeather, From: user, Chat: &bot.Chat{ID: chatID}}}
-		days5   = &bot.Update{UpdateID: 88, Message: &bot.Message{MessageID: 118, Text: FiveDays, From: user, Chat: &bot.Chat{ID: chatID}}}
-		hours96 = &bot.Update{UpdateID: 90, Message: &bot.Message{MessageID: 119, Text: NinetySixHours, From: user, Chat: &bot.Chat{ID: chatID}}}
+		days5   = &bot.Update{UpdateID: 88, Message: &bot.Message{MessageID: 118, Text: "5 days", From: user, Chat: &bot.Chat{ID: chatID}}}
+		hours96 = &bot.Update{UpdateID: 90, Message: &bot.Message{MessageID: 119, Text: "96 hours", From: user, Chat: &bot.Chat{ID: chatID}}}
 		here    = &bot.Update{UpdateID: 14, Message: &bot.Message{MessageID: 120, Text: WeatherHere, From: user, Chat: &bot.Chat{ID: chatID}, Location: botLoc}}
 		there   = &bot.Update{UpdateID: 21, Message: &bot.Message{MessageID: 120, Text: WeatherElsewhere, From: user, Chat: &bot.Chat{ID: chatID}}}
 		tallinn = &bot.Update{UpdateID: 33, Message: &bot.Message{MessageID: 121, Text: "Tallinn", From: user, Chat: &bot.Chat{ID: chatID}}}
@@ -118,7 +118,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "2. Error on adding user to db on Start",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ur.EXPECT().AddUserIfNotExists(ctx, user).Return(someErr)
+				ur.EXPECT().AddUserIfNotExists(gomock.Any(), user).Return(someErr)
 			},
 			upd:     start,
 			wantErr: true,
@@ -126,7 +126,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "3. Error on getting user's recent location from db on Now",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(nil, someErr)
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(nil, someErr)
 			},
 			upd:     current,
 			wantErr: true,
@@ -134,8 +134,8 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "4. Error on getting a forecast on Now",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(uLoc, nil)
-				fc.EXPECT().GetForecast(ctx, uLoc, current.Message.Text).Return(nil, someErr)
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Now()).Return(nil, someErr)
 			},
 			upd:     current,
 			wantErr: true,
@@ -143,14 +143,14 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "5. No error, but failed saving location name on Now",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(uLoc, nil)
-				fc.EXPECT().GetForecast(ctx, uLoc, current.Message.Text).Return(wr, nil)
-				rf.EXPECT().FormatNow(ctx, wr).Return("formatted_report")
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Now()).Return(wr, nil)
+				rf.EXPECT().FormatNow(gomock.Any(), wr).Return("formatted_report")
 				resp := bot.NewMessage(chatID, "formatted_report")
 				resp.ReplyMarkup = chPeriod
 				br.EXPECT().GetDaysOrHoursKeyboard().Return(chPeriod)
 				bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
-				ulr.EXPECT().SaveUserLocationName(ctx, user.ID, wr.CityName).Return(someErr)
+				ulr.EXPECT().SaveUserLocationName(gomock.Any(), user.ID, wr.CityName).Return(someErr)
 			},
 			upd:     current,
 			wantErr: false,
@@ -158,7 +158,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "6. Error on adding user's location from db on WeatherHere",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().AddUserLocationByCoordinates(ctx, user.ID, here.Message.Location).Return(someErr)
+				ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, here.Message.Location).Return(someErr)
 			},
 			upd:     here,
 			wantErr: true,
@@ -166,7 +166,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "7. No error, but failed to get coordinates from db when handling location by text",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				lr.EXPECT().GetCoordinatesByCityName(ctx, tallinn.Message.Text).Return(&bot.Location{}, someErr)
+				lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), tallinn.Message.Text).Return(&bot.Location{}, someErr)
 				resp := bot.NewMessage(chatID, commentsEn["Unknown"])
 				resp.ReplyMarkup = mainMenu
 				br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
@@ -188,7 +188,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "9. Success on handling Start",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ur.EXPECT().AddUserIfNotExists(ctx, user).Return(nil)
+				ur.EXPECT().AddUserIfNotExists(gomock.Any(), user).Return(nil)
 				resp := bot.NewMessage(chatID, fmt.Sprintf("%s\n%s", commentsEn["DefaultMessage"], pickASaying(start.Message.MessageID, sayingsEn)))
 				resp.ReplyMarkup = mainMenu
 				br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
@@ -244,14 +244,14 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "14. Success on handling Now",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(uLoc, nil)
-				fc.EXPECT().GetForecast(ctx, uLoc, current.Message.Text).Return(wr, nil)
-				rf.EXPECT().FormatNow(ctx, wr).Return("formatted_report")
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Now()).Return(wr, nil)
+				rf.EXPECT().FormatNow(gomock.Any(), wr).Return("formatted_report")
 				resp := bot.NewMessage(chatID, "formatted_report")
 				resp.ReplyMarkup = chPeriod
 				br.EXPECT().GetDaysOrHoursKeyboard().Return(chPeriod)
 				bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
-				ulr.EXPECT().SaveUserLocationName(ctx, user.ID, wr.CityName).Return(nil)
+				ulr.EXPECT().SaveUserLocationName(gomock.Any(), user.ID, wr.CityName).Return(nil)
 			},
 			upd:     current,
 			wantErr: false,
@@ -259,14 +259,14 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "15. Success on handling FiveDays",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(uLoc, nil)
-				fc.EXPECT().GetForecast(ctx, uLoc, days5.Message.Text).Return(wr, nil)
-				rf.EXPECT().FormatDays(ctx, wr, extractNumerals(days5.Message.Text)).Return("formatted_report")
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Days(5)).Return(wr, nil)
+				rf.EXPECT().FormatDays(gomock.Any(), wr, 5).Return("formatted_report")
 				resp := bot.NewMessage(chatID, "formatted_report")
 				resp.ReplyMarkup = chDays
 				br.EXPECT().GetDaysKeyboard().Return(chDays)
 				bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
-				ulr.EXPECT().SaveUserLocationName(ctx, user.ID, wr.CityName).Return(nil)
+				ulr.EXPECT().SaveUserLocationName(gomock.Any(), user.ID, wr.CityName).Return(nil)
 			},
 			upd:     days5,
 			wantErr: false,
@@ -274,14 +274,14 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "16. Success on handling NinetySixHours",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().GetUserRecentLocation(ctx, user.ID).Return(uLoc, nil)
-				fc.EXPECT().GetForecast(ctx, uLoc, hours96.Message.Text).Return(wr, nil)
-				rf.EXPECT().FormatHours(ctx, wr, extractNumerals(hours96.Message.Text)).Return("formatted_report")
+				ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Hours(96)).Return(wr, nil)
+				rf.EXPECT().FormatHours(gomock.Any(), wr, 96).Return("formatted_report")
 				resp := bot.NewMessage(chatID, "formatted_report")
 				resp.ReplyMarkup = chHours
 				br.EXPECT().GetHoursKeyboard().Return(chHours)
 				bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
-				ulr.EXPECT().SaveUserLocationName(ctx, user.ID, wr.CityName).Return(nil)
+				ulr.EXPECT().SaveUserLocationName(gomock.Any(), user.ID, wr.CityName).Return(nil)
 			},
 			upd:     hours96,
 			wantErr: false,
@@ -289,7 +289,7 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		{
 			name: "17. Success on handling WeatherHere",
 			prepare: func(bc *mock.MockBotClient, fc *mock.MockForecastClient, rf *mock.MockReportFormatter, br *mock.MockBotUIRepo, lr *mock.MockLocationRepo, ulr *mock.MockUserLocationRepo, ur *mock.MockUserDataRepo) {
-				ulr.EXPECT().AddUserLocationByCoordinates(ctx, user.ID, here.Message.Location).Return(nil)
+				ulr.EXPECT().AddUserLocationByCoordinates(gomock.Any(), user.ID, here.Message.Location).Return(nil)
 				resp := bot.NewMessage(chatID, commentsEn["CoordsAccepted"])
 				resp.ReplyMarkup = chPeriod
 				br.EXPECT().GetDaysOrHoursKeyboard().Return(chPeriod)
@@ -331,3 +331,90 @@ func TestMessageService_HandleNewMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageService_HandleReplay(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	admin := &bot.User{ID: 1, UserName: "the_admin"}
+	user := &bot.User{ID: 2, UserName: "the_john"}
+	mainMenu := bot.NewReplyKeyboard(bot.NewKeyboardButtonRow(bot.NewKeyboardButton("some_text")))
+
+	var (
+		byAdmin = &bot.Update{UpdateID: 11, Message: &bot.Message{MessageID: 111, Text: Replay, From: admin, Chat: &bot.Chat{ID: chatID}}}
+		byUser  = &bot.Update{UpdateID: 12, Message: &bot.Message{MessageID: 112, Text: Replay, From: user, Chat: &bot.Chat{ID: chatID}}}
+	)
+
+	tests := []struct {
+		name      string
+		withInbox bool
+		prepare   func(bc *mock.MockBotClient, lr *mock.MockLocationRepo, br *mock.MockBotUIRepo, ir *mock.MockInboxRepo)
+		upd       *bot.Update
+		wantErr   bool
+	}{
+		{
+			name:      "1. Not an admin, answered like an unknown command",
+			withInbox: true,
+			prepare: func(bc *mock.MockBotClient, lr *mock.MockLocationRepo, br *mock.MockBotUIRepo, ir *mock.MockInboxRepo) {
+				resp := bot.NewMessage(chatID, commentsEn["Unknown"])
+				resp.ReplyMarkup = mainMenu
+				br.EXPECT().GetMainMenuKeyboard().Return(mainMenu)
+				bc.EXPECT().Send(resp).Return(bot.Message{}, nil)
+			},
+			upd:     byUser,
+			wantErr: false,
+		},
+		{
+			name:      "2. Inbox is off",
+			withInbox: false,
+			prepare: func(bc *mock.MockBotClient, lr *mock.MockLocationRepo, br *mock.MockBotUIRepo, ir *mock.MockInboxRepo) {
+				bc.EXPECT().Send(bot.NewMessage(chatID, commentsEn["InboxOff"])).Return(bot.Message{}, nil)
+			},
+			upd:     byAdmin,
+			wantErr: false,
+		},
+		{
+			name:      "3. Error on replaying dead updates",
+			withInbox: true,
+			prepare: func(bc *mock.MockBotClient, lr *mock.MockLocationRepo, br *mock.MockBotUIRepo, ir *mock.MockInboxRepo) {
+				ir.EXPECT().ReplayDead(gomock.Any()).Return(int64(0), someErr)
+			},
+			upd:     byAdmin,
+			wantErr: true,
+		},
+		{
+			name:      "4. Success on replaying dead updates",
+			withInbox: true,
+			prepare: func(bc *mock.MockBotClient, lr *mock.MockLocationRepo, br *mock.MockBotUIRepo, ir *mock.MockInboxRepo) {
+				ir.EXPECT().ReplayDead(gomock.Any()).Return(int64(3), nil)
+				bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["Replayed"], 3))).Return(bot.Message{}, nil)
+			},
+			upd:     byAdmin,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bc := mock.NewMockBotClient(ctrl)
+			lr := mock.NewMockLocationRepo(ctrl)
+			br := mock.NewMockBotUIRepo(ctrl)
+			ir := mock.NewMockInboxRepo(ctrl)
+
+			tt.prepare(bc, lr, br, ir)
+
+			s := NewMessageService(bc, mock.NewMockForecastClient(ctrl), mock.NewMockReportFormatter(ctrl), br, lr,
+				mock.NewMockUserLocationRepo(ctrl), mock.NewMockUserDataRepo(ctrl)).WithAdmins([]int{admin.ID})
+			if tt.withInbox {
+				s.WithInbox(ir)
+			}
+
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -36,6 +36,64 @@ func (m *MockBotClient) EXPECT() *MockBotClientMockRecorder {
 	return m.recorder
 }
 
+// AnswerCallback mocks base method.
+func (m *MockBotClient) AnswerCallback(cb tgbotapi.CallbackConfig) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnswerCallback", cb)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnswerCallback indicates an expected call of AnswerCallback.
+func (mr *MockBotClientMockRecorder) AnswerCallback(cb interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnswerCallback", reflect.TypeOf((*MockBotClient)(nil).AnswerCallback), cb)
+}
+
+// AnswerInlineQuery mocks base method.
+func (m *MockBotClient) AnswerInlineQuery(answer tgbotapi.InlineConfig) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnswerInlineQuery", answer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnswerInlineQuery indicates an expected call of AnswerInlineQuery.
+func (mr *MockBotClientMockRecorder) AnswerInlineQuery(answer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnswerInlineQuery", reflect.TypeOf((*MockBotClient)(nil).AnswerInlineQuery), answer)
+}
+
+// Edit mocks base method.
+func (m *MockBotClient) Edit(msg tgbotapi.EditMessageTextConfig) (tgbotapi.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Edit", msg)
+	ret0, _ := ret[0].(tgbotapi.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Edit indicates an expected call of Edit.
+func (mr *MockBotClientMockRecorder) Edit(msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Edit", reflect.TypeOf((*MockBotClient)(nil).Edit), msg)
+}
+
+// GetChatMember mocks base method.
+func (m *MockBotClient) GetChatMember(member tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetChatMember", member)
+	ret0, _ := ret[0].(tgbotapi.ChatMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetChatMember indicates an expected call of GetChatMember.
+func (mr *MockBotClientMockRecorder) GetChatMember(member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChatMember", reflect.TypeOf((*MockBotClient)(nil).GetChatMember), member)
+}
+
 // ListenForWebhook mocks base method.
 func (m *MockBotClient) ListenForWebhook(webhook string) tgbotapi.UpdatesChannel {
 	m.ctrl.T.Helper()
@@ -65,6 +123,43 @@ func (mr *MockBotClientMockRecorder) Send(msg interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockBotClient)(nil).Send), msg)
 }
 
+// MockCommandSetter is a mock of CommandSetter interface.
+type MockCommandSetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommandSetterMockRecorder
+}
+
+// MockCommandSetterMockRecorder is the mock recorder for MockCommandSetter.
+type MockCommandSetterMockRecorder struct {
+	mock *MockCommandSetter
+}
+
+// NewMockCommandSetter creates a new mock instance.
+func NewMockCommandSetter(ctrl *gomock.Controller) *MockCommandSetter {
+	mock := &MockCommandSetter{ctrl: ctrl}
+	mock.recorder = &MockCommandSetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCommandSetter) EXPECT() *MockCommandSetterMockRecorder {
+	return m.recorder
+}
+
+// SetMyCommands mocks base method.
+func (m *MockCommandSetter) SetMyCommands(ctx context.Context, commands []types.BotCommand, scope types.BotCommandScope, languageCode string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMyCommands", ctx, commands, scope, languageCode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMyCommands indicates an expected call of SetMyCommands.
+func (mr *MockCommandSetterMockRecorder) SetMyCommands(ctx, commands, scope, languageCode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMyCommands", reflect.TypeOf((*MockCommandSetter)(nil).SetMyCommands), ctx, commands, scope, languageCode)
+}
+
 // MockForecastClient is a mock of ForecastClient interface.
 type MockForecastClient struct {
 	ctrl     *gomock.Controller
@@ -89,7 +184,7 @@ func (m *MockForecastClient) EXPECT() *MockForecastClientMockRecorder {
 }
 
 // GetForecast mocks base method.
-func (m *MockForecastClient) GetForecast(ctx context.Context, loc *types.UserCoordinates, period string) (*types.FullWeatherReport, error) {
+func (m *MockForecastClient) GetForecast(ctx context.Context, loc *types.UserCoordinates, period types.Period) (*types.FullWeatherReport, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetForecast", ctx, loc, period)
 	ret0, _ := ret[0].(*types.FullWeatherReport)
@@ -103,6 +198,58 @@ func (mr *MockForecastClientMockRecorder) GetForecast(ctx, loc, period interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForecast", reflect.TypeOf((*MockForecastClient)(nil).GetForecast), ctx, loc, period)
 }
 
+// MockGrammar is a mock of Grammar interface.
+type MockGrammar struct {
+	ctrl     *gomock.Controller
+	recorder *MockGrammarMockRecorder
+}
+
+// MockGrammarMockRecorder is the mock recorder for MockGrammar.
+type MockGrammarMockRecorder struct {
+	mock *MockGrammar
+}
+
+// NewMockGrammar creates a new mock instance.
+func NewMockGrammar(ctrl *gomock.Controller) *MockGrammar {
+	mock := &MockGrammar{ctrl: ctrl}
+	mock.recorder = &MockGrammarMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGrammar) EXPECT() *MockGrammarMockRecorder {
+	return m.recorder
+}
+
+// Language mocks base method.
+func (m *MockGrammar) Language() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Language")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Language indicates an expected call of Language.
+func (mr *MockGrammarMockRecorder) Language() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Language", reflect.TypeOf((*MockGrammar)(nil).Language))
+}
+
+// Parse mocks base method.
+func (m *MockGrammar) Parse(text string) (*types.NaturalQuery, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Parse", text)
+	ret0, _ := ret[0].(*types.NaturalQuery)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Parse indicates an expected call of Parse.
+func (mr *MockGrammarMockRecorder) Parse(text interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Parse", reflect.TypeOf((*MockGrammar)(nil).Parse), text)
+}
+
 // MockUserDataRepo is a mock of UserDataRepo interface.
 type MockUserDataRepo struct {
 	ctrl     *gomock.Controller
@@ -140,6 +287,21 @@ func (mr *MockUserDataRepoMockRecorder) AddUserIfNotExists(ctx, user interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserIfNotExists", reflect.TypeOf((*MockUserDataRepo)(nil).AddUserIfNotExists), ctx, user)
 }
 
+// ForgetUser mocks base method.
+func (m *MockUserDataRepo) ForgetUser(ctx context.Context, userID int) (*types.ForgottenUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgetUser", ctx, userID)
+	ret0, _ := ret[0].(*types.ForgottenUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ForgetUser indicates an expected call of ForgetUser.
+func (mr *MockUserDataRepoMockRecorder) ForgetUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgetUser", reflect.TypeOf((*MockUserDataRepo)(nil).ForgetUser), ctx, userID)
+}
+
 // MockBotUIRepo is a mock of BotUIRepo interface.
 type MockBotUIRepo struct {
 	ctrl     *gomock.Controller
@@ -177,6 +339,20 @@ func (mr *MockBotUIRepoMockRecorder) GetBackToMainMenuKeyboard() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBackToMainMenuKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetBackToMainMenuKeyboard))
 }
 
+// GetDaysInlineKeyboard mocks base method.
+func (m *MockBotUIRepo) GetDaysInlineKeyboard(loc *types.UserCoordinates) tgbotapi.InlineKeyboardMarkup {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDaysInlineKeyboard", loc)
+	ret0, _ := ret[0].(tgbotapi.InlineKeyboardMarkup)
+	return ret0
+}
+
+// GetDaysInlineKeyboard indicates an expected call of GetDaysInlineKeyboard.
+func (mr *MockBotUIRepoMockRecorder) GetDaysInlineKeyboard(loc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDaysInlineKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetDaysInlineKeyboard), loc)
+}
+
 // GetDaysKeyboard mocks base method.
 func (m *MockBotUIRepo) GetDaysKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	m.ctrl.T.Helper()
@@ -191,6 +367,20 @@ func (mr *MockBotUIRepoMockRecorder) GetDaysKeyboard() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDaysKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetDaysKeyboard))
 }
 
+// GetDaysOrHoursInlineKeyboard mocks base method.
+func (m *MockBotUIRepo) GetDaysOrHoursInlineKeyboard(loc *types.UserCoordinates) tgbotapi.InlineKeyboardMarkup {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDaysOrHoursInlineKeyboard", loc)
+	ret0, _ := ret[0].(tgbotapi.InlineKeyboardMarkup)
+	return ret0
+}
+
+// GetDaysOrHoursInlineKeyboard indicates an expected call of GetDaysOrHoursInlineKeyboard.
+func (mr *MockBotUIRepoMockRecorder) GetDaysOrHoursInlineKeyboard(loc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDaysOrHoursInlineKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetDaysOrHoursInlineKeyboard), loc)
+}
+
 // GetDaysOrHoursKeyboard mocks base method.
 func (m *MockBotUIRepo) GetDaysOrHoursKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	m.ctrl.T.Helper()
@@ -205,6 +395,52 @@ func (mr *MockBotUIRepoMockRecorder) GetDaysOrHoursKeyboard() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDaysOrHoursKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetDaysOrHoursKeyboard))
 }
 
+// GetForgetKeyboard mocks base method.
+func (m *MockBotUIRepo) GetForgetKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForgetKeyboard")
+	ret0, _ := ret[0].(tgbotapi.ReplyKeyboardMarkup)
+	return ret0
+}
+
+// GetForgetKeyboard indicates an expected call of GetForgetKeyboard.
+func (mr *MockBotUIRepoMockRecorder) GetForgetKeyboard() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForgetKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetForgetKeyboard))
+}
+
+// GetHistoryKeyboard mocks base method.
+func (m *MockBotUIRepo) GetHistoryKeyboard(locations ...string) tgbotapi.ReplyKeyboardMarkup {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range locations {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetHistoryKeyboard", varargs...)
+	ret0, _ := ret[0].(tgbotapi.ReplyKeyboardMarkup)
+	return ret0
+}
+
+// GetHistoryKeyboard indicates an expected call of GetHistoryKeyboard.
+func (mr *MockBotUIRepoMockRecorder) GetHistoryKeyboard(locations ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistoryKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetHistoryKeyboard), locations...)
+}
+
+// GetHoursInlineKeyboard mocks base method.
+func (m *MockBotUIRepo) GetHoursInlineKeyboard(loc *types.UserCoordinates) tgbotapi.InlineKeyboardMarkup {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHoursInlineKeyboard", loc)
+	ret0, _ := ret[0].(tgbotapi.InlineKeyboardMarkup)
+	return ret0
+}
+
+// GetHoursInlineKeyboard indicates an expected call of GetHoursInlineKeyboard.
+func (mr *MockBotUIRepoMockRecorder) GetHoursInlineKeyboard(loc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHoursInlineKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetHoursInlineKeyboard), loc)
+}
+
 // GetHoursKeyboard mocks base method.
 func (m *MockBotUIRepo) GetHoursKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	m.ctrl.T.Helper()
@@ -220,17 +456,21 @@ func (mr *MockBotUIRepoMockRecorder) GetHoursKeyboard() *gomock.Call {
 }
 
 // GetMainMenuKeyboard mocks base method.
-func (m *MockBotUIRepo) GetMainMenuKeyboard() tgbotapi.ReplyKeyboardMarkup {
+func (m *MockBotUIRepo) GetMainMenuKeyboard(favorites ...string) tgbotapi.ReplyKeyboardMarkup {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMainMenuKeyboard")
+	varargs := []interface{}{}
+	for _, a := range favorites {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetMainMenuKeyboard", varargs...)
 	ret0, _ := ret[0].(tgbotapi.ReplyKeyboardMarkup)
 	return ret0
 }
 
 // GetMainMenuKeyboard indicates an expected call of GetMainMenuKeyboard.
-func (mr *MockBotUIRepoMockRecorder) GetMainMenuKeyboard() *gomock.Call {
+func (mr *MockBotUIRepoMockRecorder) GetMainMenuKeyboard(favorites ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMainMenuKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetMainMenuKeyboard))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMainMenuKeyboard", reflect.TypeOf((*MockBotUIRepo)(nil).GetMainMenuKeyboard), favorites...)
 }
 
 // MockLocationRepo is a mock of LocationRepo interface.
@@ -308,6 +548,36 @@ func (mr *MockUserLocationRepoMockRecorder) AddUserLocationByCoordinates(ctx, us
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserLocationByCoordinates", reflect.TypeOf((*MockUserLocationRepo)(nil).AddUserLocationByCoordinates), ctx, userID, loc)
 }
 
+// GetUserLocationByName mocks base method.
+func (m *MockUserLocationRepo) GetUserLocationByName(ctx context.Context, userID int, locationName string) (*types.RecentLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLocationByName", ctx, userID, locationName)
+	ret0, _ := ret[0].(*types.RecentLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLocationByName indicates an expected call of GetUserLocationByName.
+func (mr *MockUserLocationRepoMockRecorder) GetUserLocationByName(ctx, userID, locationName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLocationByName", reflect.TypeOf((*MockUserLocationRepo)(nil).GetUserLocationByName), ctx, userID, locationName)
+}
+
+// GetUserLocationHistory mocks base method.
+func (m *MockUserLocationRepo) GetUserLocationHistory(ctx context.Context, userID, limit int) ([]types.RecentLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLocationHistory", ctx, userID, limit)
+	ret0, _ := ret[0].([]types.RecentLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLocationHistory indicates an expected call of GetUserLocationHistory.
+func (mr *MockUserLocationRepoMockRecorder) GetUserLocationHistory(ctx, userID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLocationHistory", reflect.TypeOf((*MockUserLocationRepo)(nil).GetUserLocationHistory), ctx, userID, limit)
+}
+
 // GetUserRecentLocation mocks base method.
 func (m *MockUserLocationRepo) GetUserRecentLocation(ctx context.Context, userID int) (*types.UserCoordinates, error) {
 	m.ctrl.T.Helper()
@@ -337,6 +607,348 @@ func (mr *MockUserLocationRepoMockRecorder) SaveUserLocationName(ctx, userID, lo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUserLocationName", reflect.TypeOf((*MockUserLocationRepo)(nil).SaveUserLocationName), ctx, userID, locationName)
 }
 
+// MockFavoriteRepo is a mock of FavoriteRepo interface.
+type MockFavoriteRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockFavoriteRepoMockRecorder
+}
+
+// MockFavoriteRepoMockRecorder is the mock recorder for MockFavoriteRepo.
+type MockFavoriteRepoMockRecorder struct {
+	mock *MockFavoriteRepo
+}
+
+// NewMockFavoriteRepo creates a new mock instance.
+func NewMockFavoriteRepo(ctrl *gomock.Controller) *MockFavoriteRepo {
+	mock := &MockFavoriteRepo{ctrl: ctrl}
+	mock.recorder = &MockFavoriteRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFavoriteRepo) EXPECT() *MockFavoriteRepoMockRecorder {
+	return m.recorder
+}
+
+// DeleteFavorite mocks base method.
+func (m *MockFavoriteRepo) DeleteFavorite(ctx context.Context, userID int, name string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFavorite", ctx, userID, name)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFavorite indicates an expected call of DeleteFavorite.
+func (mr *MockFavoriteRepoMockRecorder) DeleteFavorite(ctx, userID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFavorite", reflect.TypeOf((*MockFavoriteRepo)(nil).DeleteFavorite), ctx, userID, name)
+}
+
+// GetFavorite mocks base method.
+func (m *MockFavoriteRepo) GetFavorite(ctx context.Context, userID int, name string) (*types.Favorite, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFavorite", ctx, userID, name)
+	ret0, _ := ret[0].(*types.Favorite)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFavorite indicates an expected call of GetFavorite.
+func (mr *MockFavoriteRepoMockRecorder) GetFavorite(ctx, userID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavorite", reflect.TypeOf((*MockFavoriteRepo)(nil).GetFavorite), ctx, userID, name)
+}
+
+// ListFavorites mocks base method.
+func (m *MockFavoriteRepo) ListFavorites(ctx context.Context, userID int) ([]types.Favorite, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFavorites", ctx, userID)
+	ret0, _ := ret[0].([]types.Favorite)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFavorites indicates an expected call of ListFavorites.
+func (mr *MockFavoriteRepoMockRecorder) ListFavorites(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFavorites", reflect.TypeOf((*MockFavoriteRepo)(nil).ListFavorites), ctx, userID)
+}
+
+// RenameFavorite mocks base method.
+func (m *MockFavoriteRepo) RenameFavorite(ctx context.Context, userID int, oldName, newName string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameFavorite", ctx, userID, oldName, newName)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenameFavorite indicates an expected call of RenameFavorite.
+func (mr *MockFavoriteRepoMockRecorder) RenameFavorite(ctx, userID, oldName, newName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameFavorite", reflect.TypeOf((*MockFavoriteRepo)(nil).RenameFavorite), ctx, userID, oldName, newName)
+}
+
+// SaveFavorite mocks base method.
+func (m *MockFavoriteRepo) SaveFavorite(ctx context.Context, userID int, name string, loc *types.UserCoordinates) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFavorite", ctx, userID, name, loc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFavorite indicates an expected call of SaveFavorite.
+func (mr *MockFavoriteRepoMockRecorder) SaveFavorite(ctx, userID, name, loc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFavorite", reflect.TypeOf((*MockFavoriteRepo)(nil).SaveFavorite), ctx, userID, name, loc)
+}
+
+// MockConversationRepo is a mock of ConversationRepo interface.
+type MockConversationRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockConversationRepoMockRecorder
+}
+
+// MockConversationRepoMockRecorder is the mock recorder for MockConversationRepo.
+type MockConversationRepoMockRecorder struct {
+	mock *MockConversationRepo
+}
+
+// NewMockConversationRepo creates a new mock instance.
+func NewMockConversationRepo(ctrl *gomock.Controller) *MockConversationRepo {
+	mock := &MockConversationRepo{ctrl: ctrl}
+	mock.recorder = &MockConversationRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockConversationRepo) EXPECT() *MockConversationRepoMockRecorder {
+	return m.recorder
+}
+
+// GetConversation mocks base method.
+func (m *MockConversationRepo) GetConversation(ctx context.Context, chatID int64) (*types.Conversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConversation", ctx, chatID)
+	ret0, _ := ret[0].(*types.Conversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConversation indicates an expected call of GetConversation.
+func (mr *MockConversationRepoMockRecorder) GetConversation(ctx, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConversation", reflect.TypeOf((*MockConversationRepo)(nil).GetConversation), ctx, chatID)
+}
+
+// SaveConversation mocks base method.
+func (m *MockConversationRepo) SaveConversation(ctx context.Context, chatID int64, conv *types.Conversation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveConversation", ctx, chatID, conv)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveConversation indicates an expected call of SaveConversation.
+func (mr *MockConversationRepoMockRecorder) SaveConversation(ctx, chatID, conv interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveConversation", reflect.TypeOf((*MockConversationRepo)(nil).SaveConversation), ctx, chatID, conv)
+}
+
+// MockGroupSettingsRepo is a mock of GroupSettingsRepo interface.
+type MockGroupSettingsRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockGroupSettingsRepoMockRecorder
+}
+
+// MockGroupSettingsRepoMockRecorder is the mock recorder for MockGroupSettingsRepo.
+type MockGroupSettingsRepoMockRecorder struct {
+	mock *MockGroupSettingsRepo
+}
+
+// NewMockGroupSettingsRepo creates a new mock instance.
+func NewMockGroupSettingsRepo(ctrl *gomock.Controller) *MockGroupSettingsRepo {
+	mock := &MockGroupSettingsRepo{ctrl: ctrl}
+	mock.recorder = &MockGroupSettingsRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGroupSettingsRepo) EXPECT() *MockGroupSettingsRepoMockRecorder {
+	return m.recorder
+}
+
+// GetGroupSettings mocks base method.
+func (m *MockGroupSettingsRepo) GetGroupSettings(ctx context.Context, chatID int64) (*types.GroupSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroupSettings", ctx, chatID)
+	ret0, _ := ret[0].(*types.GroupSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroupSettings indicates an expected call of GetGroupSettings.
+func (mr *MockGroupSettingsRepoMockRecorder) GetGroupSettings(ctx, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroupSettings", reflect.TypeOf((*MockGroupSettingsRepo)(nil).GetGroupSettings), ctx, chatID)
+}
+
+// SaveGroupLanguage mocks base method.
+func (m *MockGroupSettingsRepo) SaveGroupLanguage(ctx context.Context, chatID int64, language string, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveGroupLanguage", ctx, chatID, language, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveGroupLanguage indicates an expected call of SaveGroupLanguage.
+func (mr *MockGroupSettingsRepoMockRecorder) SaveGroupLanguage(ctx, chatID, language, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveGroupLanguage", reflect.TypeOf((*MockGroupSettingsRepo)(nil).SaveGroupLanguage), ctx, chatID, language, userID)
+}
+
+// SaveGroupLocation mocks base method.
+func (m *MockGroupSettingsRepo) SaveGroupLocation(ctx context.Context, chatID int64, locationName string, loc *types.UserCoordinates, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveGroupLocation", ctx, chatID, locationName, loc, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveGroupLocation indicates an expected call of SaveGroupLocation.
+func (mr *MockGroupSettingsRepoMockRecorder) SaveGroupLocation(ctx, chatID, locationName, loc, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveGroupLocation", reflect.TypeOf((*MockGroupSettingsRepo)(nil).SaveGroupLocation), ctx, chatID, locationName, loc, userID)
+}
+
+// MockUpdateOffsetRepo is a mock of UpdateOffsetRepo interface.
+type MockUpdateOffsetRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockUpdateOffsetRepoMockRecorder
+}
+
+// MockUpdateOffsetRepoMockRecorder is the mock recorder for MockUpdateOffsetRepo.
+type MockUpdateOffsetRepoMockRecorder struct {
+	mock *MockUpdateOffsetRepo
+}
+
+// NewMockUpdateOffsetRepo creates a new mock instance.
+func NewMockUpdateOffsetRepo(ctrl *gomock.Controller) *MockUpdateOffsetRepo {
+	mock := &MockUpdateOffsetRepo{ctrl: ctrl}
+	mock.recorder = &MockUpdateOffsetRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUpdateOffsetRepo) EXPECT() *MockUpdateOffsetRepoMockRecorder {
+	return m.recorder
+}
+
+// GetUpdateOffset mocks base method.
+func (m *MockUpdateOffsetRepo) GetUpdateOffset(ctx context.Context, botID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdateOffset", ctx, botID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpdateOffset indicates an expected call of GetUpdateOffset.
+func (mr *MockUpdateOffsetRepoMockRecorder) GetUpdateOffset(ctx, botID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdateOffset", reflect.TypeOf((*MockUpdateOffsetRepo)(nil).GetUpdateOffset), ctx, botID)
+}
+
+// SaveUpdateOffset mocks base method.
+func (m *MockUpdateOffsetRepo) SaveUpdateOffset(ctx context.Context, botID, offset int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveUpdateOffset", ctx, botID, offset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveUpdateOffset indicates an expected call of SaveUpdateOffset.
+func (mr *MockUpdateOffsetRepoMockRecorder) SaveUpdateOffset(ctx, botID, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUpdateOffset", reflect.TypeOf((*MockUpdateOffsetRepo)(nil).SaveUpdateOffset), ctx, botID, offset)
+}
+
+// MockUpdateStore is a mock of UpdateStore interface.
+type MockUpdateStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockUpdateStoreMockRecorder
+}
+
+// MockUpdateStoreMockRecorder is the mock recorder for MockUpdateStore.
+type MockUpdateStoreMockRecorder struct {
+	mock *MockUpdateStore
+}
+
+// NewMockUpdateStore creates a new mock instance.
+func NewMockUpdateStore(ctrl *gomock.Controller) *MockUpdateStore {
+	mock := &MockUpdateStore{ctrl: ctrl}
+	mock.recorder = &MockUpdateStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUpdateStore) EXPECT() *MockUpdateStoreMockRecorder {
+	return m.recorder
+}
+
+// Save mocks base method.
+func (m *MockUpdateStore) Save(ctx context.Context, upd tgbotapi.Update) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, upd)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockUpdateStoreMockRecorder) Save(ctx, upd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockUpdateStore)(nil).Save), ctx, upd)
+}
+
+// MockInboxRepo is a mock of InboxRepo interface.
+type MockInboxRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockInboxRepoMockRecorder
+}
+
+// MockInboxRepoMockRecorder is the mock recorder for MockInboxRepo.
+type MockInboxRepoMockRecorder struct {
+	mock *MockInboxRepo
+}
+
+// NewMockInboxRepo creates a new mock instance.
+func NewMockInboxRepo(ctrl *gomock.Controller) *MockInboxRepo {
+	mock := &MockInboxRepo{ctrl: ctrl}
+	mock.recorder = &MockInboxRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInboxRepo) EXPECT() *MockInboxRepoMockRecorder {
+	return m.recorder
+}
+
+// ReplayDead mocks base method.
+func (m *MockInboxRepo) ReplayDead(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDead", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayDead indicates an expected call of ReplayDead.
+func (mr *MockInboxRepoMockRecorder) ReplayDead(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDead", reflect.TypeOf((*MockInboxRepo)(nil).ReplayDead), ctx)
+}
+
 // MockReportFormatter is a mock of ReportFormatter interface.
 type MockReportFormatter struct {
 	ctrl     *gomock.Controller
@@ -360,6 +972,20 @@ func (m *MockReportFormatter) EXPECT() *MockReportFormatterMockRecorder {
 	return m.recorder
 }
 
+// FormatComparison mocks base method.
+func (m *MockReportFormatter) FormatComparison(ctx context.Context, reports []*types.FullWeatherReport, days int) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FormatComparison", ctx, reports, days)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// FormatComparison indicates an expected call of FormatComparison.
+func (mr *MockReportFormatterMockRecorder) FormatComparison(ctx, reports, days interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FormatComparison", reflect.TypeOf((*MockReportFormatter)(nil).FormatComparison), ctx, reports, days)
+}
+
 // FormatDays mocks base method.
 func (m *MockReportFormatter) FormatDays(ctx context.Context, report *types.FullWeatherReport, days int) string {
 	m.ctrl.T.Helper()
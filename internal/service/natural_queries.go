@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"time"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	// hourlyLayout is how the provider writes the local time of an hour.
+	hourlyLayout = "2006-01-02T15:04:05"
+	// dailyLayout is how the provider writes the local date of a day.
+	dailyLayout = "2006-01-02"
+)
+
+// WithGrammars makes the service answer queries typed in words, like 'tomorrow in Paris',
+// in the languages of the grammars.
+func (s *MessageService) WithGrammars(grammars ...Grammar) *MessageService {
+	s.grammars = grammars
+	return s
+}
+
+// naturalQuery reads the text with the grammar of the language first and with the others if it fails.
+func (s *MessageService) naturalQuery(text, lang string) (*types.NaturalQuery, bool) {
+	for _, g := range s.grammars {
+		if g.Language() != lang {
+			continue
+		}
+		if q, ok := g.Parse(text); ok {
+			return q, true
+		}
+	}
+
+	for _, g := range s.grammars {
+		if g.Language() == lang {
+			continue
+		}
+		if q, ok := g.Parse(text); ok {
+			return q, true
+		}
+	}
+
+	return nil, false
+}
+
+// handleNaturalQuery replies with the forecast for the place and the time the query asks about.
+func (s *MessageService) handleNaturalQuery(ctx context.Context, req *bot.Message, q *types.NaturalQuery) error {
+	ctxlogrus.Extract(ctx).Debugf("Handling '%s' for '%s'", req.Text, q.Place)
+
+	var usage usageError
+
+	loc, err := s.locationOf(ctx, req, &forecastQuery{city: q.Place})
+	if errors.As(err, &usage) {
+		return s.reply(ctx, req, usage.Error())
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	period, err := windowPeriod(q.Window, time.Now().UTC().Weekday())
+	if errors.As(err, &usage) {
+		return s.reply(ctx, req, usage.Error())
+	}
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	wr, err := s.forecast.GetForecast(ctx, loc, period)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+
+	stats, err := sliceReport(wr, q.Window, period.Unit)
+	if err != nil {
+		return errors.Wrapf(err, types.ErrOnHandling, req.Text)
+	}
+	if len(stats) == 0 {
+		return s.reply(ctx, req, commentsEn["NothingForecast"])
+	}
+
+	sliced := &types.FullWeatherReport{CityName: wr.CityName, Data: stats, Count: len(stats)}
+
+	return s.reply(ctx, req, s.formatPeriod(ctx, sliced, types.Period{Unit: period.Unit, Length: len(stats)}))
+}
+
+// windowPeriod returns the period to ask the provider for, so that it covers the window.
+// Today at the place may be a day off today here, so the period is a day longer where the provider allows it.
+func windowPeriod(w types.TimeWindow, today time.Weekday) (types.Period, error) {
+	if w.Part == nil {
+		from, to := w.Days(today)
+		if from >= types.MaxForecastDays {
+			return types.Period{}, usageError(periodUsage(types.Days(from + 1)))
+		}
+		return types.Days(minInt(to+2, types.MaxForecastDays)), nil
+	}
+
+	from, to := w.Hours(today)
+	if from >= types.MaxForecastHours {
+		return types.Period{}, usageError(periodUsage(types.Hours(from)))
+	}
+	return types.Hours(minInt(to+24, types.MaxForecastHours)), nil
+}
+
+// sliceReport returns the hours or days of the report within the window. The first of them tells today at the place.
+func sliceReport(wr *types.FullWeatherReport, w types.TimeWindow, unit types.PeriodUnit) ([]*types.Stat, error) {
+	if len(wr.Data) == 0 {
+		return nil, nil
+	}
+
+	layout, timeOf := dailyLayout, func(s *types.Stat) string { return s.DateTime }
+	if unit == types.PeriodHours {
+		layout, timeOf = hourlyLayout, func(s *types.Stat) string { return s.TimestampLocal }
+	}
+
+	first, err := time.Parse(layout, timeOf(wr.Data[0]))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the time of the forecast")
+	}
+	midnight := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+
+	from, to := w.Hours(midnight.Weekday())
+
+	var stats []*types.Stat
+	for _, s := range wr.Data {
+		t, err := time.Parse(layout, timeOf(s))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read the time of the forecast")
+		}
+
+		hour := int(t.Sub(midnight).Hours())
+		if hour >= from && hour < to {
+			stats = append(stats, s)
+		}
+	}
+
+	return stats, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+	"weather-or-not-bot/internal/service/mock"
+	"weather-or-not-bot/internal/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+func TestWindowPeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    types.TimeWindow
+		today     time.Weekday
+		want      types.Period
+		wantUsage bool
+	}{
+		{"1. Whole day asks for a day more", types.TimeWindow{From: 1, To: 1}, time.Monday, types.Days(3), false},
+		{"2. Weekend on Wednesday", types.TimeWindow{OnWeekday: true, Weekday: time.Saturday, To: 1}, time.Wednesday, types.Days(6), false},
+		{"3. Part of the day asks for hours", types.TimeWindow{From: 1, To: 1, Part: &types.Evening}, time.Monday, types.Hours(72), false},
+		{"4. Hours beyond the provider's range are cut", types.TimeWindow{OnWeekday: true, Weekday: time.Friday, Part: &types.Morning}, time.Monday, types.Hours(types.MaxForecastHours), false},
+		{"5. Too far for hours", types.TimeWindow{OnWeekday: true, Weekday: time.Saturday, Part: &types.Night}, time.Sunday, types.Period{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := windowPeriod(tt.window, tt.today)
+
+			var usage usageError
+			if errors.As(err, &usage) != tt.wantUsage {
+				t.Fatalf("windowPeriod() error = %v, wantUsage %v", err, tt.wantUsage)
+			}
+			if got != tt.want {
+				t.Errorf("windowPeriod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSliceReport(t *testing.T) {
+	// 2026-10-18 is a Sunday.
+	days := &types.FullWeatherReport{Data: []*types.Stat{
+		{DateTime: "2026-10-18"}, {DateTime: "2026-10-19"}, {DateTime: "2026-10-20"}, {DateTime: "2026-10-21"},
+	}}
+	hours := &types.FullWeatherReport{Data: []*types.Stat{
+		{TimestampLocal: "2026-10-18T22:00:00"}, {TimestampLocal: "2026-10-18T23:00:00"},
+		{TimestampLocal: "2026-10-19T00:00:00"}, {TimestampLocal: "2026-10-19T06:00:00"},
+		{TimestampLocal: "2026-10-19T07:00:00"}, {TimestampLocal: "2026-10-19T12:00:00"},
+	}}
+
+	tests := []struct {
+		name    string
+		report  *types.FullWeatherReport
+		window  types.TimeWindow
+		unit    types.PeriodUnit
+		want    []*types.Stat
+		wantErr bool
+	}{
+		{"1. Tomorrow", days, types.TimeWindow{From: 1, To: 1}, types.PeriodDays, days.Data[1:2], false},
+		{"2. Weekend that has begun", days, types.TimeWindow{OnWeekday: true, Weekday: time.Saturday, To: 1}, types.PeriodDays, days.Data[:1], false},
+		{"3. Monday and Tuesday", days, types.TimeWindow{OnWeekday: true, Weekday: time.Monday, To: 1}, types.PeriodDays, days.Data[1:3], false},
+		{"4. Tomorrow morning", hours, types.TimeWindow{From: 1, To: 1, Part: &types.Morning}, types.PeriodHours, hours.Data[3:5], false},
+		{"5. Tonight past midnight", hours, types.TimeWindow{Part: &types.Tonight}, types.PeriodHours, hours.Data[:3], false},
+		{"6. Broken time", &types.FullWeatherReport{Data: []*types.Stat{{DateTime: "18.10.2026"}}}, types.TimeWindow{}, types.PeriodDays, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sliceReport(tt.report, tt.window, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sliceReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sliceReport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageService_HandleNaturalQuery(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	chatID := int64(123)
+	user := &bot.User{ID: 122334, UserName: "the_john", LanguageCode: "en"}
+	uLoc := &types.UserCoordinates{LocationID: 31415, Latitude: "12.32", Longitude: "45.16"}
+	paris := &types.UserCoordinates{Latitude: "48.856600", Longitude: "2.352200"}
+
+	days := &types.FullWeatherReport{CityName: "Paris", Data: []*types.Stat{
+		{DateTime: "2026-10-18"}, {DateTime: "2026-10-19"}, {DateTime: "2026-10-20"},
+	}}
+	var hourly []*types.Stat
+	for h := 10; h < 58; h++ {
+		hourly = append(hourly, &types.Stat{TimestampLocal: fmt.Sprintf("2026-10-%02dT%02d:00:00", 18+h/24, h%24)})
+	}
+	hours := &types.FullWeatherReport{CityName: "Lyon", Data: hourly}
+
+	newUpdate := func(text string) *bot.Update {
+		return &bot.Update{UpdateID: 1, Message: &bot.Message{MessageID: 11, Text: text, From: user, Chat: &bot.Chat{ID: chatID}}}
+	}
+
+	type mocks struct {
+		bc  *mock.MockBotClient
+		fc  *mock.MockForecastClient
+		f   *mock.MockReportFormatter
+		lr  *mock.MockLocationRepo
+		ulr *mock.MockUserLocationRepo
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(m mocks)
+		upd     *bot.Update
+		wantErr bool
+	}{
+		{
+			name: "1. Tomorrow in a city",
+			prepare: func(m mocks) {
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Paris").Return(&bot.Location{Latitude: 48.8566, Longitude: 2.3522}, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), paris, types.Days(3)).Return(days, nil)
+				m.f.EXPECT().FormatDays(gomock.Any(), &types.FullWeatherReport{CityName: "Paris", Data: days.Data[1:2], Count: 1}, 1).Return("days_report")
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, "days_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("tomorrow in Paris"),
+			wantErr: false,
+		},
+		{
+			name: "2. Tomorrow evening at the recent location",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Hours(72)).Return(hours, nil)
+				m.f.EXPECT().FormatHours(gomock.Any(), &types.FullWeatherReport{CityName: "Lyon", Data: hourly[32:38], Count: 6}, 6).Return("hours_report")
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, "hours_report")).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("tomorrow evening"),
+			wantErr: false,
+		},
+		{
+			name: "3. City not found",
+			prepare: func(m mocks) {
+				m.lr.EXPECT().GetCoordinatesByCityName(gomock.Any(), "Nowhere").Return(&bot.Location{}, errors.Wrap(sql.ErrNoRows, "cannot get coordinates"))
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, fmt.Sprintf(commentsEn["CityNotFound"], "Nowhere"))).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("Nowhere tomorrow"),
+			wantErr: false,
+		},
+		{
+			name: "4. Nothing forecast for the time",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Days(4)).Return(&types.FullWeatherReport{Data: days.Data[:2]}, nil)
+				m.bc.EXPECT().Send(bot.NewMessage(chatID, commentsEn["NothingForecast"])).Return(bot.Message{}, nil)
+			},
+			upd:     newUpdate("the day after tomorrow"),
+			wantErr: false,
+		},
+		{
+			name: "5. Error on getting a forecast",
+			prepare: func(m mocks) {
+				m.ulr.EXPECT().GetUserRecentLocation(gomock.Any(), user.ID).Return(uLoc, nil)
+				m.fc.EXPECT().GetForecast(gomock.Any(), uLoc, types.Days(2)).Return(nil, someErr)
+			},
+			upd:     newUpdate("today"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				bc:  mock.NewMockBotClient(ctrl),
+				fc:  mock.NewMockForecastClient(ctrl),
+				f:   mock.NewMockReportFormatter(ctrl),
+				lr:  mock.NewMockLocationRepo(ctrl),
+				ulr: mock.NewMockUserLocationRepo(ctrl),
+			}
+			tt.prepare(m)
+
+			s := NewMessageService(m.bc, m.fc, m.f, mock.NewMockBotUIRepo(ctrl), m.lr, m.ulr, mock.NewMockUserDataRepo(ctrl)).
+				WithGrammars(EnglishGrammar{})
+			if err := s.HandleNewMessage(ctx, tt.upd); (err != nil) != tt.wantErr {
+				t.Errorf("HandleNewMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"strings"
 	"weather-or-not-bot/internal/types"
 )
 
@@ -52,7 +53,7 @@ func (f *Formatter) FormatNow(ctx context.Context, report *types.FullWeatherRepo
 	return buf.String()
 }
 
-// FormatHours formats hours.
+// FormatHours formats hours, as many as the report has if it has fewer.
 func (f *Formatter) FormatHours(ctx context.Context, report *types.FullWeatherReport, hours int) string {
 	ctxlogrus.Extract(ctx).Debug("Running format hours")
 
@@ -65,7 +66,7 @@ func (f *Formatter) FormatHours(ctx context.Context, report *types.FullWeatherRe
 
 	buf.WriteString(formatCity(report.CityName))
 
-	for i := 0; i < hours; i++ {
+	for i := 0; i < minInt(hours, len(report.Data)); i++ {
 		if lastWeatherCode == report.Data[i].Code {
 			continue
 		}
@@ -84,19 +85,49 @@ func (f *Formatter) FormatHours(ctx context.Context, report *types.FullWeatherRe
 	return buf.String()
 }
 
-// FormatDays formats days.
+// FormatDays formats days, as many as the report has if it has fewer.
 func (f *Formatter) FormatDays(ctx context.Context, report *types.FullWeatherReport, days int) string {
 	ctxlogrus.Extract(ctx).Debug("Running format days")
 
 	var buf bytes.Buffer
 	buf.WriteString(formatCity(report.CityName))
-	for i := 0; i < days; i++ {
+	for i := 0; i < minInt(days, len(report.Data)); i++ {
 		buf.WriteString(formatDay(report.Data[i]))
 	}
 
 	return buf.String()
 }
 
+// FormatComparison formats the days of several reports side by side, a line for every city under every date.
+func (f *Formatter) FormatComparison(ctx context.Context, reports []*types.FullWeatherReport, days int) string {
+	ctxlogrus.Extract(ctx).Debug("Running format comparison")
+
+	var (
+		buf    bytes.Buffer
+		cities = make([]string, 0, len(reports))
+	)
+	for _, r := range reports {
+		cities = append(cities, r.CityName)
+	}
+	buf.WriteString(formatCity(strings.Join(cities, ", ")))
+
+	for i := 0; i < days; i++ {
+		dated := false
+		for _, r := range reports {
+			if i >= len(r.Data) {
+				continue
+			}
+			if !dated {
+				buf.WriteString(fmt.Sprintln(formatDate(r.Data[i])))
+				dated = true
+			}
+			buf.WriteString(formatComparedDay(r.CityName, r.Data[i]))
+		}
+	}
+
+	return buf.String()
+}
+
 func formatHour(s *types.Stat) string {
 	var buf bytes.Buffer
 	for i := range lineFormatterHours {
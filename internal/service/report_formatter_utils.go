@@ -65,6 +65,7 @@ const (
 	SunSunset  = "Sunset"
 
 	WeatherCityPrefix = "Weather in"
+	PrecipitationUnit = "mm"
 
 	Day      = "d"
 	UVPrefix = "UV Index"
@@ -250,10 +251,15 @@ func formatUv(s *types.Stat) string {
 
 // formatWeatherCode formats WeatherCode into a string, adding an emoji and a description.
 func formatWeatherCode(s *types.Stat) string {
+	return fmt.Sprintf("%c %s ", weatherEmoji(s.Weather.Code), s.Weather.Description)
+}
+
+// weatherEmoji returns the emoji of the weather code.
+func weatherEmoji(code int) types.Emoji {
 	var emoji types.Emoji
 
 	//TODO replace codes with constants
-	switch s.Weather.Code {
+	switch code {
 	case 200, 201, 202:
 		emoji = types.ThunderRainEmoji
 	case 230, 231, 232, 233:
@@ -284,7 +290,7 @@ func formatWeatherCode(s *types.Stat) string {
 		emoji = types.QuestionMarkEmoji
 	}
 
-	return fmt.Sprintf("%c %s ", emoji, s.Weather.Description)
+	return emoji
 }
 
 // formatWind formats wind direction data into a string, adding emojis and speed information.
@@ -315,6 +321,12 @@ func formatWind(s *types.Stat) string {
 	return fmt.Sprintf("%c  Wind:\n%c %v %v m/sec", types.WindEmoji, emoji, s.WindDirection, math.Round(s.WindSpeedMs))
 }
 
+// formatComparedDay formats the day of a city into a line of the comparison: the weather, high and low temperature and precipitation.
+func formatComparedDay(city string, s *types.Stat) string {
+	return fmt.Sprintf("%s: %c %s%c %v %s\n",
+		city, weatherEmoji(s.Weather.Code), formatTemperatureLowHigh(s), types.UmbrellaEmoji, math.Round(s.Precipitation*10)/10, PrecipitationUnit)
+}
+
 // formatCity formats a city into a string.
 // NB: only to be used inside FormatNow
 func formatCity(city string) string {
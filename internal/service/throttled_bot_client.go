@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	// idleChatLimiterTTL is how long a per-chat limiter is kept after the last message to the chat.
+	idleChatLimiterTTL = time.Minute
+	// noChat is the chat of calls made outside of any chat, e.g. answers to callback and inline queries.
+	noChat int64 = 0
+)
+
+// ThrottleCfg configures pacing of outgoing messages, defaults follow Telegram limits.
+type ThrottleCfg struct {
+	GlobalRate   float64       `mapstructure:"send_global_rate"`
+	ChatRate     float64       `mapstructure:"send_chat_rate"`
+	ChatBurst    int           `mapstructure:"send_chat_burst"`
+	GroupRate    float64       `mapstructure:"send_group_rate"`
+	MaxRetries   int           `mapstructure:"send_max_retries"`
+	RetryBackoff time.Duration `mapstructure:"send_retry_backoff"`
+}
+
+func init() {
+	pflag.Float64("send_global_rate", 30, "Messages per second the bot sends in total")
+	pflag.Float64("send_chat_rate", 1, "Messages per second the bot sends to a single private chat")
+	pflag.Float64("send_group_rate", 20.0/60, "Messages per second the bot sends to a single group")
+	pflag.Int("send_chat_burst", 3, "Messages the bot sends to a single chat or group at once before pacing them")
+	pflag.Int("send_max_retries", 3, "Retries of a message that failed to be sent")
+	pflag.Duration("send_retry_backoff", 500*time.Millisecond, "Pause before the first retry, doubled on every next one")
+}
+
+// NewThrottleCfgFromEnv reads the outgoing messages pacing configuration.
+func NewThrottleCfgFromEnv() ThrottleCfg {
+	var cfg ThrottleCfg
+
+	err := viper.Unmarshal(&cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot get throttle cfg from envs")
+	}
+
+	return cfg
+}
+
+// SendStats describes the outgoing messages queue.
+type SendStats struct {
+	Waiting int64
+	Sent    int64
+	Retried int64
+	Failed  int64
+}
+
+type chatLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// ThrottledBotClient paces calls made by the wrapped BotClient to stay within Telegram limits,
+// waits out 'retry_after' and retries transient failures with backoff. Waits and retries last until Close.
+type ThrottledBotClient struct {
+	BotClient
+	cfg    ThrottleCfg
+	global *rate.Limiter
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	chats map[int64]*chatLimiter
+
+	waiting int64
+	sent    int64
+	retried int64
+	failed  int64
+}
+
+func NewThrottledBotClient(next BotClient, cfg ThrottleCfg) *ThrottledBotClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ThrottledBotClient{
+		BotClient: next,
+		cfg:       cfg,
+		global:    rate.NewLimiter(rate.Limit(cfg.GlobalRate), 1),
+		ctx:       ctx,
+		cancel:    cancel,
+		chats:     map[int64]*chatLimiter{},
+	}
+}
+
+// Close stops waiting for turns and retrying, calls still waiting fail.
+func (c *ThrottledBotClient) Close() {
+	c.cancel()
+}
+
+// Send waits for its turn and sends the message, retrying when it makes sense.
+func (c *ThrottledBotClient) Send(msg bot.MessageConfig) (bot.Message, error) {
+	var resp bot.Message
+	err := c.call(msg.ChatID, func() (err error) {
+		resp, err = c.BotClient.Send(msg)
+		return err
+	})
+
+	return resp, err
+}
+
+// Edit waits for its turn and edits the message. Edits count towards the same limits as sends.
+func (c *ThrottledBotClient) Edit(msg bot.EditMessageTextConfig) (bot.Message, error) {
+	var resp bot.Message
+	err := c.call(msg.ChatID, func() (err error) {
+		resp, err = c.BotClient.Edit(msg)
+		return err
+	})
+
+	return resp, err
+}
+
+// AnswerCallback waits for its turn and answers the callback query.
+func (c *ThrottledBotClient) AnswerCallback(cb bot.CallbackConfig) error {
+	return c.call(noChat, func() error {
+		return c.BotClient.AnswerCallback(cb)
+	})
+}
+
+// AnswerInlineQuery waits for its turn and answers the inline query.
+func (c *ThrottledBotClient) AnswerInlineQuery(answer bot.InlineConfig) error {
+	return c.call(noChat, func() error {
+		return c.BotClient.AnswerInlineQuery(answer)
+	})
+}
+
+// GetChatMember waits for its turn and gets the chat member. Nothing is sent to the chat,
+// so only the global limit applies.
+func (c *ThrottledBotClient) GetChatMember(member bot.ChatConfigWithUser) (bot.ChatMember, error) {
+	var resp bot.ChatMember
+	err := c.call(noChat, func() (err error) {
+		resp, err = c.BotClient.GetChatMember(member)
+		return err
+	})
+
+	return resp, err
+}
+
+// call waits for the turn of the chat and makes the call, retrying when it makes sense,
+// until the client is closed. Calls made outside of any chat count towards the global limit only.
+func (c *ThrottledBotClient) call(chatID int64, call func() error) error {
+	atomic.AddInt64(&c.waiting, 1)
+	metrics.SendsWaiting.Inc()
+	defer func() {
+		atomic.AddInt64(&c.waiting, -1)
+		metrics.SendsWaiting.Dec()
+	}()
+
+	for attempt := 0; ; attempt++ {
+		err := c.wait(chatID)
+		if err == nil {
+			err = call()
+		}
+		if err == nil {
+			atomic.AddInt64(&c.sent, 1)
+			metrics.SendsTotal.WithLabelValues(metrics.OutcomeSent).Inc()
+			return nil
+		}
+
+		delay, retry := c.retryDelay(err, attempt)
+		if !retry || attempt >= c.cfg.MaxRetries || c.ctx.Err() != nil {
+			atomic.AddInt64(&c.failed, 1)
+			metrics.SendsTotal.WithLabelValues(metrics.OutcomeFailed).Inc()
+			metrics.ErrorsTotal.WithLabelValues(metrics.ErrorSend).Inc()
+			return err
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"chat_id": chatID,
+			"attempt": attempt + 1,
+			"delay":   delay,
+		}).Warn("Cannot call Bot API, retrying")
+		atomic.AddInt64(&c.retried, 1)
+		metrics.SendsTotal.WithLabelValues(metrics.OutcomeRetried).Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Stats returns the current state of the outgoing messages queue.
+func (c *ThrottledBotClient) Stats() SendStats {
+	return SendStats{
+		Waiting: atomic.LoadInt64(&c.waiting),
+		Sent:    atomic.LoadInt64(&c.sent),
+		Retried: atomic.LoadInt64(&c.retried),
+		Failed:  atomic.LoadInt64(&c.failed),
+	}
+}
+
+// ReportStats logs the queue stats every interval until ctx is done, a zero interval turns it off.
+func (c *ThrottledBotClient) ReportStats(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	log := ctxlogrus.Extract(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := c.Stats()
+			log.WithFields(logrus.Fields{
+				"waiting": stats.Waiting,
+				"sent":    stats.Sent,
+				"retried": stats.Retried,
+				"failed":  stats.Failed,
+			}).Info("Outgoing messages stats")
+		}
+	}
+}
+
+// wait blocks until both the chat and the global limits allow to call, or until the client is closed.
+func (c *ThrottledBotClient) wait(chatID int64) error {
+	if chatID != noChat {
+		err := c.chatLimiter(chatID).Wait(c.ctx)
+		if err != nil {
+			return errors.Wrap(err, "cannot wait for the turn of the chat")
+		}
+	}
+
+	err := c.global.Wait(c.ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot wait for the turn")
+	}
+
+	return nil
+}
+
+func (c *ThrottledBotClient) chatLimiter(chatID int64) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, ok := c.chats[chatID]
+	if !ok {
+		// Groups and channels have negative IDs.
+		limit := rate.Limit(c.cfg.ChatRate)
+		if chatID < 0 {
+			limit = rate.Limit(c.cfg.GroupRate)
+		}
+
+		burst := c.cfg.ChatBurst
+		if burst < 1 {
+			burst = 1
+		}
+
+		cl = &chatLimiter{limiter: rate.NewLimiter(limit, burst)}
+		c.chats[chatID] = cl
+	}
+	cl.lastUsed = time.Now()
+
+	return cl.limiter
+}
+
+// SweepIdleChats drops limiters of chats nothing was sent to for a while, every while until ctx is done.
+func (c *ThrottledBotClient) SweepIdleChats(ctx context.Context) {
+	ticker := time.NewTicker(idleChatLimiterTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.sweepIdleChats(now)
+		}
+	}
+}
+
+func (c *ThrottledBotClient) sweepIdleChats(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cl := range c.chats {
+		if now.Sub(cl.lastUsed) > idleChatLimiterTTL {
+			delete(c.chats, id)
+		}
+	}
+}
+
+// retryDelay tells whether a failed call is worth retrying and how long to wait before that.
+func (c *ThrottledBotClient) retryDelay(err error, attempt int) (time.Duration, bool) {
+	backoff := c.cfg.RetryBackoff << uint(attempt)
+
+	var apiErr bot.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			return time.Duration(apiErr.RetryAfter) * time.Second, true
+		}
+
+		if isServerError(apiErr.Message) {
+			return backoff, true
+		}
+
+		// Telegram refused the message itself, sending it again won't help.
+		return 0, false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return backoff, true
+	}
+
+	// Gateway errors come as HTML pages instead of JSON.
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return backoff, true
+	}
+
+	return 0, false
+}
+
+// isServerError tells whether Telegram failed on its side. Such failures are described with the status text,
+// e.g. 'Internal Server Error' or 'Bad Gateway'.
+func isServerError(description string) bool {
+	for code := http.StatusInternalServerError; code <= http.StatusNetworkAuthenticationRequired; code++ {
+		text := http.StatusText(code)
+		if text != "" && strings.HasPrefix(description, text) {
+			return true
+		}
+	}
+
+	return false
+}
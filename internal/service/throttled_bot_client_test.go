@@ -0,0 +1,184 @@
+package service
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	bot "gopkg.in/telegram-bot-api.v4"
+	"weather-or-not-bot/internal/service/mock"
+)
+
+func TestThrottledBotClient_Send(t *testing.T) {
+	msg := bot.NewMessage(123, "some_text")
+	cfg := ThrottleCfg{GlobalRate: 1000, ChatRate: 1000, GroupRate: 1000, MaxRetries: 2, RetryBackoff: time.Millisecond}
+	transientErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		prepare   func(bc *mock.MockBotClient)
+		wantErr   bool
+		wantStats SendStats
+	}{
+		{
+			name: "1. Success on first attempt",
+			prepare: func(bc *mock.MockBotClient) {
+				bc.EXPECT().Send(msg).Return(bot.Message{}, nil)
+			},
+			wantErr:   false,
+			wantStats: SendStats{Sent: 1},
+		},
+		{
+			name: "2. Success after a transient failure",
+			prepare: func(bc *mock.MockBotClient) {
+				gomock.InOrder(
+					bc.EXPECT().Send(msg).Return(bot.Message{}, transientErr),
+					bc.EXPECT().Send(msg).Return(bot.Message{}, nil),
+				)
+			},
+			wantErr:   false,
+			wantStats: SendStats{Sent: 1, Retried: 1},
+		},
+		{
+			name: "3. Error after running out of retries",
+			prepare: func(bc *mock.MockBotClient) {
+				bc.EXPECT().Send(msg).Return(bot.Message{}, transientErr).Times(3)
+			},
+			wantErr:   true,
+			wantStats: SendStats{Retried: 2, Failed: 1},
+		},
+		{
+			name: "4. Error refused by Telegram is not retried",
+			prepare: func(bc *mock.MockBotClient) {
+				bc.EXPECT().Send(msg).Return(bot.Message{}, bot.Error{Message: "Forbidden: bot was blocked by the user"})
+			},
+			wantErr:   true,
+			wantStats: SendStats{Failed: 1},
+		},
+		{
+			name: "5. Success after Telegram failed on its side",
+			prepare: func(bc *mock.MockBotClient) {
+				gomock.InOrder(
+					bc.EXPECT().Send(msg).Return(bot.Message{}, bot.Error{Message: "Bad Gateway"}),
+					bc.EXPECT().Send(msg).Return(bot.Message{}, nil),
+				)
+			},
+			wantErr:   false,
+			wantStats: SendStats{Sent: 1, Retried: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bc := mock.NewMockBotClient(ctrl)
+			tt.prepare(bc)
+
+			c := NewThrottledBotClient(bc, cfg)
+			if _, err := c.Send(msg); (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := c.Stats(); got != tt.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestThrottledBotClient_Send_GivesUpOnClose(t *testing.T) {
+	msg := bot.NewMessage(123, "some_text")
+	cfg := ThrottleCfg{GlobalRate: 1000, ChatRate: 0.001, ChatBurst: 1, GroupRate: 1000, MaxRetries: 2, RetryBackoff: time.Millisecond}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bc := mock.NewMockBotClient(ctrl)
+	bc.EXPECT().Send(msg).Return(bot.Message{}, nil)
+
+	c := NewThrottledBotClient(bc, cfg)
+	if _, err := c.Send(msg); err != nil {
+		t.Fatalf("Send() unexpected error = %v", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, c.Close)
+
+	start := time.Now()
+	if _, err := c.Send(msg); err == nil {
+		t.Errorf("Send() error = nil, want one once the client is closed before the chat turn")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() gave up after %s, want right after Close", elapsed)
+	}
+	if got, want := c.Stats(), (SendStats{Sent: 1, Failed: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestThrottledBotClient_Send_Burst(t *testing.T) {
+	msg := bot.NewMessage(123, "some_text")
+	cfg := ThrottleCfg{GlobalRate: 1000, ChatRate: 0.001, ChatBurst: 3, GroupRate: 1000}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bc := mock.NewMockBotClient(ctrl)
+	bc.EXPECT().Send(msg).Return(bot.Message{}, nil).Times(3)
+
+	c := NewThrottledBotClient(bc, cfg)
+	defer c.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Send(msg); err != nil {
+			t.Fatalf("Send() unexpected error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() of a reply of 3 messages took %s, want them sent at once", elapsed)
+	}
+}
+
+func TestThrottledBotClient_AnswerCallback(t *testing.T) {
+	cb := bot.NewCallback("42", "")
+	cfg := ThrottleCfg{GlobalRate: 1000, ChatRate: 1000, GroupRate: 1000, MaxRetries: 2, RetryBackoff: time.Millisecond}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bc := mock.NewMockBotClient(ctrl)
+	gomock.InOrder(
+		bc.EXPECT().AnswerCallback(cb).Return(bot.Error{Message: "Too Many Requests: retry after 0"}),
+		bc.EXPECT().AnswerCallback(cb).Return(bot.Error{Message: "Internal Server Error"}),
+		bc.EXPECT().AnswerCallback(cb).Return(nil),
+	)
+
+	c := NewThrottledBotClient(bc, cfg)
+	if err := c.AnswerCallback(cb); err == nil {
+		t.Errorf("AnswerCallback() error = nil, want one refused by Telegram")
+	}
+	if err := c.AnswerCallback(cb); err != nil {
+		t.Errorf("AnswerCallback() unexpected error = %v", err)
+	}
+	if got, want := c.Stats(), (SendStats{Sent: 1, Retried: 1, Failed: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestThrottledBotClient_sweepIdleChats(t *testing.T) {
+	c := NewThrottledBotClient(nil, ThrottleCfg{GlobalRate: 1000, ChatRate: 1, GroupRate: 1})
+	c.chatLimiter(1)
+	c.chatLimiter(-2)
+	c.chats[-2].lastUsed = time.Now().Add(-2 * idleChatLimiterTTL)
+
+	c.sweepIdleChats(time.Now())
+
+	if _, ok := c.chats[1]; !ok {
+		t.Errorf("sweepIdleChats() dropped the limiter of a chat in use")
+	}
+	if _, ok := c.chats[-2]; ok {
+		t.Errorf("sweepIdleChats() kept the limiter of an idle chat")
+	}
+}
@@ -0,0 +1,108 @@
+// Package tracing sets up OpenTelemetry and starts spans for the bot components.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span exporters to choose from.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	serviceName = "weather-or-not-bot"
+	tracerName  = "weather-or-not-bot"
+)
+
+func init() {
+	pflag.String("trace_exporter", ExporterNone, "Where to export spans: 'none', 'stdout' or 'otlp'")
+	pflag.String("otlp_endpoint", "localhost:4318", "OTLP/HTTP collector address")
+	pflag.Bool("otlp_insecure", true, "Send spans to the collector over plain HTTP")
+	pflag.Float64("trace_sample_ratio", 1, "Share of updates to trace, from 0 to 1")
+}
+
+type TraceCfg struct {
+	Exporter    string  `mapstructure:"trace_exporter"`
+	Endpoint    string  `mapstructure:"otlp_endpoint"`
+	Insecure    bool    `mapstructure:"otlp_insecure"`
+	SampleRatio float64 `mapstructure:"trace_sample_ratio"`
+}
+
+func NewTraceCfgFromEnv() TraceCfg {
+	return TraceCfg{
+		Exporter:    viper.GetString("trace_exporter"),
+		Endpoint:    viper.GetString("otlp_endpoint"),
+		Insecure:    viper.GetBool("otlp_insecure"),
+		SampleRatio: viper.GetFloat64("trace_sample_ratio"),
+	}
+}
+
+// Setup installs the global tracer provider exporting spans as configured.
+// The returned function flushes the remaining spans and must be called before exit.
+func Setup(ctx context.Context, cfg TraceCfg) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, errors.Errorf("unknown trace exporter '%s'", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create span exporter")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named after the traced operation and returns ctx carrying it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span in ctx as failed with err, a nil err is ignored.
+func RecordError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("1. Without tracing set up", func(t *testing.T) {
+		got, span := Start(ctx, "some_operation")
+		defer span.End()
+
+		if got == ctx || !trace.SpanContextFromContext(got).Equal(span.SpanContext()) {
+			t.Errorf("Start() = %v, want the context carrying the span", got)
+		}
+	})
+
+	t.Run("2. With tracing set up", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		parentCtx, parent := Start(ctx, "parent")
+		childCtx, child := Start(parentCtx, "child")
+		RecordError(childCtx, errors.New("some error"))
+		RecordError(parentCtx, nil)
+		child.End()
+		parent.End()
+
+		spans := recorder.Ended()
+		if len(spans) != 2 {
+			t.Fatalf("Start() ended %d spans, want 2", len(spans))
+		}
+		if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+			t.Errorf("Start() child's parent = %v, want %v", spans[0].Parent().SpanID(), spans[1].SpanContext().SpanID())
+		}
+		if spans[0].Status().Code != codes.Error {
+			t.Errorf("RecordError() child's status = %v, want %v", spans[0].Status().Code, codes.Error)
+		}
+		if spans[1].Status().Code != codes.Unset {
+			t.Errorf("RecordError() parent's status = %v, want %v", spans[1].Status().Code, codes.Unset)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+// Modes of update deduplication.
+const (
+	DedupModeOff      = "off"
+	DedupModeMemory   = "memory"
+	DedupModePostgres = "postgres"
+)
+
+func init() {
+	pflag.String("dedup_mode", DedupModeMemory, "Where to remember seen updates: 'off', 'memory' or 'postgres'")
+	pflag.Int("dedup_window", 10000, "Number of latest update IDs remembered to skip redelivered updates")
+}
+
+// Deduplicator claims updates as they arrive, so that the ones Telegram delivers again are skipped,
+// even while the first copy is still queued or being handled. Claims of updates that failed are released,
+// so that they are handled when redelivered or retried.
+type Deduplicator interface {
+	// Seen tells whether the update has been claimed already, and claims it if not.
+	Seen(ctx context.Context, updateID int) (bool, error)
+	// Mark remembers the claimed update as handled.
+	Mark(ctx context.Context, updateID int) error
+	// Release forgets the claim of an update that is not handled, so that it can be claimed again.
+	Release(ctx context.Context, updateID int) error
+}
+
+// MemoryDeduplicator remembers a bounded number of the latest handled update IDs and the ones being handled.
+type MemoryDeduplicator struct {
+	mu       sync.Mutex
+	ids      map[int]struct{}
+	ring     []int
+	next     int
+	inFlight map[int]struct{}
+}
+
+func NewMemoryDeduplicator(window int) *MemoryDeduplicator {
+	if window < 1 {
+		window = 1
+	}
+
+	return &MemoryDeduplicator{
+		ids:      make(map[int]struct{}, window),
+		ring:     make([]int, 0, window),
+		inFlight: map[int]struct{}{},
+	}
+}
+
+func (d *MemoryDeduplicator) Seen(_ context.Context, updateID int) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.ids[updateID]; ok {
+		return true, nil
+	}
+	if _, ok := d.inFlight[updateID]; ok {
+		return true, nil
+	}
+	d.inFlight[updateID] = struct{}{}
+
+	return false, nil
+}
+
+func (d *MemoryDeduplicator) Mark(_ context.Context, updateID int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, updateID)
+	if _, ok := d.ids[updateID]; ok {
+		return nil
+	}
+
+	// Forgetting the oldest ID once the window is full.
+	if len(d.ring) < cap(d.ring) {
+		d.ring = append(d.ring, updateID)
+	} else {
+		delete(d.ids, d.ring[d.next])
+		d.ring[d.next] = updateID
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.ids[updateID] = struct{}{}
+
+	return nil
+}
+
+func (d *MemoryDeduplicator) Release(_ context.Context, updateID int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, updateID)
+	return nil
+}
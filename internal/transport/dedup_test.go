@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryDeduplicator_Seen(t *testing.T) {
+	ctx := context.Background()
+	d := NewMemoryDeduplicator(3)
+
+	steps := []struct {
+		updateID int
+		want     bool
+	}{
+		{1, false},
+		{2, false},
+		{1, true},
+		{3, false},
+		{4, false}, // 1 falls out of the window
+		{2, true},
+		{1, false},
+		{4, true},
+	}
+	for i, step := range steps {
+		got, err := d.Seen(ctx, step.updateID)
+		if err != nil {
+			t.Fatalf("Seen() step %d unexpected error = %v", i, err)
+		}
+		if got != step.want {
+			t.Errorf("Seen() step %d update %d got = %v, want %v", i, step.updateID, got, step.want)
+		}
+
+		// Handling the update.
+		if !got {
+			err = d.Mark(ctx, step.updateID)
+			if err != nil {
+				t.Fatalf("Mark() step %d unexpected error = %v", i, err)
+			}
+		}
+	}
+}
+
+func TestMemoryDeduplicator_SeenWhileInFlight(t *testing.T) {
+	ctx := context.Background()
+	d := NewMemoryDeduplicator(3)
+
+	if seen, _ := d.Seen(ctx, 1); seen {
+		t.Fatalf("Seen() got = true for a new update")
+	}
+	if seen, _ := d.Seen(ctx, 1); !seen {
+		t.Errorf("Seen() got = false while the update is being handled")
+	}
+
+	// Handling fails, so the update is handled when delivered again.
+	_ = d.Release(ctx, 1)
+	if seen, _ := d.Seen(ctx, 1); seen {
+		t.Errorf("Seen() got = true once the claim is released")
+	}
+
+	_ = d.Mark(ctx, 1)
+	if seen, _ := d.Seen(ctx, 1); !seen {
+		t.Errorf("Seen() got = false once the update is marked")
+	}
+}
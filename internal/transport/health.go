@@ -0,0 +1,145 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Statuses reported by health endpoints.
+const (
+	StatusOK          = "ok"
+	StatusFail        = "fail"
+	StatusWarn        = "warn"
+	StatusUnavailable = "unavailable"
+)
+
+func init() {
+	pflag.Duration("readiness_timeout", 3*time.Second, "Time limit for all readiness checks together")
+}
+
+// Check tells whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness endpoints.
+type HealthHandler struct {
+	timeout       time.Duration
+	checks        map[string]Check
+	informational map[string]bool
+}
+
+func NewHealthHandler(timeout time.Duration) *HealthHandler {
+	return &HealthHandler{timeout: timeout, checks: map[string]Check{}, informational: map[string]bool{}}
+}
+
+// AddCheck adds a named readiness check.
+func (h *HealthHandler) AddCheck(name string, check Check) *HealthHandler {
+	h.checks[name] = check
+	return h
+}
+
+// AddInfo adds a named check which is reported along with readiness checks,
+// but does not make the instance unready when it fails.
+func (h *HealthHandler) AddInfo(name string, check Check) *HealthHandler {
+	h.checks[name] = check
+	h.informational[name] = true
+	return h
+}
+
+// CachedCheck runs the check at most once in ttl, telling its last result in between.
+// A result cut short by the caller's ctx is not kept.
+func CachedCheck(check Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+
+		err := check(ctx)
+		if ctx.Err() == nil {
+			checked, last = time.Now(), err
+		}
+
+		return err
+	}
+}
+
+// Liveness tells that the process is up and serving.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(w, http.StatusOK, HealthReport{Status: StatusOK})
+}
+
+// Readiness runs all checks concurrently and tells whether the instance can handle updates.
+// Failed informational checks are reported with the warn status only.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	report := HealthReport{Status: StatusOK, Checks: make(map[string]CheckResult, len(h.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			result := CheckResult{Status: StatusOK}
+			if err := check(ctx); err != nil {
+				result = CheckResult{Status: StatusFail, Error: err.Error()}
+				if h.informational[name] {
+					result.Status = StatusWarn
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status == StatusFail {
+				report.Status = StatusUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeHealthReport(w, code, report)
+}
+
+func writeHealthReport(w http.ResponseWriter, code int, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
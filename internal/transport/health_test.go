@@ -0,0 +1,116 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	broken := func(ctx context.Context) error { return errors.New("some error") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantCode   int
+		wantStatus string
+		wantFailed []string
+	}{
+		{"1. No checks", nil, http.StatusOK, StatusOK, nil},
+		{"2. All checks pass", map[string]Check{"database": ok, "webhook": ok}, http.StatusOK, StatusOK, nil},
+		{"3. One check fails", map[string]Check{"database": ok, "webhook": broken}, http.StatusServiceUnavailable, StatusUnavailable, []string{"webhook"}},
+		{"4. One check times out", map[string]Check{"database": slow, "webhook": ok}, http.StatusServiceUnavailable, StatusUnavailable, []string{"database"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(10 * time.Millisecond)
+			for name, check := range tt.checks {
+				h.AddCheck(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Readiness() code = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var report HealthReport
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("Readiness() cannot decode report: %v", err)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Readiness() status = %s, want %s", report.Status, tt.wantStatus)
+			}
+			for _, name := range tt.wantFailed {
+				if report.Checks[name].Status != StatusFail || report.Checks[name].Error == "" {
+					t.Errorf("Readiness() check '%s' = %+v, want it failed", name, report.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Readiness_Informational(t *testing.T) {
+	h := NewHealthHandler(10*time.Millisecond).
+		AddCheck("database", func(ctx context.Context) error { return nil }).
+		AddInfo("webhook", func(ctx context.Context) error { return errors.New("some error") })
+
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Readiness() code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var report HealthReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Readiness() cannot decode report: %v", err)
+	}
+	if report.Status != StatusOK {
+		t.Errorf("Readiness() status = %s, want %s", report.Status, StatusOK)
+	}
+	if got := report.Checks["webhook"]; got.Status != StatusWarn || got.Error == "" {
+		t.Errorf("Readiness() check 'webhook' = %+v, want it warned", got)
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	calls := 0
+	check := CachedCheck(func(ctx context.Context) error {
+		calls++
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return someErr
+	}, time.Hour)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := check(cancelled); err == nil {
+		t.Errorf("CachedCheck() with cancelled ctx error = nil, want one")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := check(ctx); err != someErr {
+			t.Errorf("CachedCheck() error = %v, want %v", err, someErr)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("CachedCheck() ran the check %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// InboxCfg configures the durable inbox of incoming updates.
+type InboxCfg struct {
+	On            bool          `mapstructure:"inbox_on"`
+	MaxAttempts   int           `mapstructure:"inbox_max_attempts"`
+	RetryInterval time.Duration `mapstructure:"inbox_retry_interval"`
+	RetryBatch    int           `mapstructure:"inbox_retry_batch"`
+	Retention     time.Duration `mapstructure:"inbox_retention"`
+	Lease         time.Duration `mapstructure:"inbox_lease"`
+}
+
+func init() {
+	pflag.Bool("inbox_on", false, "Store incoming updates in the database before handling them")
+	pflag.Int("inbox_max_attempts", 5, "Attempts to handle an update before it is dead-lettered")
+	pflag.Duration("inbox_retry_interval", time.Minute, "How often unfinished updates are handled again")
+	pflag.Int("inbox_retry_batch", 100, "Number of unfinished updates handled again at once")
+	pflag.Duration("inbox_retention", 72*time.Hour, "How long handled updates are kept in the inbox")
+	pflag.Duration("inbox_lease", 5*time.Minute, "How long an update stays with the replica that took it before others may handle it, above update_timeout")
+}
+
+// NewInboxCfgFromEnv reads the inbox configuration.
+func NewInboxCfgFromEnv() InboxCfg {
+	var cfg InboxCfg
+
+	err := viper.Unmarshal(&cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot get inbox cfg from envs")
+	}
+
+	return cfg
+}
+
+type Inbox interface {
+	Save(ctx context.Context, upd bot.Update) error
+	// Take marks the update as being handled and tells whether it is to be handled, i.e. no one else has it.
+	Take(ctx context.Context, updateID int) (bool, error)
+	MarkDone(ctx context.Context, updateID int) error
+	// MarkFailed records a failed attempt and tells whether the update has been dead-lettered.
+	MarkFailed(ctx context.Context, updateID int, cause error) (bool, error)
+	// ClaimUnfinished returns updates failed longer than retryAfter ago and the unfinished ones no one has anymore.
+	ClaimUnfinished(ctx context.Context, retryAfter time.Duration, limit int) ([]bot.Update, error)
+	DeleteDone(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// NewInboxWebhook returns a webhook handler that acknowledges an update only once it is stored in the inbox,
+// and the channel the stored updates are passed to. Once ctx is done, stored updates are acknowledged
+// without being passed on.
+func NewInboxWebhook(ctx context.Context, inbox Inbox, buffer int) (http.Handler, bot.UpdatesChannel) {
+	log := ctxlogrus.Extract(ctx)
+	ch := make(chan bot.Update, buffer)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.WithError(err).Warn("cannot read webhook request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var update bot.Update
+		err = json.Unmarshal(body, &update)
+		if err != nil {
+			log.WithError(err).Warn("cannot unmarshal update")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Telegram redelivers the update unless it gets 200.
+		err = inbox.Save(r.Context(), update)
+		if err != nil {
+			log.WithError(err).WithField("update_id", update.UpdateID).Error("cannot store update in the inbox")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The update is stored already, so once nobody takes it the retries of the inbox will.
+		select {
+		case ch <- update:
+		case <-ctx.Done():
+			log.WithField("update_id", update.UpdateID).Info("Stopping receiving updates, leaving the update to the inbox")
+		case <-r.Context().Done():
+			log.WithField("update_id", update.UpdateID).Warn("Webhook request is gone, leaving the update to the inbox")
+		}
+	})
+
+	return handler, ch
+}
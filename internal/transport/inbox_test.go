@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// fakeInbox keeps the updates and their statuses in memory.
+type fakeInbox struct {
+	mu          sync.Mutex
+	saved       []int
+	statuses    map[int]string
+	attempts    map[int]int
+	maxAttempts int
+	// unfinished are the updates ClaimUnfinished returns once.
+	unfinished []bot.Update
+}
+
+func newFakeInbox() *fakeInbox {
+	return &fakeInbox{statuses: map[int]string{}, attempts: map[int]int{}, maxAttempts: 1}
+}
+
+func (i *fakeInbox) Save(_ context.Context, upd bot.Update) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.saved = append(i.saved, upd.UpdateID)
+	if _, ok := i.statuses[upd.UpdateID]; !ok {
+		i.statuses[upd.UpdateID] = "pending"
+	}
+
+	return nil
+}
+
+func (i *fakeInbox) Take(_ context.Context, updateID int) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	status, ok := i.statuses[updateID]
+	if ok && status != "pending" {
+		return false, nil
+	}
+	i.statuses[updateID] = "processing"
+
+	return true, nil
+}
+
+func (i *fakeInbox) MarkDone(_ context.Context, updateID int) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.statuses[updateID] = "done"
+	return nil
+}
+
+func (i *fakeInbox) MarkFailed(_ context.Context, updateID int, _ error) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.attempts[updateID]++
+	i.statuses[updateID] = "failed"
+	if i.attempts[updateID] >= i.maxAttempts {
+		i.statuses[updateID] = "dead"
+	}
+
+	return i.statuses[updateID] == "dead", nil
+}
+
+func (i *fakeInbox) ClaimUnfinished(context.Context, time.Duration, int) ([]bot.Update, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	claimed := i.unfinished
+	i.unfinished = nil
+	for _, upd := range claimed {
+		i.statuses[upd.UpdateID] = "pending"
+	}
+
+	return claimed, nil
+}
+
+func (i *fakeInbox) DeleteDone(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (i *fakeInbox) status(updateID int) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.statuses[updateID]
+}
+
+func TestNewInboxWebhook(t *testing.T) {
+	const body = `{"update_id": 7, "message": {"message_id": 1, "text": "hi", "chat": {"id": 1}}}`
+
+	tests := []struct {
+		name       string
+		cancelled  bool
+		wantPassed bool
+	}{
+		{"1. Stored update is passed on", false, true},
+		{"2. Stored update is acknowledged once receiving is stopped", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			inbox := newFakeInbox()
+			// No buffer, so nobody takes the update unless the test does.
+			handler, updates := NewInboxWebhook(ctx, inbox, 0)
+
+			passed := make(chan bot.Update, 1)
+			if !tt.cancelled {
+				go func() { passed <- <-updates }()
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("NewInboxWebhook() status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if len(inbox.saved) != 1 || inbox.saved[0] != 7 {
+				t.Errorf("NewInboxWebhook() saved = %v, want [7]", inbox.saved)
+			}
+			if tt.wantPassed {
+				if upd := <-passed; upd.UpdateID != 7 {
+					t.Errorf("NewInboxWebhook() passed update %d, want 7", upd.UpdateID)
+				}
+			}
+		})
+	}
+}
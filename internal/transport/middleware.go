@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// Names of the built-in middlewares.
+const (
+	MiddlewareLogging  = "logging"
+	MiddlewareRecovery = "recovery"
+	MiddlewareTiming   = "timing"
+)
+
+const apologyText = "Sorry, something went wrong on my side. Please try again."
+
+func init() {
+	pflag.StringSlice("middlewares", []string{MiddlewareLogging, MiddlewareRecovery, MiddlewareTiming},
+		"Middlewares wrapping message handling, outermost first")
+}
+
+// MessageServiceFunc lets an ordinary function act as a MessageService.
+type MessageServiceFunc func(ctx context.Context, upd *bot.Update) error
+
+func (f MessageServiceFunc) HandleNewMessage(ctx context.Context, upd *bot.Update) error {
+	return f(ctx, upd)
+}
+
+// Middleware wraps a MessageService with extra behaviour.
+type Middleware func(next MessageService) MessageService
+
+// Chain wraps svc with middlewares, the first of them being the outermost.
+func Chain(svc MessageService, middlewares ...Middleware) MessageService {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		svc = middlewares[i](svc)
+	}
+
+	return svc
+}
+
+type Replier interface {
+	Send(msg bot.MessageConfig) (bot.Message, error)
+}
+
+// MiddlewareRegistry keeps middlewares by name, so that they can be enabled from config.
+type MiddlewareRegistry struct {
+	middlewares map[string]Middleware
+}
+
+// NewMiddlewareRegistry creates a registry holding the built-in middlewares.
+func NewMiddlewareRegistry(replier Replier) *MiddlewareRegistry {
+	r := &MiddlewareRegistry{middlewares: map[string]Middleware{}}
+	r.Register(MiddlewareLogging, Logging())
+	r.Register(MiddlewareRecovery, Recovery(replier))
+	r.Register(MiddlewareTiming, Timing())
+
+	return r
+}
+
+// Register adds a custom middleware or replaces the one with the same name.
+func (r *MiddlewareRegistry) Register(name string, mw Middleware) {
+	r.middlewares[name] = mw
+}
+
+// Build returns the middlewares with given names in the same order.
+func (r *MiddlewareRegistry) Build(names []string) ([]Middleware, error) {
+	middlewares := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		mw, ok := r.middlewares[strings.TrimSpace(name)]
+		if !ok {
+			return nil, errors.Errorf("unknown middleware '%s'", name)
+		}
+		middlewares = append(middlewares, mw)
+	}
+
+	return middlewares, nil
+}
+
+// Logging puts a logger carrying update_id and chat_id into the context.
+func Logging() Middleware {
+	return func(next MessageService) MessageService {
+		return MessageServiceFunc(func(ctx context.Context, upd *bot.Update) error {
+			log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+				"update_id": upd.UpdateID,
+				"chat_id":   chatIDOf(*upd),
+			})
+
+			return next.HandleNewMessage(ctxlogrus.ToContext(ctx, log), upd)
+		})
+	}
+}
+
+// Recovery turns a panic into an error and apologises to the user.
+func Recovery(replier Replier) Middleware {
+	return func(next MessageService) MessageService {
+		return MessageServiceFunc(func(ctx context.Context, upd *bot.Update) (err error) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				metrics.ErrorsTotal.WithLabelValues(metrics.ErrorPanic).Inc()
+
+				log := ctxlogrus.Extract(ctx)
+				log.WithField("stack", string(debug.Stack())).Errorf("Recovered from panic: %v", p)
+				err = errors.Errorf("panic on handling update %d: %v", upd.UpdateID, p)
+
+				if upd.Message == nil || upd.Message.Chat == nil {
+					return
+				}
+
+				_, sendErr := replier.Send(bot.NewMessage(upd.Message.Chat.ID, apologyText))
+				if sendErr != nil {
+					log.WithError(sendErr).Warn("cannot send an apology")
+				}
+			}()
+
+			return next.HandleNewMessage(ctx, upd)
+		})
+	}
+}
+
+// Timing logs how long handling took.
+func Timing() Middleware {
+	return func(next MessageService) MessageService {
+		return MessageServiceFunc(func(ctx context.Context, upd *bot.Update) error {
+			start := time.Now()
+			err := next.HandleNewMessage(ctx, upd)
+
+			ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+				"duration": time.Since(start),
+			}).Info("Handled a new message")
+
+			return err
+		})
+	}
+}
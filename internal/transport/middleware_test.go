@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+type recordingReplier struct {
+	sent []bot.MessageConfig
+}
+
+func (r *recordingReplier) Send(msg bot.MessageConfig) (bot.Message, error) {
+	r.sent = append(r.sent, msg)
+	return bot.Message{}, nil
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	upd := &bot.Update{UpdateID: 7, Message: &bot.Message{Chat: &bot.Chat{ID: 123}, From: &bot.User{ID: 456}}}
+
+	var order []string
+	tracing := func(name string) Middleware {
+		return func(next MessageService) MessageService {
+			return MessageServiceFunc(func(ctx context.Context, upd *bot.Update) error {
+				order = append(order, name)
+				return next.HandleNewMessage(ctx, upd)
+			})
+		}
+	}
+
+	replier := &recordingReplier{}
+	registry := NewMiddlewareRegistry(replier)
+	registry.Register("first", tracing("first"))
+	registry.Register("second", tracing("second"))
+
+	middlewares, err := registry.Build([]string{"first", MiddlewareLogging, MiddlewareRecovery, "second", MiddlewareTiming})
+	if err != nil {
+		t.Fatalf("Build() unexpected error = %v", err)
+	}
+
+	panicking := MessageServiceFunc(func(ctx context.Context, upd *bot.Update) error {
+		var msg *bot.Message
+		_ = msg.Text
+
+		return nil
+	})
+
+	err = Chain(panicking, middlewares...).HandleNewMessage(ctx, upd)
+	if err == nil {
+		t.Error("HandleNewMessage() expected an error after panic")
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("HandleNewMessage() middlewares order = %v", order)
+	}
+	if len(replier.sent) != 1 || replier.sent[0].ChatID != 123 || replier.sent[0].Text != apologyText {
+		t.Errorf("HandleNewMessage() sent = %+v, want an apology", replier.sent)
+	}
+
+	if _, err = registry.Build([]string{"unknown"}); err == nil {
+		t.Error("Build() expected an error on unknown middleware")
+	}
+}
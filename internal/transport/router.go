@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"weather-or-not-bot/internal/metrics"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// Kinds of updates the router tells apart.
+const (
+	KindMessage            = "message"
+	KindEditedMessage      = "edited_message"
+	KindChannelPost        = "channel_post"
+	KindEditedChannelPost  = "edited_channel_post"
+	KindCallbackQuery      = "callback_query"
+	KindInlineQuery        = "inline_query"
+	KindChosenInlineResult = "chosen_inline_result"
+	KindChatMember         = "chat_member"
+	KindShippingQuery      = "shipping_query"
+	KindPreCheckoutQuery   = "pre_checkout_query"
+	KindUnknown            = "unknown"
+)
+
+type EditedMessageHandler interface {
+	HandleEditedMessage(ctx context.Context, upd *bot.Update) error
+}
+
+type CallbackQueryHandler interface {
+	HandleCallbackQuery(ctx context.Context, upd *bot.Update) error
+}
+
+// CallbackQueryHandlerFunc lets an ordinary function act as a CallbackQueryHandler,
+// e.g. a callback handler wrapped with middlewares.
+type CallbackQueryHandlerFunc func(ctx context.Context, upd *bot.Update) error
+
+func (f CallbackQueryHandlerFunc) HandleCallbackQuery(ctx context.Context, upd *bot.Update) error {
+	return f(ctx, upd)
+}
+
+type InlineQueryHandler interface {
+	HandleInlineQuery(ctx context.Context, upd *bot.Update) error
+}
+
+// InlineQueryHandlerFunc lets an ordinary function act as an InlineQueryHandler.
+type InlineQueryHandlerFunc func(ctx context.Context, upd *bot.Update) error
+
+func (f InlineQueryHandlerFunc) HandleInlineQuery(ctx context.Context, upd *bot.Update) error {
+	return f(ctx, upd)
+}
+
+type ChatMemberHandler interface {
+	HandleChatMember(ctx context.Context, upd *bot.Update) error
+}
+
+// Router sends every kind of update to its own handler.
+// Kinds without a handler are counted and ignored.
+type Router struct {
+	messages  MessageService
+	edited    EditedMessageHandler
+	callbacks CallbackQueryHandler
+	inline    InlineQueryHandler
+	members   ChatMemberHandler
+
+	mu          sync.Mutex
+	unsupported map[string]int
+}
+
+func NewRouter(messages MessageService) *Router {
+	return &Router{messages: messages, unsupported: map[string]int{}}
+}
+
+func (r *Router) WithEditedMessageHandler(h EditedMessageHandler) *Router {
+	r.edited = h
+	return r
+}
+
+func (r *Router) WithCallbackQueryHandler(h CallbackQueryHandler) *Router {
+	r.callbacks = h
+	return r
+}
+
+func (r *Router) WithInlineQueryHandler(h InlineQueryHandler) *Router {
+	r.inline = h
+	return r
+}
+
+func (r *Router) WithChatMemberHandler(h ChatMemberHandler) *Router {
+	r.members = h
+	return r
+}
+
+// Route passes the update to the handler of its kind.
+func (r *Router) Route(ctx context.Context, upd *bot.Update) error {
+	kind := UpdateKind(upd)
+	metrics.UpdatesReceived.WithLabelValues(kind).Inc()
+
+	switch {
+	case kind == KindMessage && r.messages != nil:
+		return r.messages.HandleNewMessage(ctx, upd)
+	case kind == KindEditedMessage && r.edited != nil:
+		return r.edited.HandleEditedMessage(ctx, upd)
+	case kind == KindCallbackQuery && r.callbacks != nil:
+		return r.callbacks.HandleCallbackQuery(ctx, upd)
+	case kind == KindInlineQuery && r.inline != nil:
+		return r.inline.HandleInlineQuery(ctx, upd)
+	case kind == KindChatMember && r.members != nil:
+		return r.members.HandleChatMember(ctx, upd)
+	}
+
+	r.mu.Lock()
+	r.unsupported[kind]++
+	count := r.unsupported[kind]
+	r.mu.Unlock()
+
+	ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": upd.UpdateID,
+		"kind":      kind,
+		"count":     count,
+	}).Debug("Ignoring unsupported update")
+
+	return nil
+}
+
+// Unsupported returns how many updates of each kind were ignored so far.
+func (r *Router) Unsupported() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int, len(r.unsupported))
+	for kind, count := range r.unsupported {
+		counts[kind] = count
+	}
+
+	return counts
+}
+
+// UpdateKind tells what kind of update it is. Messages without a chat or a sender are of unknown kind.
+func UpdateKind(upd *bot.Update) string {
+	switch {
+	case upd.Message != nil && (upd.Message.Chat == nil || upd.Message.From == nil):
+		return KindUnknown
+	case upd.Message != nil && (upd.Message.NewChatMembers != nil || upd.Message.LeftChatMember != nil):
+		return KindChatMember
+	case upd.Message != nil:
+		return KindMessage
+	case upd.EditedMessage != nil:
+		return KindEditedMessage
+	case upd.ChannelPost != nil:
+		return KindChannelPost
+	case upd.EditedChannelPost != nil:
+		return KindEditedChannelPost
+	case upd.CallbackQuery != nil:
+		return KindCallbackQuery
+	case upd.InlineQuery != nil:
+		return KindInlineQuery
+	case upd.ChosenInlineResult != nil:
+		return KindChosenInlineResult
+	case upd.ShippingQuery != nil:
+		return KindShippingQuery
+	case upd.PreCheckoutQuery != nil:
+		return KindPreCheckoutQuery
+	default:
+		return KindUnknown
+	}
+}
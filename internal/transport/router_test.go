@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+type countingService struct {
+	calls int
+}
+
+func (s *countingService) HandleNewMessage(ctx context.Context, upd *bot.Update) error {
+	s.calls++
+	return nil
+}
+
+func TestRouter_Route(t *testing.T) {
+	ctx := context.Background()
+	chat := &bot.Chat{ID: 123}
+	user := &bot.User{ID: 456}
+
+	tests := []struct {
+		name      string
+		upd       *bot.Update
+		wantCalls int
+		wantKind  string
+	}{
+		{"1. Message goes to message service", &bot.Update{Message: &bot.Message{Chat: chat, From: user}}, 1, ""},
+		{"2. Message without sender is ignored", &bot.Update{Message: &bot.Message{Chat: chat}}, 0, KindUnknown},
+		{"3. Chat member change without handler is ignored", &bot.Update{Message: &bot.Message{Chat: chat, From: user, LeftChatMember: user}}, 0, KindChatMember},
+		{"4. Callback query without handler is ignored", &bot.Update{CallbackQuery: &bot.CallbackQuery{From: user}}, 0, KindCallbackQuery},
+		{"5. Inline query without handler is ignored", &bot.Update{InlineQuery: &bot.InlineQuery{From: user}}, 0, KindInlineQuery},
+		{"6. Channel post is ignored", &bot.Update{ChannelPost: &bot.Message{Chat: chat}}, 0, KindChannelPost},
+		{"7. Empty update is ignored", &bot.Update{}, 0, KindUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &countingService{}
+			r := NewRouter(svc)
+
+			if err := r.Route(ctx, tt.upd); err != nil {
+				t.Errorf("Route() unexpected error = %v", err)
+			}
+			if svc.calls != tt.wantCalls {
+				t.Errorf("Route() message service calls = %d, want %d", svc.calls, tt.wantCalls)
+			}
+			if tt.wantKind != "" && r.Unsupported()[tt.wantKind] != 1 {
+				t.Errorf("Route() unsupported = %v, want one '%s'", r.Unsupported(), tt.wantKind)
+			}
+		})
+	}
+}
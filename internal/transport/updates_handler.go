@@ -2,8 +2,16 @@ package transport
 
 import (
 	"context"
-	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"sync"
+	"sync/atomic"
+	"time"
+	"weather-or-not-bot/internal/tracing"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 	bot "gopkg.in/telegram-bot-api.v4"
 )
 
@@ -11,24 +19,453 @@ type MessageService interface {
 	HandleNewMessage(ctx context.Context, msg *bot.Update) error
 }
 
+type UpdateRouter interface {
+	Route(ctx context.Context, upd *bot.Update) error
+}
+
+// PoolCfg configures concurrent processing of updates.
+type PoolCfg struct {
+	Workers       int           `mapstructure:"workers"`
+	QueueDepth    int           `mapstructure:"queue_depth"`
+	UpdateTimeout time.Duration `mapstructure:"update_timeout"`
+	// ShutdownTimeout limits how long in-flight updates are waited for once handling is stopped.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// Summary tells what happened to the updates received by UpdatesHandler.
+type Summary struct {
+	Received  int64
+	Handled   int64
+	Failed    int64
+	Abandoned int64
+	Duplicate int64
+	Retried   int64
+	// Skipped are the updates found taken or finished elsewhere by the time a worker got to them.
+	Skipped int64
+}
+
+func init() {
+	pflag.Int("workers", 8, "Number of workers handling updates concurrently")
+	pflag.Int("queue_depth", 64, "Number of updates waiting in each worker's queue")
+	pflag.Duration("update_timeout", 30*time.Second, "Time limit for handling a single update, 0 for none")
+	pflag.Duration("shutdown_timeout", 20*time.Second, "Time limit for finishing in-flight updates on shutdown, 0 for none")
+}
+
+// NewPoolCfgFromEnv reads the worker pool configuration.
+func NewPoolCfgFromEnv() PoolCfg {
+	var cfg PoolCfg
+
+	err := viper.Unmarshal(&cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot get worker pool cfg from envs")
+	}
+
+	return cfg
+}
+
 type UpdatesHandler struct {
-	svc MessageService
-	upd bot.UpdatesChannel
+	router UpdateRouter
+	upd    bot.UpdatesChannel
+	cfg    PoolCfg
+	dedup  Deduplicator
+	inbox  Inbox
+	inCfg  InboxCfg
+	// stored tells that the source of updates has stored them in the inbox already.
+	stored bool
+
+	received  int64
+	handled   int64
+	failed    int64
+	duplicate int64
+	retried   int64
+	skipped   int64
+}
+
+func NewUpdatesHandler(router UpdateRouter, upd bot.UpdatesChannel, cfg PoolCfg) *UpdatesHandler {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	if cfg.QueueDepth < 0 {
+		cfg.QueueDepth = 0
+	}
+
+	return &UpdatesHandler{router: router, upd: upd, cfg: cfg}
+}
+
+// WithDeduplicator makes the handler skip updates that have already been handled.
+func (h *UpdatesHandler) WithDeduplicator(dedup Deduplicator) *UpdatesHandler {
+	h.dedup = dedup
+	return h
+}
+
+// WithInbox makes the handler store updates before handling them, record the outcome,
+// and periodically handle again the failed and the unfinished ones.
+// Updates are not stored again when the source stores them itself, as the inbox webhook does.
+func (h *UpdatesHandler) WithInbox(inbox Inbox, cfg InboxCfg, storedBySource bool) *UpdatesHandler {
+	h.inbox = inbox
+	h.inCfg = cfg
+	h.stored = storedBySource
+	return h
+}
+
+// HandleUpdates reads updates from bot's UpdateChannel and handles them concurrently.
+// Updates from the same chat always go to the same worker, so they are handled in order.
+// Once ctx is done, it dispatches the updates buffered in the channel and finishes the updates
+// already received, both within the shutdown timeout, and returns a summary. The source of updates
+// should be stopped before ctx is done, so that nothing is put into the channel after it is drained.
+func (h *UpdatesHandler) HandleUpdates(ctx context.Context) Summary {
+	log := ctxlogrus.Extract(ctx)
+	log.WithFields(logrus.Fields{
+		"workers":          h.cfg.Workers,
+		"queue_depth":      h.cfg.QueueDepth,
+		"update_timeout":   h.cfg.UpdateTimeout,
+		"shutdown_timeout": h.cfg.ShutdownTimeout,
+	}).Info("Starting handling messages from users")
+
+	// In-flight updates must survive cancellation of ctx, so workers get a context of their own.
+	workCtx, abort := context.WithCancel(ctxlogrus.ToContext(context.Background(), log))
+	defer abort()
+
+	var wg sync.WaitGroup
+	queues := make([]chan bot.Update, h.cfg.Workers)
+	for i := range queues {
+		queues[i] = make(chan bot.Update, h.cfg.QueueDepth)
+
+		wg.Add(1)
+		go h.work(workCtx, queues[i], &wg)
+	}
+
+	waiting := h.receive(ctx, queues)
+
+	// The background context is never done, so without the timeout updates are waited for as long as it takes.
+	stopCtx := context.Background()
+	if h.cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		stopCtx, cancel = context.WithTimeout(stopCtx, h.cfg.ShutdownTimeout)
+		defer cancel()
+	}
+	deadline := stopCtx.Done()
+
+	h.drain(log, queues, deadline, waiting)
+
+	for i := range queues {
+		close(queues[i])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("Finished handling all received updates")
+	case <-deadline:
+		log.Warn("Shutdown timeout exceeded, abandoning in-flight updates")
+		abort()
+	}
+
+	return h.summary()
+}
+
+// receive dispatches updates to worker queues until the updates channel is closed or ctx is done.
+// It returns the update still waiting for room in its queue when ctx got done, if any.
+func (h *UpdatesHandler) receive(ctx context.Context, queues []chan bot.Update) *bot.Update {
+	log := ctxlogrus.Extract(ctx)
+
+	// A nil channel never fires, so there are no retries without the inbox.
+	var retries <-chan time.Time
+	if h.inbox != nil && h.inCfg.RetryInterval > 0 {
+		ticker := time.NewTicker(h.inCfg.RetryInterval)
+		defer ticker.Stop()
+		retries = ticker.C
+	}
+
+	for {
+		select {
+		case <-retries:
+			h.retry(ctx, queues)
+		case update, ok := <-h.upd:
+			if !ok {
+				log.Info("Updates channel is closed")
+				return nil
+			}
+			if !h.dispatch(ctx, queues, update, ctx.Done()) {
+				log.Info("Stopping receiving updates")
+				return &update
+			}
+		case <-ctx.Done():
+			log.Info("Stopping receiving updates")
+			return nil
+		}
+	}
+}
+
+// drain queues the waiting update, if any, and dispatches the updates buffered in the channel until the deadline.
+// They have already been acknowledged, so they are dispatched regardless of ctx.
+func (h *UpdatesHandler) drain(log *logrus.Entry, queues []chan bot.Update, deadline <-chan struct{}, waiting *bot.Update) {
+	ctx := ctxlogrus.ToContext(context.Background(), log)
+	if waiting != nil && !h.enqueue(ctx, queues, *waiting, deadline) {
+		log.Warn("Shutdown timeout exceeded, abandoning buffered updates")
+		return
+	}
+
+	for {
+		select {
+		case update, ok := <-h.upd:
+			if !ok {
+				return
+			}
+			if !h.dispatch(ctx, queues, update, deadline) {
+				log.Warn("Shutdown timeout exceeded, abandoning buffered updates")
+				return
+			}
+		case <-deadline:
+			log.Warn("Shutdown timeout exceeded, abandoning buffered updates")
+			return
+		default:
+			return
+		}
+	}
+}
+
+// dispatch passes the update to its worker and tells whether it made it before the deadline.
+func (h *UpdatesHandler) dispatch(ctx context.Context, queues []chan bot.Update, update bot.Update, deadline <-chan struct{}) bool {
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": update.UpdateID,
+	})
+
+	// Claiming the update right away, so that a copy redelivered while this one waits or runs is skipped.
+	if h.dedup != nil {
+		seen, err := h.dedup.Seen(ctx, update.UpdateID)
+		if err != nil {
+			// Handling an update twice is better than not handling it at all.
+			log.WithError(err).Warn("cannot check whether the update is a duplicate")
+		}
+
+		if seen {
+			atomic.AddInt64(&h.duplicate, 1)
+			log.Info("Skipping a redelivered update")
+			return true
+		}
+	}
+
+	if h.inbox != nil && !h.stored {
+		err := h.inbox.Save(ctx, update)
+		if err != nil {
+			log.WithError(err).Warn("cannot store update in the inbox")
+		}
+	}
+
+	atomic.AddInt64(&h.received, 1)
+	if !h.enqueue(ctx, queues, update, deadline) {
+		h.release(ctx, update.UpdateID)
+		return false
+	}
+
+	return true
 }
 
-func NewUpdatesHandler(svc MessageService, upd bot.UpdatesChannel) *UpdatesHandler {
-	return &UpdatesHandler{svc: svc, upd: upd}
+// enqueue puts the update into the queue of its worker, waiting for room until the deadline, if any.
+func (h *UpdatesHandler) enqueue(ctx context.Context, queues []chan bot.Update, update bot.Update, deadline <-chan struct{}) bool {
+	i := workerIndex(update, len(queues))
+
+	select {
+	case queues[i] <- update:
+		return true
+	default:
+	}
+
+	ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"worker":    i,
+		"update_id": update.UpdateID,
+	}).Warn("Worker queue is full, waiting")
+
+	select {
+	case queues[i] <- update:
+		return true
+	case <-deadline:
+		return false
+	}
 }
 
-// HandleUpdates reads updates from bot's UpdateChannel and handles them.
-func (h *UpdatesHandler) HandleUpdates(ctx context.Context) {
+// retry passes failed and unfinished updates from the inbox to workers again,
+// bypassing deduplication, and forgets the old handled ones.
+func (h *UpdatesHandler) retry(ctx context.Context, queues []chan bot.Update) {
 	log := ctxlogrus.Extract(ctx)
-	log.Info("Starting handling messages from users")
 
-	for update := range h.upd {
-		err := h.svc.HandleNewMessage(ctx, &update)
+	updates, err := h.inbox.ClaimUnfinished(ctx, h.inCfg.RetryInterval, h.inCfg.RetryBatch)
+	if err != nil {
+		log.WithError(err).Warn("cannot get unfinished updates from the inbox")
+	}
+
+	if len(updates) > 0 {
+		log.Infof("Handling %d unfinished updates again", len(updates))
+	}
+
+	for _, update := range updates {
+		atomic.AddInt64(&h.received, 1)
+		atomic.AddInt64(&h.retried, 1)
+		// Updates left behind on shutdown stay in the inbox for the next claim.
+		if !h.enqueue(ctx, queues, update, ctx.Done()) {
+			return
+		}
+	}
+
+	deleted, err := h.inbox.DeleteDone(ctx, h.inCfg.Retention)
+	if err != nil {
+		log.WithError(err).Warn("cannot delete handled updates from the inbox")
+	}
+
+	if deleted > 0 {
+		log.Debugf("Deleted %d handled updates from the inbox", deleted)
+	}
+}
+
+// work handles updates from a single queue one by one.
+func (h *UpdatesHandler) work(ctx context.Context, queue <-chan bot.Update, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for update := range queue {
+		if ctx.Err() != nil {
+			continue
+		}
+		h.handle(ctx, update)
+	}
+}
+
+func (h *UpdatesHandler) handle(ctx context.Context, update bot.Update) {
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"update_id": update.UpdateID,
+	})
+
+	if h.inbox != nil {
+		taken, err := h.inbox.Take(ctx, update.UpdateID)
 		if err != nil {
-			log.WithError(err).Warn("cannot handle user message")
+			// Handling an update twice is better than not handling it at all.
+			log.WithError(err).Warn("cannot take update from the inbox, handling it anyway")
+		} else if !taken {
+			atomic.AddInt64(&h.skipped, 1)
+			log.Info("Skipping an update taken or finished elsewhere")
+			h.markHandled(ctx, update.UpdateID)
+			return
 		}
 	}
+
+	ctx, span := tracing.Start(ctx, "UpdatesHandler.handle",
+		attribute.Int("update_id", update.UpdateID),
+		attribute.String("update_kind", UpdateKind(&update)),
+		attribute.Int64("chat_id", chatIDOf(update)),
+	)
+	defer span.End()
+
+	err := h.route(ctx, update)
+	tracing.RecordError(ctx, err)
+	if err != nil {
+		atomic.AddInt64(&h.failed, 1)
+		log.WithError(err).Warn("cannot handle update")
+		h.release(ctx, update.UpdateID)
+	} else {
+		atomic.AddInt64(&h.handled, 1)
+		h.markHandled(ctx, update.UpdateID)
+	}
+
+	if h.inbox == nil {
+		return
+	}
+
+	if err == nil {
+		err = h.inbox.MarkDone(ctx, update.UpdateID)
+		if err != nil {
+			log.WithError(err).Warn("cannot mark update as done in the inbox")
+		}
+		return
+	}
+
+	dead, err := h.inbox.MarkFailed(ctx, update.UpdateID, err)
+	if err != nil {
+		log.WithError(err).Warn("cannot mark update as failed in the inbox")
+	}
+
+	if dead {
+		log.Error("Update is dead-lettered after running out of attempts")
+	}
+}
+
+// markHandled remembers the update, so that it is skipped when Telegram delivers it again.
+func (h *UpdatesHandler) markHandled(ctx context.Context, updateID int) {
+	if h.dedup == nil {
+		return
+	}
+
+	err := h.dedup.Mark(ctx, updateID)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).Warn("cannot remember the update as handled")
+	}
+}
+
+// release forgets the claim of an update that is not handled, so that it is handled when delivered again.
+func (h *UpdatesHandler) release(ctx context.Context, updateID int) {
+	if h.dedup == nil {
+		return
+	}
+
+	err := h.dedup.Release(ctx, updateID)
+	if err != nil {
+		ctxlogrus.Extract(ctx).WithError(err).Warn("cannot release the claim of the update")
+	}
+}
+
+// route passes the update to the router within the update timeout.
+func (h *UpdatesHandler) route(ctx context.Context, update bot.Update) error {
+	if h.cfg.UpdateTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.UpdateTimeout)
+		defer cancel()
+	}
+
+	return h.router.Route(ctx, &update)
+}
+
+func (h *UpdatesHandler) summary() Summary {
+	s := Summary{
+		Received:  atomic.LoadInt64(&h.received),
+		Handled:   atomic.LoadInt64(&h.handled),
+		Failed:    atomic.LoadInt64(&h.failed),
+		Duplicate: atomic.LoadInt64(&h.duplicate),
+		Retried:   atomic.LoadInt64(&h.retried),
+		Skipped:   atomic.LoadInt64(&h.skipped),
+	}
+	s.Abandoned = s.Received - s.Handled - s.Failed - s.Skipped
+
+	return s
+}
+
+// workerIndex picks a worker for the update based on the chat it belongs to.
+func workerIndex(update bot.Update, workers int) int {
+	return int(uint64(chatIDOf(update)) % uint64(workers))
+}
+
+// chatIDOf returns the ID of the chat the update belongs to, or of the user for chat-less updates.
+func chatIDOf(update bot.Update) int64 {
+	for _, msg := range []*bot.Message{update.Message, update.EditedMessage, update.ChannelPost, update.EditedChannelPost} {
+		if msg != nil && msg.Chat != nil {
+			return msg.Chat.ID
+		}
+	}
+
+	switch {
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return int64(update.CallbackQuery.From.ID)
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return int64(update.InlineQuery.From.ID)
+	case update.ChosenInlineResult != nil && update.ChosenInlineResult.From != nil:
+		return int64(update.ChosenInlineResult.From.ID)
+	default:
+		return 0
+	}
 }
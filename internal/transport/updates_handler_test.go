@@ -0,0 +1,313 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+// recordingService remembers the order of handled updates per chat.
+type recordingService struct {
+	mu      sync.Mutex
+	handled map[int64][]int
+}
+
+func (s *recordingService) HandleNewMessage(ctx context.Context, upd *bot.Update) error {
+	// Slowing down the first chat to let the others overtake it.
+	if upd.Message.Chat.ID == 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handled[upd.Message.Chat.ID] = append(s.handled[upd.Message.Chat.ID], upd.UpdateID)
+
+	return nil
+}
+
+func TestUpdatesHandler_HandleUpdates(t *testing.T) {
+	const (
+		chats          = 5
+		updatesPerChat = 20
+	)
+
+	upd := make(chan bot.Update, chats*updatesPerChat)
+	for i := 0; i < updatesPerChat; i++ {
+		for chatID := int64(1); chatID <= chats; chatID++ {
+			upd <- bot.Update{
+				UpdateID: i,
+				Message:  &bot.Message{Chat: &bot.Chat{ID: chatID}, From: &bot.User{ID: int(chatID)}},
+			}
+		}
+	}
+	close(upd)
+
+	svc := &recordingService{handled: map[int64][]int{}}
+	h := NewUpdatesHandler(NewRouter(svc), upd, PoolCfg{Workers: 3, QueueDepth: 2, UpdateTimeout: time.Second})
+	h.HandleUpdates(context.Background())
+
+	for chatID := int64(1); chatID <= chats; chatID++ {
+		got := svc.handled[chatID]
+		if len(got) != updatesPerChat {
+			t.Fatalf("HandleUpdates() chat %d got %d updates, want %d", chatID, len(got), updatesPerChat)
+		}
+		for i := range got {
+			if got[i] != i {
+				t.Errorf("HandleUpdates() chat %d got update %d at position %d", chatID, got[i], i)
+			}
+		}
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The channel is never closed, as with the webhook, so only cancellation stops the handler.
+	upd := make(chan bot.Update, 10)
+	for i := 0; i < 10; i++ {
+		upd <- bot.Update{
+			UpdateID: i,
+			Message:  &bot.Message{Chat: &bot.Chat{ID: int64(i)}, From: &bot.User{ID: i}},
+		}
+	}
+	cancel()
+
+	svc := &recordingService{handled: map[int64][]int{}}
+	h := NewUpdatesHandler(NewRouter(svc), upd, PoolCfg{Workers: 2, ShutdownTimeout: time.Second})
+	got := h.HandleUpdates(ctx)
+
+	want := Summary{Received: 10, Handled: 10}
+	if got != want {
+		t.Errorf("HandleUpdates() summary = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_Inbox(t *testing.T) {
+	newUpdate := func(id int) bot.Update {
+		return bot.Update{UpdateID: id, Message: &bot.Message{Chat: &bot.Chat{ID: int64(id)}, From: &bot.User{ID: id}}}
+	}
+
+	tests := []struct {
+		name         string
+		stored       bool
+		statuses     map[int]string
+		fail         bool
+		maxAttempts  int
+		wantSaved    []int
+		wantStatuses map[int]string
+		want         Summary
+	}{
+		{
+			name:         "1. Updates stored before handling and marked done",
+			wantSaved:    []int{1, 2},
+			wantStatuses: map[int]string{1: "done", 2: "done"},
+			want:         Summary{Received: 2, Handled: 2},
+		},
+		{
+			name:         "2. Updates stored by the source are not stored again",
+			stored:       true,
+			statuses:     map[int]string{1: "pending", 2: "pending"},
+			wantStatuses: map[int]string{1: "done", 2: "done"},
+			want:         Summary{Received: 2, Handled: 2},
+		},
+		{
+			name:         "3. Failed updates dead-lettered once out of attempts",
+			fail:         true,
+			maxAttempts:  1,
+			wantSaved:    []int{1, 2},
+			wantStatuses: map[int]string{1: "dead", 2: "dead"},
+			want:         Summary{Received: 2, Failed: 2},
+		},
+		{
+			name:         "4. Failed updates kept for retries while there are attempts left",
+			fail:         true,
+			maxAttempts:  3,
+			wantSaved:    []int{1, 2},
+			wantStatuses: map[int]string{1: "failed", 2: "failed"},
+			want:         Summary{Received: 2, Failed: 2},
+		},
+		{
+			name:         "5. Updates taken or finished elsewhere are skipped",
+			stored:       true,
+			statuses:     map[int]string{1: "processing", 2: "done"},
+			wantStatuses: map[int]string{1: "processing", 2: "done"},
+			want:         Summary{Received: 2, Skipped: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upd := make(chan bot.Update, 2)
+			upd <- newUpdate(1)
+			upd <- newUpdate(2)
+			close(upd)
+
+			inbox := newFakeInbox()
+			for id, status := range tt.statuses {
+				inbox.statuses[id] = status
+			}
+			if tt.maxAttempts > 0 {
+				inbox.maxAttempts = tt.maxAttempts
+			}
+
+			var svc MessageService = &recordingService{handled: map[int64][]int{}}
+			if tt.fail {
+				svc = MessageServiceFunc(func(context.Context, *bot.Update) error { return errors.New("some error") })
+			}
+
+			got := NewUpdatesHandler(NewRouter(svc), upd, PoolCfg{Workers: 1}).
+				WithInbox(inbox, InboxCfg{On: true}, tt.stored).
+				HandleUpdates(context.Background())
+
+			if got != tt.want {
+				t.Errorf("HandleUpdates() summary = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(inbox.saved, tt.wantSaved) {
+				t.Errorf("HandleUpdates() saved = %v, want %v", inbox.saved, tt.wantSaved)
+			}
+			if !reflect.DeepEqual(inbox.statuses, tt.wantStatuses) {
+				t.Errorf("HandleUpdates() statuses = %v, want %v", inbox.statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_InboxRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inbox := newFakeInbox()
+	inbox.statuses[7] = "failed"
+	inbox.unfinished = []bot.Update{{UpdateID: 7, Message: &bot.Message{Chat: &bot.Chat{ID: 1}, From: &bot.User{ID: 1}}}}
+
+	// The channel is never closed, so only the retries bring updates.
+	svc := &recordingService{handled: map[int64][]int{}}
+	h := NewUpdatesHandler(NewRouter(svc), make(chan bot.Update), PoolCfg{Workers: 1}).
+		WithInbox(inbox, InboxCfg{On: true, RetryInterval: time.Millisecond}, true)
+
+	go func() {
+		for inbox.status(7) != "done" {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	got := h.HandleUpdates(ctx)
+
+	want := Summary{Received: 1, Handled: 1, Retried: 1}
+	if got != want {
+		t.Errorf("HandleUpdates() summary = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(svc.handled[1], []int{7}) {
+		t.Errorf("HandleUpdates() handled = %v, want [7]", svc.handled[1])
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_ShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	upd := make(chan bot.Update, 3)
+	for i := 0; i < 3; i++ {
+		upd <- bot.Update{UpdateID: i, Message: &bot.Message{Chat: &bot.Chat{ID: 1}, From: &bot.User{ID: 1}}}
+	}
+	cancel()
+
+	// The only worker is stuck on the first update, so the rest cannot even be queued.
+	stuck := MessageServiceFunc(func(ctx context.Context, _ *bot.Update) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h := NewUpdatesHandler(NewRouter(stuck), upd, PoolCfg{Workers: 1, ShutdownTimeout: 50 * time.Millisecond})
+
+	returned := make(chan Summary)
+	go func() { returned <- h.HandleUpdates(ctx) }()
+
+	select {
+	case got := <-returned:
+		if got.Handled != 0 || got.Received > 2 {
+			t.Errorf("HandleUpdates() summary = %+v, want nothing handled and the last update not received", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("HandleUpdates() did not return once the shutdown timeout was exceeded")
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_Dedup(t *testing.T) {
+	dedup := NewMemoryDeduplicator(10)
+	failing := true
+	svc := MessageServiceFunc(func(_ context.Context, upd *bot.Update) error {
+		if upd.UpdateID == 1 && failing {
+			return errors.New("some error")
+		}
+		return nil
+	})
+
+	deliver := func() Summary {
+		upd := make(chan bot.Update, 2)
+		for i := 1; i <= 2; i++ {
+			upd <- bot.Update{UpdateID: i, Message: &bot.Message{Chat: &bot.Chat{ID: int64(i)}, From: &bot.User{ID: i}}}
+		}
+		close(upd)
+
+		return NewUpdatesHandler(NewRouter(svc), upd, PoolCfg{Workers: 1}).
+			WithDeduplicator(dedup).
+			HandleUpdates(context.Background())
+	}
+
+	got := deliver()
+	if want := (Summary{Received: 2, Handled: 1, Failed: 1}); got != want {
+		t.Errorf("HandleUpdates() first delivery summary = %+v, want %+v", got, want)
+	}
+
+	// Telegram delivers both again: the failed one is handled anew, the handled one is skipped.
+	failing = false
+	got = deliver()
+	if want := (Summary{Received: 1, Handled: 1, Duplicate: 1}); got != want {
+		t.Errorf("HandleUpdates() redelivery summary = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdatesHandler_HandleUpdates_DedupInFlight(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var calls int32
+	svc := MessageServiceFunc(func(_ context.Context, upd *bot.Update) error {
+		if upd.UpdateID != 1 {
+			return nil
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-unblock
+		return nil
+	})
+
+	upd := make(chan bot.Update)
+	go func() {
+		defer close(upd)
+
+		// Telegram delivers the update again while the first copy is still being handled.
+		upd <- bot.Update{UpdateID: 1, Message: &bot.Message{Chat: &bot.Chat{ID: 1}, From: &bot.User{ID: 1}}}
+		<-started
+		upd <- bot.Update{UpdateID: 1, Message: &bot.Message{Chat: &bot.Chat{ID: 1}, From: &bot.User{ID: 1}}}
+		// Updates are dispatched one by one, so taking the next one means the copy has been dispatched.
+		upd <- bot.Update{UpdateID: 2, Message: &bot.Message{Chat: &bot.Chat{ID: 2}, From: &bot.User{ID: 2}}}
+		close(unblock)
+	}()
+
+	got := NewUpdatesHandler(NewRouter(svc), upd, PoolCfg{Workers: 2, QueueDepth: 2}).
+		WithDeduplicator(NewMemoryDeduplicator(10)).
+		HandleUpdates(context.Background())
+
+	if want := (Summary{Received: 2, Handled: 2, Duplicate: 1}); got != want {
+		t.Errorf("HandleUpdates() summary = %+v, want %+v", got, want)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("HandleUpdates() handled the update %d times, want once", got)
+	}
+}
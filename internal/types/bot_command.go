@@ -0,0 +1,18 @@
+package types
+
+// Scopes of bot commands, as in Telegram Bot API.
+const (
+	ScopeAllPrivateChats = "all_private_chats"
+	ScopeAllGroupChats   = "all_group_chats"
+)
+
+// BotCommand is a command shown in the Telegram command menu.
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// BotCommandScope tells in which chats the commands are shown.
+type BotCommandScope struct {
+	Type string `json:"type"`
+}
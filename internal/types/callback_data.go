@@ -0,0 +1,71 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Actions of inline buttons.
+const (
+	// CallbackMenu shows another set of buttons under the message.
+	CallbackMenu = "m"
+	// CallbackForecast puts the forecast for the period into the message.
+	CallbackForecast = "f"
+)
+
+// Menus of inline buttons.
+const (
+	MenuPeriodType = "type"
+	MenuHours      = "hours"
+	MenuDays       = "days"
+)
+
+const callbackSeparator = "|"
+
+// CallbackData is what an inline button tells the bot when pressed. It carries the location,
+// so that buttons of old messages keep working whatever the user's recent location is.
+type CallbackData struct {
+	Action string
+	// Option is the menu to show or the period to forecast.
+	Option    string
+	Latitude  string
+	Longitude string
+}
+
+// String encodes the data to fit into the 64 bytes Telegram allows.
+func (d CallbackData) String() string {
+	return strings.Join([]string{d.Action, d.Option, d.Latitude, d.Longitude}, callbackSeparator)
+}
+
+// Coordinates returns the location the button is about.
+func (d CallbackData) Coordinates() *UserCoordinates {
+	return &UserCoordinates{Latitude: d.Latitude, Longitude: d.Longitude}
+}
+
+// ParseCallbackData decodes the data of a pressed inline button.
+func ParseCallbackData(data string) (*CallbackData, error) {
+	parts := strings.Split(data, callbackSeparator)
+	if len(parts) != 4 {
+		return nil, errors.Errorf("malformed callback data '%s'", data)
+	}
+
+	d := &CallbackData{Action: parts[0], Option: parts[1], Latitude: parts[2], Longitude: parts[3]}
+	if d.Action != CallbackMenu && d.Action != CallbackForecast {
+		return nil, errors.Errorf("unknown callback action '%s'", d.Action)
+	}
+
+	// The data comes from the client, so the location must be nothing but two numbers before it goes any further.
+	if !isCoordinate(d.Latitude, 90) || !isCoordinate(d.Longitude, 180) {
+		return nil, errors.Errorf("no valid location in callback data '%s'", data)
+	}
+
+	return d, nil
+}
+
+// isCoordinate tells whether the text is a number within [-limit, limit].
+func isCoordinate(text string, limit float64) bool {
+	v, err := strconv.ParseFloat(text, 64)
+	return err == nil && v >= -limit && v <= limit
+}
@@ -0,0 +1,82 @@
+package types
+
+import "github.com/pkg/errors"
+
+// ErrConversationChanged tells that the conversation was saved by someone else after it was read.
+var ErrConversationChanged = errors.New("conversation changed meanwhile")
+
+// ConversationState is the step of the conversation a chat is at.
+type ConversationState string
+
+const (
+	// StateNone is for chats with no state stored, e.g. started before states were kept.
+	StateNone               ConversationState = ""
+	StateMainMenu           ConversationState = "main_menu"
+	StateAwaitingCity       ConversationState = "awaiting_city"
+	StateChoosingPeriodType ConversationState = "choosing_period_type"
+	StateChoosingHours      ConversationState = "choosing_hours"
+	StateChoosingDays       ConversationState = "choosing_days"
+	// StateAwaitingForgetConfirmation is where /forget leaves the chat, the only one deleting the user is allowed in.
+	StateAwaitingForgetConfirmation ConversationState = "awaiting_forget_confirmation"
+)
+
+// Conversation is the current state of a chat and the steps that led to it.
+type Conversation struct {
+	State   ConversationState
+	History []ConversationState
+	// Version is the version read from the store, so that it is saved only if nobody has saved it since.
+	Version int
+}
+
+// Forward moves the conversation to the next state. Coming back to a state
+// that is already in the history rewinds the history to it.
+func (c *Conversation) Forward(next ConversationState) {
+	if next == c.State {
+		return
+	}
+
+	for i, state := range c.History {
+		if state == next {
+			c.History = c.History[:i]
+			c.State = next
+			return
+		}
+	}
+
+	if c.State != StateNone {
+		c.History = append(c.History, c.State)
+	}
+	c.State = next
+}
+
+// Back moves the conversation to the previous state, or to the main menu if there is none.
+func (c *Conversation) Back() ConversationState {
+	if len(c.History) == 0 {
+		c.State = StateMainMenu
+		return c.State
+	}
+
+	c.State = c.History[len(c.History)-1]
+	c.History = c.History[:len(c.History)-1]
+
+	return c.State
+}
+
+// Rebase moves a newer version of the conversation, saved meanwhile, to the state this one has got to.
+// The newer history is kept unless this conversation was started over.
+func (c *Conversation) Rebase(newer *Conversation) {
+	state, reset := c.State, len(c.History) == 0
+	c.Version = newer.Version
+	if reset {
+		return
+	}
+
+	c.State, c.History = newer.State, append([]ConversationState(nil), newer.History...)
+	c.Forward(state)
+}
+
+// Reset starts the conversation over from the main menu.
+func (c *Conversation) Reset() {
+	c.State = StateMainMenu
+	c.History = nil
+}
@@ -0,0 +1,13 @@
+package types
+
+// Favorite is a location the user saved under a name of their own.
+type Favorite struct {
+	Name      string `db:"name"`
+	Latitude  string `db:"latitude"`
+	Longitude string `db:"longitude"`
+}
+
+// Coordinates returns the location of the favorite.
+func (f *Favorite) Coordinates() *UserCoordinates {
+	return &UserCoordinates{Latitude: f.Latitude, Longitude: f.Longitude}
+}
@@ -0,0 +1,17 @@
+package types
+
+// ForgottenUser tells how much of the user's data was deleted.
+type ForgottenUser struct {
+	Profiles      int64
+	Locations     int64
+	Favorites     int64
+	Conversations int64
+	// Groups are the group settings the user changed last, kept for the group with the user's ID erased.
+	Groups  int64
+	Updates int64
+}
+
+// Total returns the number of rows deleted or erased.
+func (f *ForgottenUser) Total() int64 {
+	return f.Profiles + f.Locations + f.Favorites + f.Conversations + f.Groups + f.Updates
+}
@@ -0,0 +1,38 @@
+package types
+
+import "context"
+
+// GroupSettings are defaults chosen by the admins of a group chat.
+type GroupSettings struct {
+	LocationName string `db:"location_name"`
+	Latitude     string `db:"latitude"`
+	Longitude    string `db:"longitude"`
+	Language     string `db:"language"`
+}
+
+// HasLocation tells whether the group has a default location.
+func (g *GroupSettings) HasLocation() bool {
+	return g.Latitude != "" && g.Longitude != ""
+}
+
+// Coordinates returns the default location of the group.
+func (g *GroupSettings) Coordinates() *UserCoordinates {
+	return &UserCoordinates{Latitude: g.Latitude, Longitude: g.Longitude}
+}
+
+type languageKey struct{}
+
+// ContextWithLanguage makes forecasts got within ctx come in the language instead of the configured one.
+func ContextWithLanguage(ctx context.Context, lang string) context.Context {
+	if lang == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// LanguageFrom returns the language forecasts are asked in within ctx, empty for the configured one.
+func LanguageFrom(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
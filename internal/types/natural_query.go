@@ -0,0 +1,67 @@
+package types
+
+import "time"
+
+// DayPart is a part of the day a query may ask about, e.g. the evening.
+type DayPart struct {
+	Name string
+	// From and To are the hours the part starts and ends at, To excluded. To is past 24 for parts that end the next day.
+	From, To int
+}
+
+// Parts of the day, as most people tell them.
+var (
+	Morning   = DayPart{Name: "morning", From: 6, To: 12}
+	Afternoon = DayPart{Name: "afternoon", From: 12, To: 18}
+	Evening   = DayPart{Name: "evening", From: 18, To: 24}
+	Night     = DayPart{Name: "night", From: 22, To: 30}
+	Tonight   = DayPart{Name: "tonight", From: 18, To: 30}
+)
+
+// TimeWindow is the time a query asks about, counted in days from today at the place.
+type TimeWindow struct {
+	// From and To are the first and the last day of the window, both included.
+	From, To int
+	// OnWeekday makes the days count from the nearest Weekday instead of today.
+	OnWeekday bool
+	Weekday   time.Weekday
+	// Part narrows every day of the window down to a part of the day, nil for whole days.
+	Part *DayPart
+}
+
+// Days returns the first and the last day of the window counted from today, the day of the week given.
+// A window that has begun already, e.g. the weekend asked about on Sunday, starts today.
+func (w TimeWindow) Days(today time.Weekday) (int, int) {
+	if !w.OnWeekday {
+		return w.From, w.To
+	}
+
+	shift := (int(w.Weekday) - int(today) + 7) % 7
+	if shift+w.To >= 7 {
+		shift -= 7
+	}
+
+	from, to := shift+w.From, shift+w.To
+	if from < 0 {
+		from = 0
+	}
+
+	return from, to
+}
+
+// Hours returns the hours the window starts and ends at, counted from the midnight of today, the end excluded.
+func (w TimeWindow) Hours(today time.Weekday) (int, int) {
+	from, to := w.Days(today)
+	if w.Part == nil {
+		return from * 24, (to + 1) * 24
+	}
+
+	return from*24 + w.Part.From, to*24 + w.Part.To
+}
+
+// NaturalQuery is a query typed in words, like 'tomorrow in Paris'.
+type NaturalQuery struct {
+	// Place is the name of the place, empty for the location shared or named last.
+	Place  string
+	Window TimeWindow
+}
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PeriodUnit tells whether the forecast is for now, by hours or by days.
+type PeriodUnit string
+
+const (
+	PeriodNow   PeriodUnit = "now"
+	PeriodHours PeriodUnit = "hours"
+	PeriodDays  PeriodUnit = "days"
+)
+
+// The longest forecasts the provider gives.
+const (
+	MaxForecastHours = 120
+	MaxForecastDays  = 16
+)
+
+// Period is how far ahead the forecast goes.
+type Period struct {
+	Unit PeriodUnit
+	// Length is the number of hours or days, zero for now.
+	Length int
+}
+
+// Now is the period of the current weather.
+func Now() Period {
+	return Period{Unit: PeriodNow}
+}
+
+// Hours is the period of the next n hours.
+func Hours(n int) Period {
+	return Period{Unit: PeriodHours, Length: n}
+}
+
+// Days is the period of the next n days.
+func Days(n int) Period {
+	return Period{Unit: PeriodDays, Length: n}
+}
+
+// String returns the period the way it is written on buttons, e.g. '36 hours'.
+func (p Period) String() string {
+	switch p.Unit {
+	case PeriodHours, PeriodDays:
+		unit := string(p.Unit)
+		if p.Length == 1 {
+			unit = strings.TrimSuffix(unit, "s")
+		}
+		return fmt.Sprintf("%d %s", p.Length, unit)
+	default:
+		return "Now"
+	}
+}
+
+// Max returns the longest period of the unit the provider gives.
+func (p Period) Max() int {
+	switch p.Unit {
+	case PeriodHours:
+		return MaxForecastHours
+	case PeriodDays:
+		return MaxForecastDays
+	default:
+		return 0
+	}
+}
+
+// Validate tells whether the provider gives forecasts that far ahead.
+func (p Period) Validate() error {
+	switch p.Unit {
+	case PeriodNow:
+		return nil
+	case PeriodHours, PeriodDays:
+		if p.Length < 1 || p.Length > p.Max() {
+			return errors.Errorf("%s is out of range from 1 to %d", p, p.Max())
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown period unit '%s'", p.Unit)
+	}
+}
+
+// periodPattern matches horizons like '36 hours', '9 days', 'next 2 days' or '12h'.
+var periodPattern = regexp.MustCompile(`^(?:next\s+)?(\d{1,6})\s*(h|hrs?|hours?|d|days?)$`)
+
+// ParsePeriod reads a period the user typed or tapped. Periods out of the provider's range
+// are read as well, so that the user can be told about the range.
+func ParsePeriod(s string) (Period, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "now" {
+		return Now(), nil
+	}
+
+	m := periodPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Period{}, errors.Errorf("'%s' is not a period", s)
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Period{}, errors.Wrapf(err, "'%s' is not a period", s)
+	}
+
+	if strings.HasPrefix(m[2], "d") {
+		return Days(n), nil
+	}
+
+	return Hours(n), nil
+}
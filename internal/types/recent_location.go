@@ -0,0 +1,13 @@
+package types
+
+// RecentLocation is a place the user asked the weather for, named after the forecast for it.
+type RecentLocation struct {
+	Name      string `db:"location_name"`
+	Latitude  string `db:"latitude"`
+	Longitude string `db:"longitude"`
+}
+
+// Coordinates returns the location of the place.
+func (l *RecentLocation) Coordinates() *UserCoordinates {
+	return &UserCoordinates{Latitude: l.Latitude, Longitude: l.Longitude}
+}
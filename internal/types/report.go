@@ -17,6 +17,7 @@ type Stat struct {
 	PartOfDay        string  `json:"pod"`
 	CityName         string  `json:"city_name"`
 	DateTime         string  `json:"datetime"`
+	TimestampLocal   string  `json:"timestamp_local"`
 	WindDirection    string  `json:"wind_cdir"`
 	SunriseTime      string  `json:"sunrise"`
 	SunsetTime       string  `json:"sunset"`
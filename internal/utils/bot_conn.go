@@ -6,9 +6,26 @@ import (
 	bot "gopkg.in/telegram-bot-api.v4"
 )
 
-// NewBotApi creates a BotAPI instance from token and sets a webhook.
+const (
+	// UpdateModeWebhook makes Telegram push updates to the configured webhook.
+	UpdateModeWebhook = "webhook"
+	// UpdateModePolling makes the bot pull updates with getUpdates, no public endpoint needed.
+	UpdateModePolling = "polling"
+)
+
+// NewBotApi creates a BotAPI instance from token and prepares it for the configured update mode.
 func NewBotApi() *bot.BotAPI {
-	botAPI, err := bot.NewBotAPI(viper.GetString("bot_token"))
+	mode := viper.GetString("update_mode")
+	if mode != UpdateModeWebhook && mode != UpdateModePolling {
+		logrus.Fatalf("Unknown update mode '%s', expected '%s' or '%s'", mode, UpdateModeWebhook, UpdateModePolling)
+	}
+
+	client, err := NewBotHTTPClient(viper.GetString("bot_api_url"))
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot create Bot API client")
+	}
+
+	botAPI, err := bot.NewBotAPIWithClient(viper.GetString("bot_token"), client)
 	if err != nil {
 		logrus.WithError(err).Fatal("Cannot create new BotAPI with given token")
 	}
@@ -16,12 +33,17 @@ func NewBotApi() *bot.BotAPI {
 	botAPI.Debug = viper.GetBool("bot_debug_on")
 	logrus.Infof("Authorized on account '%s'", botAPI.Self.UserName)
 
-	// Deleting existing webhook from bot
+	// Deleting existing webhook from bot, getUpdates does not work while one is set.
 	_, err = botAPI.RemoveWebhook()
 	if err != nil {
 		logrus.WithError(err).Fatal("Cannot delete WebHook")
 	}
 
+	if mode == UpdateModePolling {
+		logrus.Info("Running in polling mode, no webhook is set")
+		return botAPI
+	}
+
 	// Setting a webhook (e.g. using ngrok)
 	_, err = botAPI.SetWebhook(bot.NewWebhook(viper.GetString("webhook")))
 	if err != nil {
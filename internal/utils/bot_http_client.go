@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// NewBotHTTPClient creates a client for the Bot API, sending requests to apiURL instead of Telegram if one is given.
+// The bot library has the Telegram address built in, so requests are redirected on the transport level.
+func NewBotHTTPClient(apiURL string) (*http.Client, error) {
+	if apiURL == "" {
+		return &http.Client{}, nil
+	}
+
+	target, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse Bot API URL '%s'", apiURL)
+	}
+
+	return &http.Client{Transport: redirectTransport{target: target}}, nil
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+
+	return http.DefaultTransport.RoundTrip(r)
+}
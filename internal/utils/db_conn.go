@@ -28,7 +28,7 @@ func init() {
 	pflag.String("db_pass", "1234", "Database password")
 	pflag.Bool("db_ssl", false, "Is database SSL mode on")
 
-	sql.Register("instrumented-postgres", stdlib.GetDefaultDriver())
+	sql.Register("instrumented-postgres", newInstrumentedDriver(stdlib.GetDefaultDriver()))
 }
 
 // NewDBFromEnv establishes a new db connection and returns a wrapper.
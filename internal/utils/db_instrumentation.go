@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"time"
+	"weather-or-not-bot/internal/metrics"
+	"weather-or-not-bot/internal/tracing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	pflag.Duration("db_slow_query_threshold", 200*time.Millisecond, "Queries running longer are logged as slow, 0 to turn it off")
+}
+
+// queryNamePattern finds the name given to a query by a leading "-- name: <name>" comment.
+var queryNamePattern = regexp.MustCompile(`^\s*--\s*name:\s*(\S+)`)
+
+// instrumentedDriver wraps every query of the underlying driver with timing, metrics and logging.
+type instrumentedDriver struct {
+	driver.Driver
+}
+
+func newInstrumentedDriver(d driver.Driver) *instrumentedDriver {
+	return &instrumentedDriver{Driver: d}
+}
+
+// Open opens a connection, the driver is registered before flags are parsed, so they are read here.
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedConn{Conn: conn, slowQuery: viper.GetDuration("db_slow_query_threshold")}, nil
+}
+
+type instrumentedConn struct {
+	driver.Conn
+	slowQuery time.Duration
+}
+
+// observe records the query outcome and logs it with the fields of the context logger.
+func (c *instrumentedConn) observe(ctx context.Context, query string, start time.Time, err error) {
+	// ErrSkip makes database/sql fall back to another way of running the same query.
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	name := queryName(query)
+	elapsed := time.Since(start)
+	metrics.ObserveQuery(name, start, err)
+	tracing.RecordError(ctx, err)
+
+	log := ctxlogrus.Extract(ctx).WithFields(logrus.Fields{
+		"query_name": name,
+		"query":      compactQuery(query),
+		"elapsed":    elapsed,
+	})
+	switch {
+	case err != nil:
+		log.WithError(err).Warn("Query failed")
+	case c.slowQuery > 0 && elapsed > c.slowQuery:
+		log.Warn("Slow query")
+	default:
+		log.Debug("Query done")
+	}
+}
+
+// queryName returns the name given to the query, or its first keyword if it has none.
+func queryName(query string) string {
+	if m := queryNamePattern.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "empty"
+	}
+
+	return strings.ToLower(fields[0])
+}
+
+// compactQuery puts the query on a single line.
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	c.observe(ctx, query, start, err)
+
+	return res, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.observe(ctx, query, start, err)
+
+	return rows, err
+}
+
+// PrepareContext prepares the statement, which is then timed, counted and logged on every run like any other query.
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *instrumentedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+// instrumentedStmt wraps runs of a prepared statement the same way as queries run directly on the connection.
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  *instrumentedConn
+	query string
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := s.runExec(ctx, args)
+	s.conn.observe(ctx, s.query, start, err)
+
+	return res, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.runQuery(ctx, args)
+	s.conn.observe(ctx, s.query, start, err)
+
+	return rows, err
+}
+
+func (s *instrumentedStmt) runExec(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stmt.Exec(values)
+}
+
+func (s *instrumentedStmt) runQuery(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stmt.Query(values)
+}
+
+func (s *instrumentedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+// namedValuesToValues turns arguments into the form statements without context support take,
+// these know nothing about named arguments.
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.Errorf("named argument '%s' is not supported by the driver", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
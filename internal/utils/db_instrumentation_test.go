@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"weather-or-not-bot/internal/metrics"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"1. Named query", "\n\t-- name: get_update_offset\n\tSELECT update_offset FROM update_offsets;", "get_update_offset"},
+		{"2. Unnamed query", "\n\tCREATE TABLE IF NOT EXISTS users (user_id BIGINT);", "create"},
+		{"3. Empty query", " ", "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryName(tt.query); got != tt.want {
+				t.Errorf("queryName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentedDriver(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	mockDB, mock, err := sqlmock.NewWithDSN("instrumented_driver_test")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	sql.Register("instrumented-sqlmock", newInstrumentedDriver(mockDB.Driver()))
+	db, err := sql.Open("instrumented-sqlmock", "instrumented_driver_test")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening the instrumented driver", err)
+	}
+	defer db.Close()
+
+	const (
+		execQuery  = "-- name: test_exec\nDELETE FROM test_table;"
+		queryQuery = "-- name: test_query\nSELECT id FROM test_table;"
+	)
+	errsBefore := testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues(metrics.ErrorDatabase))
+
+	mock.ExpectExec("DELETE FROM test_table").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM test_table").WillReturnError(someErr)
+
+	_, err = db.ExecContext(ctx, execQuery)
+	if err != nil {
+		t.Errorf("ExecContext() error = %v, want none", err)
+	}
+
+	_, err = db.QueryContext(ctx, queryQuery)
+	if !errors.Is(err, someErr) {
+		t.Errorf("QueryContext() error = %v, want %v", err, someErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	if got := testutil.CollectAndCount(metrics.QueryDuration, "weather_bot_db_query_duration_seconds"); got < 2 {
+		t.Errorf("instrumented driver left %d series, want at least 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues(metrics.ErrorDatabase)) - errsBefore; got != 1 {
+		t.Errorf("instrumented driver counted %v errors, want 1", got)
+	}
+}
+
+func TestInstrumentedDriver_PreparedStatement(t *testing.T) {
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	mockDB, mock, err := sqlmock.NewWithDSN("instrumented_driver_prepared_test")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	sql.Register("instrumented-sqlmock-prepared", newInstrumentedDriver(mockDB.Driver()))
+	db, err := sql.Open("instrumented-sqlmock-prepared", "instrumented_driver_prepared_test")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening the instrumented driver", err)
+	}
+	defer db.Close()
+
+	const query = "-- name: test_prepared\nSELECT id FROM test_table WHERE id = $1;"
+	seriesBefore := testutil.CollectAndCount(metrics.QueryDuration, "weather_bot_db_query_duration_seconds")
+	errsBefore := testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues(metrics.ErrorDatabase))
+
+	prepared := mock.ExpectPrepare("SELECT id FROM test_table")
+	prepared.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	prepared.ExpectQuery().WithArgs(2).WillReturnError(someErr)
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		t.Fatalf("PrepareContext() error = %v, want none", err)
+	}
+	defer stmt.Close()
+
+	var id int
+	err = stmt.QueryRowContext(ctx, 1).Scan(&id)
+	if err != nil {
+		t.Errorf("QueryRowContext() error = %v, want none", err)
+	}
+
+	_, err = stmt.QueryContext(ctx, 2)
+	if !errors.Is(err, someErr) {
+		t.Errorf("QueryContext() error = %v, want %v", err, someErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	if got := testutil.CollectAndCount(metrics.QueryDuration, "weather_bot_db_query_duration_seconds") - seriesBefore; got != 2 {
+		t.Errorf("instrumented driver added %d series for the prepared statement, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues(metrics.ErrorDatabase)) - errsBefore; got != 1 {
+		t.Errorf("instrumented driver counted %v errors, want 1", got)
+	}
+}
@@ -40,6 +40,60 @@ const initMigration = `
 	longitude VARCHAR(64) NOT NULL DEFAULT '',
 	location_name VARCHAR(64) NOT NULL DEFAULT ''
 );
+
+	CREATE TABLE IF NOT EXISTS update_offsets
+(
+	bot_id BIGINT PRIMARY KEY,
+	update_offset BIGINT NOT NULL DEFAULT 0
+);
+
+	CREATE TABLE IF NOT EXISTS processed_updates
+(
+	update_id BIGINT PRIMARY KEY,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+	CREATE TABLE IF NOT EXISTS inbox
+(
+	update_id BIGINT PRIMARY KEY,
+	payload JSONB NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ NOT NULL DEFAULT now();
+
+	CREATE TABLE IF NOT EXISTS conversations
+(
+	chat_id BIGINT PRIMARY KEY,
+	state VARCHAR(32) NOT NULL,
+	history JSONB NOT NULL DEFAULT '[]',
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+	ALTER TABLE conversations ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS group_settings
+(
+	chat_id BIGINT PRIMARY KEY,
+	location_name VARCHAR(64) NOT NULL DEFAULT '',
+	latitude VARCHAR(64) NOT NULL DEFAULT '',
+	longitude VARCHAR(64) NOT NULL DEFAULT '',
+	language VARCHAR(8) NOT NULL DEFAULT '',
+	updated_by BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+	CREATE TABLE IF NOT EXISTS favorites
+(
+	user_id BIGINT NOT NULL,
+	name VARCHAR(32) NOT NULL,
+	latitude VARCHAR(64) NOT NULL DEFAULT '',
+	longitude VARCHAR(64) NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, name)
+);
 	drop table if exists world_cities;
 
 	CREATE TABLE IF NOT EXISTS world_cities
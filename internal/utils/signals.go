@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+)
+
+// CancelOnSignal calls cancelFunc once the process is asked to stop with SIGINT or SIGTERM.
+func CancelOnSignal(ctx context.Context, cancelFunc context.CancelFunc) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(sigs)
+
+		select {
+		case sig := <-sigs:
+			ctxlogrus.Extract(ctx).Infof("Received %v, shutting down", sig)
+			cancelFunc()
+		case <-ctx.Done():
+		}
+	}()
+}
@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
+	"weather-or-not-bot/internal/metrics"
 	"weather-or-not-bot/internal/repository"
 	"weather-or-not-bot/internal/service"
+	"weather-or-not-bot/internal/tracing"
 	"weather-or-not-bot/internal/transport"
 	"weather-or-not-bot/internal/utils"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	bot "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	// webhookBuffer is the number of received updates waiting to be dispatched, as in the bot library.
+	webhookBuffer = 100
+	// tracesFlushTimeout limits exporting the remaining spans on shutdown.
+	tracesFlushTimeout = 5 * time.Second
 )
 
 func init() {
 	pflag.String("bot_token", `fake_token`, "Token to access Telegram Bot API")
+	pflag.String("bot_api_url", "", "Bot API address to use instead of Telegram, e.g. a fake one")
 	pflag.String("port", ":8080", "Port to listen to")
 	pflag.Bool("bot_debug_on", true, "Turn on bot debug")
 
+	pflag.String("update_mode", utils.UpdateModeWebhook, "How to get updates from bot: 'webhook' or 'polling'")
 	pflag.String("webhook", "https://some-numbers.ngrok.io", "Webhook URL to get updates from bot")
+	pflag.Int("polling_timeout", 60, "Long polling timeout in seconds")
+	pflag.Duration("webhook_check_interval", time.Minute, "How often readiness asks Telegram about the webhook, reported for information only")
 	pflag.String("weather_api_key", `fake_key`, "Client's key to access weather API")
+	pflag.Float64("forecast_max_error_rate", 0.5, "Share of failed forecast requests above which the bot is not ready")
+	pflag.Duration("http_shutdown_timeout", 5*time.Second, "Time limit for finishing webhook and health requests on shutdown, 0 for none")
 
 	pflag.String("language", "", "Service language")
+	pflag.Bool("inline_keyboards", false, "Offer forecast periods as buttons under the message, editing it in place")
+	pflag.IntSlice("admin_ids", nil, "Telegram IDs of users allowed to run admin commands")
+	pflag.Duration("send_stats_interval", time.Minute, "How often outgoing messages stats are logged")
 
 	pflag.Parse()
 	_ = viper.BindPFlags(pflag.CommandLine)
@@ -32,9 +55,11 @@ func main() {
 	ctx, cancelFunc := utils.NewLogger()
 	defer cancelFunc()
 
+	// Stopping gracefully on SIGINT and SIGTERM.
+	utils.CancelOnSignal(ctx, cancelFunc)
+
 	// Establishing connection to database.
 	db := utils.NewDBFromEnv()
-	defer db.Close()
 
 	// Running init SQL
 	err := utils.RunInitMigration(ctx, db)
@@ -42,6 +67,12 @@ func main() {
 		logrus.WithError(err).Fatal("Cannot listen and serve")
 	}
 
+	// Exporting spans if asked to.
+	shutdownTracing, err := tracing.Setup(ctx, tracing.NewTraceCfgFromEnv())
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot set up tracing")
+	}
+
 	// Initiating all repositories.
 	usrRepo := repository.NewUserDataRepo(db)
 	locRepo := repository.NewLocationRepo(db)
@@ -49,24 +80,177 @@ func main() {
 	botUIRepo := repository.NewBotUIRepo()
 
 	// Establishing client connections.
-	botClient := service.NewBotCmd(utils.NewBotApi())
+	botCmd := service.NewBotCmd(utils.NewBotApi())
+	botClient := service.NewThrottledBotClient(botCmd, service.NewThrottleCfgFromEnv())
+	go botClient.ReportStats(ctx, viper.GetDuration("send_stats_interval"))
+	go botClient.SweepIdleChats(ctx)
 	forecastClient := repository.NewForecastClient()
 
 	formatter := service.NewFormatter()
 
+	// Showing the commands in the Telegram command menu.
+	commands := service.NewCommandRegistry()
+	err = commands.Sync(ctx, botCmd)
+	if err != nil {
+		logrus.WithError(err).Warn("Cannot set bot commands, the command menu may be outdated")
+	}
+
+	// Reusing recent forecasts unless turned off.
+	var forecasts service.ForecastClient = forecastClient
+	if ttl := viper.GetDuration("forecast_cache_ttl"); ttl > 0 {
+		forecasts = service.NewCachedForecastClient(forecastClient, ttl)
+	}
+
 	// Instantiating main service.
-	svc := service.NewMessageService(botClient, forecastClient, formatter, botUIRepo, locRepo, usrLocRepo, usrRepo)
+	svc := service.NewMessageService(botClient, forecasts, formatter, botUIRepo, locRepo, usrLocRepo, usrRepo).
+		WithAdmins(viper.GetIntSlice("admin_ids")).
+		WithCommands(commands).
+		WithGroups(botCmd.Self(), repository.NewGroupSettingsRepo(db)).
+		WithFavorites(repository.NewFavoriteRepo(db)).
+		WithGrammars(service.EnglishGrammar{}).
+		WithConversations(repository.NewConversationRepo(db))
+
+	// Storing incoming updates durably if asked to.
+	inboxCfg := transport.NewInboxCfgFromEnv()
+	var inboxRepo *repository.InboxRepo
+	if inboxCfg.On {
+		inboxRepo = repository.NewInboxRepo(db, inboxCfg.MaxAttempts, inboxCfg.Lease)
+		svc.WithInbox(inboxRepo)
+	}
+
+	// Editing messages in place instead of sending new ones if asked to.
+	if viper.GetBool("inline_keyboards") {
+		svc.WithInlineKeyboards()
+	}
+
+	// Reporting liveness and readiness to the orchestrator.
+	webhookURL := viper.GetString("webhook")
+	if viper.GetString("update_mode") == utils.UpdateModePolling {
+		webhookURL = ""
+	}
+	health := transport.NewHealthHandler(viper.GetDuration("readiness_timeout")).
+		AddCheck("database", db.PingContext).
+		AddCheck("world_cities", locRepo.CheckWorldCities).
+		AddCheck("forecast_provider", forecastClient.CheckErrorRate).
+		AddInfo("webhook", transport.CachedCheck(func(ctx context.Context) error {
+			return botCmd.CheckWebhook(ctx, webhookURL)
+		}, viper.GetDuration("webhook_check_interval")))
+	http.HandleFunc("/healthz", health.Liveness)
+	http.HandleFunc("/readyz", health.Readiness)
+	http.Handle("/metrics", metrics.Handler())
 
 	// Launching a server.
+	srv := &http.Server{Addr: viper.GetString("port")}
 	go func() {
-		err := http.ListenAndServe(viper.GetString("port"), nil)
-		if err != nil {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logrus.WithError(err).Fatal("Cannot listen and serve")
 		}
 	}()
 	logrus.Infof("Start listen on port %s", viper.GetString("port"))
 
-	//Handling messages from user.
-	updatesHandler := transport.NewUpdatesHandler(svc, botClient.ListenForWebhook("/"))
-	updatesHandler.HandleUpdates(ctx)
+	// Stopping accepting webhook calls on shutdown, and only then receiving the updates they brought,
+	// so that no acknowledged update is put into the channel after it is drained.
+	receiveCtx, stopReceiving := context.WithCancel(ctxlogrus.ToContext(context.Background(), ctxlogrus.Extract(ctx)))
+	defer stopReceiving()
+	go func() {
+		<-ctx.Done()
+		defer stopReceiving()
+
+		shutdownCtx := context.Background()
+		if timeout := viper.GetDuration("http_shutdown_timeout"); timeout > 0 {
+			var cancel context.CancelFunc
+			shutdownCtx, cancel = context.WithTimeout(shutdownCtx, timeout)
+			defer cancel()
+		}
+
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			logrus.WithError(err).Warn("Cannot shut down the server gracefully")
+		}
+	}()
+
+	// Choosing the source of updates.
+	var (
+		updates bot.UpdatesChannel
+		stored  bool
+	)
+	switch viper.GetString("update_mode") {
+	case utils.UpdateModePolling:
+		// Polled updates are confirmed to Telegram once stored in the inbox, or as soon as they are received without it.
+		var store service.UpdateStore
+		if inboxCfg.On {
+			store, stored = inboxRepo, true
+		} else {
+			logrus.Warn("Polling with no inbox, updates received but not handled before a crash are lost")
+		}
+		updates, err = botCmd.ListenForPolling(ctx, repository.NewUpdateOffsetRepo(db), store, viper.GetInt("polling_timeout"))
+		if err != nil {
+			logrus.WithError(err).Fatal("Cannot start polling for updates")
+		}
+	default:
+		if inboxCfg.On {
+			var webhook http.Handler
+			webhook, updates = transport.NewInboxWebhook(ctx, inboxRepo, webhookBuffer)
+			http.Handle("/", webhook)
+			stored = true
+		} else {
+			updates = botClient.ListenForWebhook("/")
+		}
+	}
+
+	// Wrapping message handling with middlewares enabled in config.
+	middlewares, err := transport.NewMiddlewareRegistry(botClient).Build(viper.GetStringSlice("middlewares"))
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot build middlewares")
+	}
+
+	//Handling messages from user until shutdown.
+	callbacks := transport.Chain(transport.MessageServiceFunc(svc.HandleCallbackQuery), middlewares...)
+	inline := transport.Chain(transport.MessageServiceFunc(svc.HandleInlineQuery), middlewares...)
+	router := transport.NewRouter(transport.Chain(svc, middlewares...)).
+		WithCallbackQueryHandler(transport.CallbackQueryHandlerFunc(callbacks.HandleNewMessage)).
+		WithInlineQueryHandler(transport.InlineQueryHandlerFunc(inline.HandleNewMessage))
+	updatesHandler := transport.NewUpdatesHandler(router, updates, transport.NewPoolCfgFromEnv())
+
+	if inboxCfg.On {
+		updatesHandler.WithInbox(inboxRepo, inboxCfg, stored)
+	}
+
+	// Skipping updates redelivered by Telegram.
+	switch viper.GetString("dedup_mode") {
+	case transport.DedupModeMemory:
+		updatesHandler.WithDeduplicator(transport.NewMemoryDeduplicator(viper.GetInt("dedup_window")))
+	case transport.DedupModePostgres:
+		updatesHandler.WithDeduplicator(repository.NewProcessedUpdateRepo(db, viper.GetInt("dedup_window")))
+	case transport.DedupModeOff:
+	default:
+		logrus.Fatalf("Unknown dedup mode '%s'", viper.GetString("dedup_mode"))
+	}
+
+	summary := updatesHandler.HandleUpdates(receiveCtx)
+	botClient.Close()
+
+	err = db.Close()
+	if err != nil {
+		logrus.WithError(err).Warn("Cannot close the database connection")
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), tracesFlushTimeout)
+	defer cancelFlush()
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		logrus.WithError(err).Warn("Cannot flush the remaining spans")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"received":    summary.Received,
+		"handled":     summary.Handled,
+		"failed":      summary.Failed,
+		"abandoned":   summary.Abandoned,
+		"duplicate":   summary.Duplicate,
+		"retried":     summary.Retried,
+		"skipped":     summary.Skipped,
+		"unsupported": router.Unsupported(),
+	}).Info("Shut down")
 }